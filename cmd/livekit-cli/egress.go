@@ -130,6 +130,8 @@ var (
 					Name:  "active",
 					Usage: "lists only active egresses",
 				},
+				watchFlag,
+				watchIntervalFlag,
 			),
 		},
 		{
@@ -249,8 +251,7 @@ func startRoomCompositeEgress(c *cli.Context) error {
 		return err
 	}
 
-	printInfo(info)
-	return nil
+	return printInfo(info)
 }
 
 func startWebEgress(c *cli.Context) error {
@@ -264,8 +265,7 @@ func startWebEgress(c *cli.Context) error {
 		return err
 	}
 
-	printInfo(info)
-	return nil
+	return printInfo(info)
 }
 
 func startParticipantEgress(c *cli.Context) error {
@@ -279,8 +279,7 @@ func startParticipantEgress(c *cli.Context) error {
 		return err
 	}
 
-	printInfo(info)
-	return nil
+	return printInfo(info)
 }
 
 func startTrackCompositeEgress(c *cli.Context) error {
@@ -294,8 +293,7 @@ func startTrackCompositeEgress(c *cli.Context) error {
 		return err
 	}
 
-	printInfo(info)
-	return nil
+	return printInfo(info)
 }
 
 func startTrackEgress(c *cli.Context) error {
@@ -309,8 +307,7 @@ func startTrackEgress(c *cli.Context) error {
 		return err
 	}
 
-	printInfo(info)
-	return nil
+	return printInfo(info)
 }
 
 func unmarshalEgressRequest(c *cli.Context, req proto.Message) error {
@@ -324,79 +321,116 @@ func unmarshalEgressRequest(c *cli.Context, req proto.Message) error {
 	}
 
 	if c.Bool("verbose") {
-		PrintJSON(req)
+		fprintJSON(os.Stderr, req)
 	}
 	return nil
 }
 
 func listEgress(c *cli.Context) error {
-	var items []*livekit.EgressInfo
+	if c.Bool("watch") {
+		return watchList(c, "list-egress", egressTableHeader, 1, func(ctx context.Context) ([]watchRow, error) {
+			items, err := fetchEgress(ctx, c)
+			if err != nil {
+				return nil, err
+			}
+			rows := make([]watchRow, 0, len(items))
+			for _, item := range items {
+				rows = append(rows, watchRow{
+					key:    item.EgressId,
+					label:  item.EgressId,
+					status: item.Status.String(),
+					fields: egressTableRow(item),
+					item:   item,
+				})
+			}
+			return rows, nil
+		})
+	}
+
+	items, err := fetchEgress(context.Background(), c)
+	if err != nil {
+		return err
+	}
+	return printOutput(items, func() {
+		printEgressTable(items)
+	})
+}
+
+func fetchEgress(ctx context.Context, c *cli.Context) ([]*livekit.EgressInfo, error) {
+	items := []*livekit.EgressInfo{}
 	if c.IsSet("id") {
 		for _, id := range c.StringSlice("id") {
-			res, err := egressClient.ListEgress(context.Background(), &livekit.ListEgressRequest{
+			res, err := egressClient.ListEgress(ctx, &livekit.ListEgressRequest{
 				EgressId: id,
 			})
 			if err != nil {
-				return err
+				return nil, err
 			}
 			items = append(items, res.Items...)
 		}
 	} else {
-		res, err := egressClient.ListEgress(context.Background(), &livekit.ListEgressRequest{
+		res, err := egressClient.ListEgress(ctx, &livekit.ListEgressRequest{
 			RoomName: c.String("room"),
 			Active:   c.Bool("active"),
 		})
 		if err != nil {
-			return err
+			return nil, err
 		}
-		items = res.Items
+		items = append(items, res.Items...)
 	}
+	return items, nil
+}
 
+var egressTableHeader = []string{"EgressID", "Status", "Type", "Source", "Started At", "Error"}
+
+func printEgressTable(items []*livekit.EgressInfo) {
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"EgressID", "Status", "Type", "Source", "Started At", "Error"})
+	table.SetHeader(egressTableHeader)
 	for _, item := range items {
-		var startedAt string
-		if item.StartedAt != 0 {
-			startedAt = fmt.Sprint(time.Unix(0, item.StartedAt))
+		table.Append(egressTableRow(item))
+	}
+	table.Render()
+}
+
+func egressTableRow(item *livekit.EgressInfo) []string {
+	var startedAt string
+	if item.StartedAt != 0 {
+		startedAt = fmt.Sprint(time.Unix(0, item.StartedAt))
+	}
+	var egressType, egressSource string
+	switch req := item.Request.(type) {
+	case *livekit.EgressInfo_RoomComposite:
+		egressType = "room_composite"
+		egressSource = req.RoomComposite.RoomName
+	case *livekit.EgressInfo_Web:
+		egressType = "web"
+		egressSource = req.Web.Url
+	case *livekit.EgressInfo_Participant:
+		egressType = "participant"
+		egressSource = fmt.Sprintf("%s/%s", req.Participant.RoomName, req.Participant.Identity)
+	case *livekit.EgressInfo_TrackComposite:
+		egressType = "track_composite"
+		trackIDs := make([]string, 0)
+		if req.TrackComposite.VideoTrackId != "" {
+			trackIDs = append(trackIDs, req.TrackComposite.VideoTrackId)
 		}
-		var egressType, egressSource string
-		switch req := item.Request.(type) {
-		case *livekit.EgressInfo_RoomComposite:
-			egressType = "room_composite"
-			egressSource = req.RoomComposite.RoomName
-		case *livekit.EgressInfo_Web:
-			egressType = "web"
-			egressSource = req.Web.Url
-		case *livekit.EgressInfo_Participant:
-			egressType = "participant"
-			egressSource = fmt.Sprintf("%s/%s", req.Participant.RoomName, req.Participant.Identity)
-		case *livekit.EgressInfo_TrackComposite:
-			egressType = "track_composite"
-			trackIDs := make([]string, 0)
-			if req.TrackComposite.VideoTrackId != "" {
-				trackIDs = append(trackIDs, req.TrackComposite.VideoTrackId)
-			}
-			if req.TrackComposite.AudioTrackId != "" {
-				trackIDs = append(trackIDs, req.TrackComposite.AudioTrackId)
-			}
-			egressSource = fmt.Sprintf("%s/%s", req.TrackComposite.RoomName, strings.Join(trackIDs, ","))
-		case *livekit.EgressInfo_Track:
-			egressType = "track"
-			egressSource = fmt.Sprintf("%s/%s", req.Track.RoomName, req.Track.TrackId)
+		if req.TrackComposite.AudioTrackId != "" {
+			trackIDs = append(trackIDs, req.TrackComposite.AudioTrackId)
 		}
-
-		table.Append([]string{
-			item.EgressId,
-			item.Status.String(),
-			egressType,
-			egressSource,
-			startedAt,
-			item.Error,
-		})
+		egressSource = fmt.Sprintf("%s/%s", req.TrackComposite.RoomName, strings.Join(trackIDs, ","))
+	case *livekit.EgressInfo_Track:
+		egressType = "track"
+		egressSource = fmt.Sprintf("%s/%s", req.Track.RoomName, req.Track.TrackId)
 	}
-	table.Render()
 
-	return nil
+	return []string{
+		item.EgressId,
+		item.Status.String(),
+		egressType,
+		egressSource,
+		startedAt,
+		item.Error,
+	}
 }
 
 func updateLayout(c *cli.Context) error {
@@ -408,8 +442,7 @@ func updateLayout(c *cli.Context) error {
 		return err
 	}
 
-	printInfo(info)
-	return nil
+	return printInfo(info)
 }
 
 func updateStream(c *cli.Context) error {
@@ -422,24 +455,28 @@ func updateStream(c *cli.Context) error {
 		return err
 	}
 
-	printInfo(info)
-	return nil
+	return printInfo(info)
 }
 
 func stopEgress(c *cli.Context) error {
 	ids := c.StringSlice("id")
 	var errors []error
+	infos := make([]*livekit.EgressInfo, 0, len(ids))
 	for _, id := range ids {
-		_, err := egressClient.StopEgress(context.Background(), &livekit.StopEgressRequest{
+		info, err := egressClient.StopEgress(context.Background(), &livekit.StopEgressRequest{
 			EgressId: id,
 		})
 		if err != nil {
 			errors = append(errors, err)
-			fmt.Println("Error stopping Egress", id, err)
+			printStatus("Error stopping Egress", id, err)
 		} else {
-			fmt.Println("Stopping Egress", id)
+			printStatus("Stopping Egress", id)
+			infos = append(infos, info)
 		}
 	}
+	if err := printOutput(infos, func() {}); err != nil {
+		return err
+	}
 	if len(errors) != 0 {
 		return errors[0]
 	}
@@ -511,7 +548,7 @@ func testEgressTemplate(c *cli.Context) error {
 		Testers: testers,
 	})
 	sim.Start()
-	fmt.Println("simulating speakers...")
+	printStatus("simulating speakers...")
 
 	<-done
 
@@ -522,10 +559,12 @@ func testEgressTemplate(c *cli.Context) error {
 	return nil
 }
 
-func printInfo(info *livekit.EgressInfo) {
-	if info.Error == "" {
-		fmt.Printf("EgressID: %v Status: %v\n", info.EgressId, info.Status)
-	} else {
-		fmt.Printf("EgressID: %v Error: %v\n", info.EgressId, info.Error)
-	}
+func printInfo(info *livekit.EgressInfo) error {
+	return printOutput(info, func() {
+		if info.Error == "" {
+			fmt.Printf("EgressID: %v Status: %v\n", info.EgressId, info.Status)
+		} else {
+			fmt.Printf("EgressID: %v Error: %v\n", info.EgressId, info.Error)
+		}
+	})
 }
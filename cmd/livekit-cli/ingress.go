@@ -76,6 +76,8 @@ var (
 					Usage:    "list a specific ingress id",
 					Required: false,
 				},
+				watchFlag,
+				watchIntervalFlag,
 			),
 		},
 		{
@@ -121,7 +123,7 @@ func createIngress(c *cli.Context) error {
 	}
 
 	if c.Bool("verbose") {
-		PrintJSON(req)
+		fprintJSON(os.Stderr, req)
 	}
 
 	info, err := ingressClient.CreateIngress(context.Background(), req)
@@ -129,8 +131,7 @@ func createIngress(c *cli.Context) error {
 		return err
 	}
 
-	printIngressInfo(info)
-	return nil
+	return printIngressInfo(info)
 }
 
 func updateIngress(c *cli.Context) error {
@@ -147,7 +148,7 @@ func updateIngress(c *cli.Context) error {
 	}
 
 	if c.Bool("verbose") {
-		PrintJSON(req)
+		fprintJSON(os.Stderr, req)
 	}
 
 	info, err := ingressClient.UpdateIngress(context.Background(), req)
@@ -155,49 +156,91 @@ func updateIngress(c *cli.Context) error {
 		return err
 	}
 
-	printIngressInfo(info)
-	return nil
+	return printIngressInfo(info)
 }
 
 func listIngress(c *cli.Context) error {
-	res, err := ingressClient.ListIngress(context.Background(), &livekit.ListIngressRequest{
+	if c.Bool("watch") {
+		return watchList(c, "list-ingress", ingressTableHeader, 5, func(ctx context.Context) ([]watchRow, error) {
+			items, err := fetchIngress(ctx, c)
+			if err != nil {
+				return nil, err
+			}
+			rows := make([]watchRow, 0, len(items))
+			for _, item := range items {
+				fields := ingressTableRow(item)
+				rows = append(rows, watchRow{
+					key:    item.IngressId,
+					label:  fmt.Sprintf("%s (%s)", item.IngressId, item.Name),
+					status: fields[5],
+					fields: fields,
+					item:   item,
+				})
+			}
+			return rows, nil
+		})
+	}
+
+	items, err := fetchIngress(context.Background(), c)
+	if err != nil {
+		return err
+	}
+	return printOutput(items, func() {
+		printIngressTable(items)
+		if c.Bool("verbose") {
+			PrintJSON(&livekit.ListIngressResponse{Items: items})
+		}
+	})
+}
+
+func fetchIngress(ctx context.Context, c *cli.Context) ([]*livekit.IngressInfo, error) {
+	res, err := ingressClient.ListIngress(ctx, &livekit.ListIngressRequest{
 		RoomName:  c.String("room"),
 		IngressId: c.String("id"),
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"IngressID", "Name", "Room", "StreamKey", "URL", "Status", "Error"})
+	items := make([]*livekit.IngressInfo, 0, len(res.Items))
 	for _, item := range res.Items {
-		if item == nil {
-			continue
+		if item != nil {
+			items = append(items, item)
 		}
+	}
+	return items, nil
+}
 
-		var status, errorStr string
-		if item.State != nil {
-			status = item.State.Status.String()
-			errorStr = item.State.Error
-		}
+var ingressTableHeader = []string{"IngressID", "Name", "Room", "StreamKey", "URL", "Status", "Error"}
 
-		table.Append([]string{
-			item.IngressId,
-			item.Name,
-			item.RoomName,
-			item.StreamKey,
-			item.Url,
-			status,
-			errorStr,
-		})
+func printIngressTable(items []*livekit.IngressInfo) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader(ingressTableHeader)
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		table.Append(ingressTableRow(item))
 	}
 	table.Render()
+}
 
-	if c.Bool("verbose") {
-		PrintJSON(res)
+func ingressTableRow(item *livekit.IngressInfo) []string {
+	var status, errorStr string
+	if item.State != nil {
+		status = item.State.Status.String()
+		errorStr = item.State.Error
 	}
 
-	return nil
+	return []string{
+		item.IngressId,
+		item.Name,
+		item.RoomName,
+		item.StreamKey,
+		item.Url,
+		status,
+		errorStr,
+	}
 }
 
 func deleteIngress(c *cli.Context) error {
@@ -208,22 +251,23 @@ func deleteIngress(c *cli.Context) error {
 		return err
 	}
 
-	printIngressInfo(info)
-	return nil
+	return printIngressInfo(info)
 }
 
-func printIngressInfo(info *livekit.IngressInfo) {
-	var status, errorStr string
+func printIngressInfo(info *livekit.IngressInfo) error {
+	return printOutput(info, func() {
+		var status, errorStr string
 
-	if info.State != nil {
-		errorStr = info.State.Error
-		status = info.State.Status.String()
-	}
+		if info.State != nil {
+			errorStr = info.State.Error
+			status = info.State.Status.String()
+		}
 
-	if errorStr == "" {
-		fmt.Printf("IngressID: %v Status: %v\n", info.IngressId, status)
-		fmt.Printf("URL: %v Stream Key: %s\n", info.Url, info.StreamKey)
-	} else {
-		fmt.Printf("IngressID: %v Error: %v\n", info.IngressId, errorStr)
-	}
+		if errorStr == "" {
+			fmt.Printf("IngressID: %v Status: %v\n", info.IngressId, status)
+			fmt.Printf("URL: %v Stream Key: %s\n", info.Url, info.StreamKey)
+		} else {
+			fmt.Printf("IngressID: %v Error: %v\n", info.IngressId, errorStr)
+		}
+	})
 }
@@ -29,6 +29,7 @@ import (
 	"github.com/pion/webrtc/v3"
 	"github.com/urfave/cli/v2"
 
+	"github.com/livekit/livekit-cli/pkg/loadtester"
 	provider2 "github.com/livekit/livekit-cli/pkg/provider"
 	"github.com/livekit/protocol/livekit"
 	"github.com/livekit/protocol/logger"
@@ -63,6 +64,10 @@ var (
 					Name:  "exit-after-publish",
 					Usage: "when publishing, exit after file or stream is complete",
 				},
+				&cli.StringFlag{
+					Name:  "impairment",
+					Usage: "simulate a poor network with a profile (2g, 3g, 4g, wifi-lossy, satellite) or custom settings such as \"loss=2;delay=100ms;jitter=20ms;bandwidth=500\"",
+				},
 			),
 		},
 	}
@@ -141,12 +146,24 @@ func joinRoom(c *cli.Context) error {
 			close(done)
 		},
 	}
+	var opts []lksdk.ConnectOption
+	if spec := c.String("impairment"); spec != "" {
+		impairment, err := loadtester.ParseImpairment(spec)
+		if err != nil {
+			return err
+		}
+		interceptors, err := loadtester.ImpairedInterceptors(impairment)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, lksdk.WithInterceptors(interceptors))
+	}
 	room, err := lksdk.ConnectToRoom(pc.URL, lksdk.ConnectInfo{
 		APIKey:              pc.APIKey,
 		APISecret:           pc.APISecret,
 		RoomName:            c.String("room"),
 		ParticipantIdentity: c.String("identity"),
-	}, roomCB)
+	}, roomCB, opts...)
 	if err != nil {
 		return err
 	}
@@ -171,7 +188,7 @@ func joinRoom(c *cli.Context) error {
 					return
 				}
 				if pub != nil {
-					fmt.Printf("finished writing %s\n", pub.Name())
+					printStatusf("finished writing %s\n", pub.Name())
 					_ = room.LocalParticipant.UnpublishTrack(pub.SID())
 				}
 			}
@@ -15,9 +15,14 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -29,72 +34,228 @@ import (
 	lksdk "github.com/livekit/server-sdk-go/v2"
 )
 
+const (
+	// exit code used when a load test fails
+	exitCodeFailed = 1
+	// exit code used when a load test completes but breaches its thresholds
+	exitCodeThresholdsBreached = 2
+)
+
 var LoadTestCommands = []*cli.Command{
 	{
 		Name:     "load-test",
 		Usage:    "Run load tests against LiveKit with simulated publishers & subscribers",
 		Category: "Simulate",
-		Action:   loadTest,
-		Flags: withDefaultFlags(
-			&cli.StringFlag{
-				Name:  "room",
-				Usage: "name of the room (default to random name)",
-			},
-			&cli.DurationFlag{
-				Name:  "duration",
-				Usage: "duration to run, 1m, 1h (by default will run until canceled)",
-				Value: 0,
-			},
-			&cli.IntFlag{
-				Name:    "video-publishers",
-				Aliases: []string{"publishers"},
-				Usage:   "number of participants that would publish video tracks",
-			},
-			&cli.IntFlag{
-				Name:  "audio-publishers",
-				Usage: "number of participants that would publish audio tracks",
-			},
-			&cli.IntFlag{
-				Name:  "subscribers",
-				Usage: "number of participants that would subscribe to tracks",
-			},
-			&cli.StringFlag{
-				Name:  "identity-prefix",
-				Usage: "identity prefix of tester participants (defaults to a random prefix)",
-			},
-			&cli.StringFlag{
-				Name:  "video-resolution",
-				Usage: "resolution of video to publish. valid values are: high, medium, or low",
-				Value: "high",
-			},
-			&cli.StringFlag{
-				Name:  "video-codec",
-				Usage: "h264 or vp8, both will be used when unset",
+		Action:   withLoadTestExit(loadTest),
+		Flags:    withDefaultFlags(loadTestFlags...),
+		Subcommands: []*cli.Command{
+			{
+				Name:   "coordinator",
+				Usage:  "Split a load test between agents, and merge their results",
+				Action: withLoadTestExit(loadTestCoordinator),
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:  "bind",
+						Usage: "address to listen on for agents",
+						Value: ":7890",
+					},
+					&cli.IntFlag{
+						Name:     "agents",
+						Usage:    "number of agents to wait for before starting",
+						Required: true,
+					},
+					&cli.DurationFlag{
+						Name:  "start-delay",
+						Usage: "delay between assigning testers and starting, so that agents start together",
+						Value: 3 * time.Second,
+					},
+				}, loadTestFlags...),
 			},
-			&cli.Float64Flag{
-				Name:  "num-per-second",
-				Usage: "number of testers to start every second",
-				Value: 5,
+			{
+				Name:   "agent",
+				Usage:  "Run the testers assigned by a load test coordinator",
+				Action: withLoadTestExit(loadTestAgent),
+				Flags: withDefaultFlags(
+					&cli.StringFlag{
+						Name:     "coordinator",
+						Usage:    "address of the coordinator, host:port",
+						Required: true,
+					},
+					&cli.IntFlag{
+						Name:  "prometheus-port",
+						Usage: "serve live Prometheus metrics on this port at /metrics while the test is running",
+					},
+				),
 			},
-			&cli.StringFlag{
-				Name:  "layout",
-				Usage: "layout to simulate, choose from speaker, 3x3, 4x4, 5x5",
-				Value: "speaker",
-			},
-			&cli.BoolFlag{
-				Name:  "no-simulcast",
-				Usage: "disables simulcast publishing (simulcast is enabled by default)",
-			},
-			&cli.BoolFlag{
-				Name:  "simulate-speakers",
-				Usage: "fire random speaker events to simulate speaker changes",
-			},
-			&cli.BoolFlag{
-				Name:   "run-all",
-				Usage:  "runs set list of load test cases",
-				Hidden: true,
-			},
-		),
+		},
+	},
+}
+
+var loadTestFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "room",
+		Usage: "name of the room (default to random name)",
+	},
+	&cli.IntFlag{
+		Name:  "rooms",
+		Usage: "number of rooms to spread publishers and subscribers across, named <room>_0, <room>_1...",
+		Value: 1,
+	},
+	&cli.StringFlag{
+		Name:  "room-distribution",
+		Usage: "how participants are spread across rooms: even, random, or skewed (a few large rooms, many small ones)",
+		Value: "even",
+	},
+	&cli.DurationFlag{
+		Name:  "duration",
+		Usage: "duration to run, 1m, 1h (by default will run until canceled)",
+		Value: 0,
+	},
+	&cli.IntFlag{
+		Name:    "video-publishers",
+		Aliases: []string{"publishers"},
+		Usage:   "number of participants that would publish video tracks",
+	},
+	&cli.IntFlag{
+		Name:  "audio-publishers",
+		Usage: "number of participants that would publish audio tracks",
+	},
+	&cli.IntFlag{
+		Name:  "subscribers",
+		Usage: "number of participants that would subscribe to tracks",
+	},
+	&cli.StringFlag{
+		Name:  "identity-prefix",
+		Usage: "identity prefix of tester participants (defaults to a random prefix)",
+	},
+	&cli.StringFlag{
+		Name:  "video-resolution",
+		Usage: "resolution of video to publish. valid values are: high, medium, or low",
+		Value: "high",
+	},
+	&cli.StringFlag{
+		Name:  "video-codec",
+		Usage: "h264 or vp8, both will be used when unset",
+	},
+	&cli.Float64Flag{
+		Name:  "num-per-second",
+		Usage: "number of testers to start every second, on average with --arrival poisson and after the burst with --arrival burst",
+		Value: 5,
+	},
+	&cli.StringFlag{
+		Name:  "arrival",
+		Usage: "how testers join: constant (evenly spaced at --num-per-second), poisson (random gaps averaging --num-per-second), step (--step-size testers every --step-interval) or burst (--burst-size testers at once, then the rest at --num-per-second)",
+		Value: "constant",
+	},
+	&cli.IntFlag{
+		Name:  "step-size",
+		Usage: "number of testers joining at once with --arrival step",
+		Value: 10,
+	},
+	&cli.DurationFlag{
+		Name:  "step-interval",
+		Usage: "time between steps with --arrival step",
+		Value: 5 * time.Second,
+	},
+	&cli.IntFlag{
+		Name:  "burst-size",
+		Usage: "number of testers joining at once with --arrival burst, all of them when unset",
+	},
+	&cli.StringFlag{
+		Name:  "layout",
+		Usage: "layout to simulate, choose from speaker, 3x3, 4x4, 5x5. With --rooms, a comma-separated list is assigned to rooms in turn",
+		Value: "speaker",
+	},
+	&cli.DurationFlag{
+		Name:  "layout-switch-interval",
+		Usage: "make subscribers switch speaker focus or pin another tile this often on average, and report how long the received layer takes to converge",
+	},
+	&cli.BoolFlag{
+		Name:  "no-simulcast",
+		Usage: "disables simulcast publishing (simulcast is enabled by default)",
+	},
+	&cli.BoolFlag{
+		Name:  "simulate-speakers",
+		Usage: "fire random speaker events to simulate speaker changes",
+	},
+	&cli.BoolFlag{
+		Name:  "measure-latency",
+		Usage: "publish synthetic timestamped tracks instead of media, and report end-to-end latency",
+	},
+	&cli.StringFlag{
+		Name:  "report",
+		Usage: "write detailed results to a file, as JSON or CSV depending on the extension",
+	},
+	&cli.StringFlag{
+		Name:  "report-format",
+		Usage: "format of the --report file, json or csv (overrides the file extension)",
+	},
+	&cli.IntFlag{
+		Name:  "prometheus-port",
+		Usage: "serve live Prometheus metrics on this port at /metrics while the test is running",
+	},
+	&cli.StringFlag{
+		Name:  "scenario",
+		Usage: "YAML file describing a sequence of test phases, overrides publisher and subscriber counts",
+	},
+	&cli.StringSliceFlag{
+		Name: "impairment",
+		Usage: "impair the network of a percentage of participants, as `profile:percent`. " +
+			"Profiles are 2g, 3g, 4g, wifi-lossy and satellite, or custom settings such as \"loss=2;delay=100ms;jitter=20ms;bandwidth=500\" " +
+			"(loss in percent, bandwidth in kbps). Can be used multiple times",
+	},
+	&cli.Float64Flag{
+		Name:  "churn",
+		Usage: "fraction of publishers and subscribers (0-1) that disconnect and rejoin, or republish and mute tracks, during the test",
+	},
+	&cli.DurationFlag{
+		Name:  "churn-interval",
+		Usage: "average time between churn events of a participant, also the length of a churn reporting cycle",
+		Value: 30 * time.Second,
+	},
+	&cli.IntFlag{
+		Name:  "data-publishers",
+		Usage: "number of publishers that also send data messages, data-only publishers are added when it exceeds the number of audio and video publishers",
+	},
+	&cli.IntFlag{
+		Name:  "data-size",
+		Usage: "size of data messages in bytes",
+		Value: 256,
+	},
+	&cli.Float64Flag{
+		Name:  "data-rate",
+		Usage: "data messages sent per second by each data publisher, on each topic and in each mode",
+		Value: 10,
+	},
+	&cli.StringSliceFlag{
+		Name:  "data-topic",
+		Usage: "topic of data messages, can be used multiple times. Messages are sent without a topic when unset",
+	},
+	&cli.StringSliceFlag{
+		Name:  "data-mode",
+		Usage: "delivery of data messages, reliable and/or lossy",
+		Value: cli.NewStringSlice(string(loadtester.DataReliable), string(loadtester.DataLossy)),
+	},
+	&cli.Float64Flag{
+		Name:  "max-packet-loss",
+		Usage: "fail if more than this percentage of packets are dropped",
+	},
+	&cli.Float64Flag{
+		Name:  "min-track-ratio",
+		Usage: "fail if subscribers receive less than this ratio of expected tracks (0-1)",
+	},
+	&cli.Int64Flag{
+		Name:  "max-errors",
+		Usage: "fail if more than this number of testers end with an error",
+	},
+	&cli.DurationFlag{
+		Name:  "max-latency",
+		Usage: "fail if p95 latency exceeds this duration, requires --measure-latency",
+	},
+	&cli.BoolFlag{
+		Name:   "run-all",
+		Usage:  "runs set list of load test cases",
+		Hidden: true,
 	},
 }
 
@@ -104,6 +265,130 @@ func loadTest(cCtx *cli.Context) error {
 		return err
 	}
 
+	ctx, cancel := loadTestContext(cCtx)
+	defer cancel()
+
+	params, err := loadTestParams(cCtx)
+	if err != nil {
+		return err
+	}
+	params.URL = pc.URL
+	params.APIKey = pc.APIKey
+	params.APISecret = pc.APISecret
+
+	if scenarioFile := cCtx.String("scenario"); scenarioFile != "" {
+		if params.Rooms > 1 {
+			return errors.New("--rooms cannot be used with --scenario")
+		}
+		if params.Churn.Fraction > 0 {
+			return errors.New("--churn cannot be used with --scenario")
+		}
+		if len(params.Impairments) > 0 {
+			return errors.New("--impairment cannot be used with --scenario")
+		}
+		if params.Arrival.Model != loadtester.ArrivalConstant {
+			return errors.New("--arrival cannot be used with --scenario")
+		}
+		if params.Data.Publishers > 0 {
+			return errors.New("--data-publishers cannot be used with --scenario")
+		}
+		scenario, err := loadtester.LoadScenario(scenarioFile)
+		if err != nil {
+			return err
+		}
+		return runLoadTest(params, func(test *loadtester.LoadTest) error {
+			return test.RunScenario(ctx, scenario)
+		})
+	}
+
+	if cCtx.Bool("run-all") {
+		// leave out room name and pub/sub counts
+		if params.Duration == 0 {
+			params.Duration = time.Second * 15
+		}
+		return runLoadTest(params, func(test *loadtester.LoadTest) error {
+			return test.RunSuite(ctx)
+		})
+	}
+
+	params.VideoPublishers = cCtx.Int("video-publishers")
+	params.AudioPublishers = cCtx.Int("audio-publishers")
+	params.Subscribers = cCtx.Int("subscribers")
+
+	return runLoadTest(params, func(test *loadtester.LoadTest) error {
+		return test.Run(ctx)
+	})
+}
+
+func loadTestCoordinator(cCtx *cli.Context) error {
+	if cCtx.String("scenario") != "" || cCtx.Bool("run-all") {
+		return errors.New("scenarios and test suites cannot be distributed")
+	}
+
+	ctx, cancel := loadTestContext(cCtx)
+	defer cancel()
+
+	params, err := loadTestParams(cCtx)
+	if err != nil {
+		return err
+	}
+	params.VideoPublishers = cCtx.Int("video-publishers")
+	params.AudioPublishers = cCtx.Int("audio-publishers")
+	params.Subscribers = cCtx.Int("subscribers")
+
+	return runLoadTest(params, func(test *loadtester.LoadTest) error {
+		return test.RunCoordinator(ctx, loadtester.CoordinatorParams{
+			Address:    cCtx.String("bind"),
+			Agents:     cCtx.Int("agents"),
+			StartDelay: cCtx.Duration("start-delay"),
+		})
+	})
+}
+
+func loadTestAgent(cCtx *cli.Context) error {
+	pc, err := loadProjectDetails(cCtx)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := loadTestContext(cCtx)
+	defer cancel()
+
+	// the rest of the parameters are assigned by the coordinator
+	params := loadtester.Params{
+		PrometheusPort: cCtx.Int("prometheus-port"),
+		TesterParams: loadtester.TesterParams{
+			URL:       pc.URL,
+			APIKey:    pc.APIKey,
+			APISecret: pc.APISecret,
+		},
+	}
+	return runLoadTest(params, func(test *loadtester.LoadTest) error {
+		return test.RunAgent(ctx, cCtx.String("coordinator"))
+	})
+}
+
+// runLoadTest prints the report to stdout in json and yaml output. Results tables are printed to stderr instead
+func runLoadTest(params loadtester.Params, run func(test *loadtester.LoadTest) error) error {
+	if outputFormat == outputTable {
+		return run(loadtester.NewLoadTest(params))
+	}
+
+	var report bytes.Buffer
+	params.ReportOutput = &report
+	params.Output = os.Stderr
+	err := run(loadtester.NewLoadTest(params))
+
+	if report.Len() > 0 {
+		if outputErr := printOutput(json.RawMessage(report.Bytes()), nil); outputErr != nil && err == nil {
+			err = outputErr
+		}
+	}
+	return err
+}
+
+// loadTestContext prepares the process for running testers, and returns a context canceled on interrupt
+func loadTestContext(cCtx *cli.Context) (context.Context, context.CancelFunc) {
 	if !cCtx.Bool("verbose") {
 		lksdk.SetLogger(logger.LogRLogger(logr.Discard()))
 	}
@@ -116,7 +401,11 @@ func loadTest(cCtx *cli.Context) error {
 		<-done
 		cancel()
 	}()
+	return ctx, cancel
+}
 
+// loadTestParams reads test parameters shared by all modes, without credentials or participant counts
+func loadTestParams(cCtx *cli.Context) (loadtester.Params, error) {
 	params := loadtester.Params{
 		VideoResolution:  cCtx.String("video-resolution"),
 		VideoCodec:       cCtx.String("video-codec"),
@@ -124,29 +413,111 @@ func loadTest(cCtx *cli.Context) error {
 		NumPerSecond:     cCtx.Float64("num-per-second"),
 		Simulcast:        !cCtx.Bool("no-simulcast"),
 		SimulateSpeakers: cCtx.Bool("simulate-speakers"),
+		MeasureLatency:   cCtx.Bool("measure-latency"),
+		ReportFile:       cCtx.String("report"),
+		ReportFormat:     loadtester.ReportFormat(cCtx.String("report-format")),
+		PrometheusPort:   cCtx.Int("prometheus-port"),
+		Thresholds: loadtester.Thresholds{
+			MaxPacketLoss: cCtx.Float64("max-packet-loss"),
+			MinTrackRatio: cCtx.Float64("min-track-ratio"),
+			MaxLatency:    cCtx.Duration("max-latency"),
+		},
 		TesterParams: loadtester.TesterParams{
-			URL:            pc.URL,
-			APIKey:         pc.APIKey,
-			APISecret:      pc.APISecret,
-			Room:           cCtx.String("room"),
-			IdentityPrefix: cCtx.String("identity-prefix"),
-			Layout:         loadtester.LayoutFromString(cCtx.String("layout")),
+			Room:                 cCtx.String("room"),
+			IdentityPrefix:       cCtx.String("identity-prefix"),
+			LayoutSwitchInterval: cCtx.Duration("layout-switch-interval"),
+		},
+		Rooms: cCtx.Int("rooms"),
+		Churn: loadtester.ChurnParams{
+			Fraction: cCtx.Float64("churn"),
+			Interval: cCtx.Duration("churn-interval"),
 		},
 	}
 
-	if cCtx.Bool("run-all") {
-		// leave out room name and pub/sub counts
-		if params.Duration == 0 {
-			params.Duration = time.Second * 15
+	layouts := strings.Split(cCtx.String("layout"), ",")
+	params.Layout = loadtester.LayoutFromString(layouts[0])
+	if len(layouts) > 1 {
+		for _, layout := range layouts {
+			params.Layouts = append(params.Layouts, loadtester.LayoutFromString(strings.TrimSpace(layout)))
+		}
+	}
+	var err error
+	params.Arrival = loadtester.ArrivalParams{
+		StepSize:     cCtx.Int("step-size"),
+		StepInterval: cCtx.Duration("step-interval"),
+		BurstSize:    cCtx.Int("burst-size"),
+	}
+	if params.Arrival.Model, err = loadtester.ArrivalModelFromString(cCtx.String("arrival")); err != nil {
+		return params, err
+	}
+	if params.Arrival.Model == loadtester.ArrivalStep && (params.Arrival.StepSize <= 0 || params.Arrival.StepInterval <= 0) {
+		return params, errors.New("--arrival step requires a positive --step-size and --step-interval")
+	}
+	params.Data = loadtester.DataParams{
+		Publishers: cCtx.Int("data-publishers"),
+		Size:       cCtx.Int("data-size"),
+		Rate:       cCtx.Float64("data-rate"),
+		Topics:     cCtx.StringSlice("data-topic"),
+	}
+	for _, str := range cCtx.StringSlice("data-mode") {
+		mode, err := loadtester.DataModeFromString(strings.TrimSpace(str))
+		if err != nil {
+			return params, err
 		}
-		test := loadtester.NewLoadTest(params)
-		return test.RunSuite(ctx)
+		params.Data.Modes = append(params.Data.Modes, mode)
+	}
+	if params.Data.Publishers > 0 {
+		if params.Data.Rate <= 0 || params.Data.Rate > loadtester.MaxDataRate {
+			return params, fmt.Errorf("--data-rate must be positive and at most %d", loadtester.MaxDataRate)
+		}
+		if params.Data.Size < loadtester.DataHeaderSize {
+			return params, fmt.Errorf("--data-size must be at least %d bytes", loadtester.DataHeaderSize)
+		}
+	}
+	if params.RoomDistribution, err = loadtester.RoomDistributionFromString(cCtx.String("room-distribution")); err != nil {
+		return params, err
 	}
 
-	params.VideoPublishers = cCtx.Int("video-publishers")
-	params.AudioPublishers = cCtx.Int("audio-publishers")
-	params.Subscribers = cCtx.Int("subscribers")
+	if cCtx.IsSet("max-errors") {
+		maxErrors := cCtx.Int64("max-errors")
+		params.Thresholds.MaxErrors = &maxErrors
+	}
+	var impaired float64
+	for _, str := range cCtx.StringSlice("impairment") {
+		assignment, err := loadtester.ParseImpairmentAssignment(str)
+		if err != nil {
+			return params, err
+		}
+		impaired += assignment.Percent
+		params.Impairments = append(params.Impairments, assignment)
+	}
+	if impaired > 100 {
+		return params, errors.New("--impairment percentages add up to more than 100")
+	}
+	if params.Churn.Fraction < 0 || params.Churn.Fraction > 1 {
+		return params, errors.New("--churn must be between 0 and 1")
+	}
+	if params.Churn.Fraction > 0 && params.Churn.Interval <= 0 {
+		return params, errors.New("--churn-interval must be positive")
+	}
+	if params.Thresholds.MaxLatency > 0 && !params.MeasureLatency {
+		return params, errors.New("--max-latency requires --measure-latency")
+	}
+	return params, nil
+}
 
-	test := loadtester.NewLoadTest(params)
-	return test.Run(ctx)
+// withLoadTestExit makes load tests exit with a non-zero code on failure, and gives breached thresholds a distinct one
+func withLoadTestExit(action cli.ActionFunc) cli.ActionFunc {
+	return func(cCtx *cli.Context) error {
+		err := action(cCtx)
+		var thresholdErr *loadtester.ThresholdError
+		switch {
+		case err == nil:
+			return nil
+		case errors.As(err, &thresholdErr):
+			return cli.Exit(err.Error(), exitCodeThresholdsBreached)
+		default:
+			return cli.Exit(err.Error(), exitCodeFailed)
+		}
+	}
 }
@@ -36,6 +36,7 @@ func main() {
 			&cli.BoolFlag{
 				Name: "verbose",
 			},
+			globalOutputFlag,
 		},
 		Commands: []*cli.Command{
 			{
@@ -72,9 +73,11 @@ func main() {
 	app.Commands = append(app.Commands, LoadTestCommands...)
 	app.Commands = append(app.Commands, ProjectCommands...)
 	app.Commands = append(app.Commands, SIPCommands...)
+	app.Commands = append(app.Commands, TopCommands...)
+	withOutputFlag(app.Commands)
 
 	if err := app.Run(os.Args); err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 	}
 }
 
@@ -119,7 +119,7 @@ func addProject(c *cli.Context) error {
 		if err = validateURL(p.URL); err != nil {
 			return err
 		}
-		fmt.Println("URL:", p.URL)
+		printStatus("URL:", p.URL)
 	} else {
 		prompt = promptui.Prompt{
 			Label:    "URL",
@@ -141,7 +141,7 @@ func addProject(c *cli.Context) error {
 		if err = validateKey(p.APIKey); err != nil {
 			return err
 		}
-		fmt.Println("API Key:", p.APIKey)
+		printStatus("API Key:", p.APIKey)
 	} else {
 		prompt = promptui.Prompt{
 			Label:    "API Key",
@@ -157,7 +157,7 @@ func addProject(c *cli.Context) error {
 		if err = validateKey(p.APISecret); err != nil {
 			return err
 		}
-		fmt.Println("API Secret:", p.APISecret)
+		printStatus("API Secret:", p.APISecret)
 	} else {
 		prompt = promptui.Prompt{
 			Label:    "API Secret",
@@ -217,25 +217,48 @@ func addProject(c *cli.Context) error {
 		return err
 	}
 
-	fmt.Println("Added project", p.Name)
+	printStatus("Added project", p.Name)
+	return printOutput(projectOutput{
+		Name:    p.Name,
+		URL:     p.URL,
+		APIKey:  p.APIKey,
+		Default: p.Name == cliConfig.DefaultProject,
+	}, func() {})
+}
 
-	return nil
+// projectOutput is a configured project, without its secret
+type projectOutput struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	APIKey  string `json:"api_key"`
+	Default bool   `json:"default"`
 }
 
 func listProjects(c *cli.Context) error {
-	if len(cliConfig.Projects) == 0 {
-		fmt.Println("No projects configured, use `livekit-cli project add` to add a new project.")
-		return nil
-	}
-
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetAlignment(tablewriter.ALIGN_LEFT)
-	table.SetHeader([]string{"Name", "URL", "API Key", "Default"})
+	projects := make([]projectOutput, 0, len(cliConfig.Projects))
 	for _, p := range cliConfig.Projects {
-		table.Append([]string{p.Name, p.URL, p.APIKey, fmt.Sprint(p.Name == cliConfig.DefaultProject)})
+		projects = append(projects, projectOutput{
+			Name:    p.Name,
+			URL:     p.URL,
+			APIKey:  p.APIKey,
+			Default: p.Name == cliConfig.DefaultProject,
+		})
 	}
-	table.Render()
-	return nil
+
+	return printOutput(projects, func() {
+		if len(projects) == 0 {
+			printStatus("No projects configured, use `livekit-cli project add` to add a new project.")
+			return
+		}
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+		table.SetHeader([]string{"Name", "URL", "API Key", "Default"})
+		for _, p := range projects {
+			table.Append([]string{p.Name, p.URL, p.APIKey, fmt.Sprint(p.Default)})
+		}
+		table.Render()
+	})
 }
 
 func removeProject(c *cli.Context) error {
@@ -262,9 +285,7 @@ func removeProject(c *cli.Context) error {
 		return err
 	}
 
-	fmt.Println("Removed project", name)
-
-	return nil
+	return printResult(&actionResult{Action: "remove-project", Project: name}, "Removed project", name)
 }
 
 func setDefaultProject(c *cli.Context) error {
@@ -280,8 +301,7 @@ func setDefaultProject(c *cli.Context) error {
 			if err := cliConfig.PersistIfNeeded(); err != nil {
 				return err
 			}
-			fmt.Println("Default project set to", name)
-			return nil
+			return printResult(&actionResult{Action: "set-default-project", Project: name}, "Default project set to", name)
 		}
 	}
 
@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/urfave/cli/v2"
 	"google.golang.org/protobuf/encoding/protojson"
@@ -42,30 +43,12 @@ var (
 					Usage:    "name of the room",
 					Required: true,
 				},
-				&cli.StringFlag{
-					Name:  "room-egress-file",
-					Usage: "RoomCompositeRequest json file (see examples/room-composite-file.json)",
-				},
-				&cli.StringFlag{
-					Name:  "participant-egress-file",
-					Usage: "ParticipantEgress json file (see examples/auto-participant-egress.json)",
-				},
-				&cli.StringFlag{
-					Name:  "track-egress-file",
-					Usage: "AutoTrackEgress json file (see examples/auto-track-egress.json)",
-				},
-				&cli.UintFlag{
-					Name:  "min-playout-delay",
-					Usage: "minimum playout delay for video (in ms)",
-				},
-				&cli.UintFlag{
-					Name:  "max-playout-delay",
-					Usage: "maximum playout delay for video (in ms)",
-				},
-				&cli.BoolFlag{
-					Name:  "sync-streams",
-					Usage: "improve A/V sync by placing them in the same stream. when enabled, transceivers will not be reused",
-				},
+				roomEgressFileFlag,
+				participantEgressFileFlag,
+				trackEgressFileFlag,
+				minPlayoutDelayFlag,
+				maxPlayoutDelayFlag,
+				syncStreamsFlag,
 				&cli.UintFlag{
 					Name:  "empty-timeout",
 					Usage: "number of seconds to keep the room open before any participant joins",
@@ -76,12 +59,59 @@ var (
 				},
 			),
 		},
+		{
+			Name:  "export-room",
+			Usage: "Save the configuration and state of a room, with its participants and tracks, to a JSON snapshot",
+			Description: "The server doesn't list the egress, playout delays or sync streams setting a room was created with.\n" +
+				"Pass the same flags as create-room to include them in the snapshot.",
+			Action:   exportRoom,
+			Category: roomCategory,
+			Flags: withDefaultFlags(
+				roomFlag,
+				&cli.StringFlag{
+					Name:  "file",
+					Usage: "file to write the snapshot to, stdout when not set",
+				},
+				roomEgressFileFlag,
+				participantEgressFileFlag,
+				trackEgressFileFlag,
+				minPlayoutDelayFlag,
+				maxPlayoutDelayFlag,
+				syncStreamsFlag,
+			),
+		},
+		{
+			Name:     "import-room",
+			Usage:    "Create a room from a snapshot saved by export-room, in the same or another project",
+			Before:   createRoomClient,
+			Action:   importRoom,
+			Category: roomCategory,
+			Flags: withDefaultFlags(
+				&cli.StringFlag{
+					Name:  "file",
+					Usage: "snapshot to import, stdin when not set",
+				},
+				&cli.StringFlag{
+					Name:  "name",
+					Usage: "name of the new room, the name of the exported room when not set",
+				},
+				roomEgressFileFlag,
+				participantEgressFileFlag,
+				trackEgressFileFlag,
+				minPlayoutDelayFlag,
+				maxPlayoutDelayFlag,
+				syncStreamsFlag,
+			),
+		},
 		{
 			Name:     "list-rooms",
 			Before:   createRoomClient,
 			Action:   listRooms,
 			Category: roomCategory,
-			Flags:    withDefaultFlags(),
+			Flags: withDefaultFlags(
+				watchFlag,
+				watchIntervalFlag,
+			),
 		},
 		{
 			Name:     "list-room",
@@ -101,6 +131,14 @@ var (
 				roomFlag,
 			),
 		},
+		{
+			Name:     "delete-rooms",
+			Usage:    "Delete all rooms matching filters, such as rooms left behind by load tests",
+			Before:   createRoomClient,
+			Action:   deleteRooms,
+			Category: roomCategory,
+			Flags:    bulkRoomFlags(),
+		},
 		{
 			Name:     "update-room-metadata",
 			Before:   createRoomClient,
@@ -113,6 +151,19 @@ var (
 				},
 			),
 		},
+		{
+			Name:     "update-rooms-metadata",
+			Usage:    "Update the metadata of all rooms matching filters",
+			Before:   createRoomClient,
+			Action:   updateRoomsMetadata,
+			Category: roomCategory,
+			Flags: bulkRoomFlags(
+				&cli.StringFlag{
+					Name:     "metadata",
+					Required: true,
+				},
+			),
+		},
 		{
 			Name:     "list-participants",
 			Before:   createRoomClient,
@@ -120,6 +171,8 @@ var (
 			Category: roomCategory,
 			Flags: withDefaultFlags(
 				roomFlag,
+				watchFlag,
+				watchIntervalFlag,
 			),
 		},
 		{
@@ -142,6 +195,19 @@ var (
 				identityFlag,
 			),
 		},
+		{
+			Name:     "remove-participants",
+			Usage:    "Remove participants from all rooms matching filters",
+			Before:   createRoomClient,
+			Action:   removeParticipants,
+			Category: roomCategory,
+			Flags: bulkRoomFlags(
+				&cli.StringSliceFlag{
+					Name:  "identity",
+					Usage: "glob of participant identities to remove, can be used multiple times. all participants when not set",
+				},
+			),
+		},
 		{
 			Name:     "update-participant",
 			Before:   createRoomClient,
@@ -222,6 +288,31 @@ var (
 	}
 
 	roomClient *lksdk.RoomServiceClient
+
+	roomEgressFileFlag = &cli.StringFlag{
+		Name:  "room-egress-file",
+		Usage: "RoomCompositeRequest json file (see examples/room-composite-file.json)",
+	}
+	participantEgressFileFlag = &cli.StringFlag{
+		Name:  "participant-egress-file",
+		Usage: "ParticipantEgress json file (see examples/auto-participant-egress.json)",
+	}
+	trackEgressFileFlag = &cli.StringFlag{
+		Name:  "track-egress-file",
+		Usage: "AutoTrackEgress json file (see examples/auto-track-egress.json)",
+	}
+	minPlayoutDelayFlag = &cli.UintFlag{
+		Name:  "min-playout-delay",
+		Usage: "minimum playout delay for video (in ms)",
+	}
+	maxPlayoutDelayFlag = &cli.UintFlag{
+		Name:  "max-playout-delay",
+		Usage: "maximum playout delay for video (in ms)",
+	}
+	syncStreamsFlag = &cli.BoolFlag{
+		Name:  "sync-streams",
+		Usage: "improve A/V sync by placing them in the same stream. when enabled, transceivers will not be reused",
+	}
 )
 
 func createRoomClient(c *cli.Context) error {
@@ -239,94 +330,135 @@ func createRoom(c *cli.Context) error {
 		Name: c.String("name"),
 	}
 
+	egress, err := roomEgressFromFlags(c)
+	if err != nil {
+		return err
+	}
+	req.Egress = egress
+
+	if c.Uint("min-playout-delay") != 0 {
+		printStatusf("setting min playout delay: %d\n", c.Uint("min-playout-delay"))
+		req.MinPlayoutDelay = uint32(c.Uint("min-playout-delay"))
+	}
+
+	if maxPlayoutDelay := c.Uint("max-playout-delay"); maxPlayoutDelay != 0 {
+		printStatusf("setting max playout delay: %d\n", maxPlayoutDelay)
+		req.MaxPlayoutDelay = uint32(maxPlayoutDelay)
+	}
+
+	if syncStreams := c.Bool("sync-streams"); syncStreams {
+		printStatusf("setting sync streams: %t\n", syncStreams)
+		req.SyncStreams = syncStreams
+	}
+
+	if emptyTimeout := c.Uint("empty-timeout"); emptyTimeout != 0 {
+		printStatusf("setting empty timeout: %d\n", emptyTimeout)
+		req.EmptyTimeout = uint32(emptyTimeout)
+	}
+
+	if departureTimeout := c.Uint("departure-timeout"); departureTimeout != 0 {
+		printStatusf("setting departure timeout: %d\n", departureTimeout)
+		req.DepartureTimeout = uint32(departureTimeout)
+	}
+
+	room, err := roomClient.CreateRoom(context.Background(), req)
+	if err != nil {
+		return err
+	}
+
+	return printOutput(room, nil)
+}
+
+// roomEgressFromFlags returns the egress to start automatically for a new room, nil when there's none
+func roomEgressFromFlags(c *cli.Context) (*livekit.RoomEgress, error) {
+	var egress *livekit.RoomEgress
 	if roomEgressFile := c.String("room-egress-file"); roomEgressFile != "" {
 		roomEgress := &livekit.RoomCompositeEgressRequest{}
 		b, err := os.ReadFile(roomEgressFile)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if err = protojson.Unmarshal(b, roomEgress); err != nil {
-			return err
+			return nil, err
 		}
-		req.Egress = &livekit.RoomEgress{Room: roomEgress}
+		egress = &livekit.RoomEgress{Room: roomEgress}
 	}
 
 	if participantEgressFile := c.String("participant-egress-file"); participantEgressFile != "" {
 		participantEgress := &livekit.AutoParticipantEgress{}
 		b, err := os.ReadFile(participantEgressFile)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if err = protojson.Unmarshal(b, participantEgress); err != nil {
-			return err
+			return nil, err
 		}
-		if req.Egress == nil {
-			req.Egress = &livekit.RoomEgress{}
+		if egress == nil {
+			egress = &livekit.RoomEgress{}
 		}
-		req.Egress.Participant = participantEgress
+		egress.Participant = participantEgress
 	}
 
 	if trackEgressFile := c.String("track-egress-file"); trackEgressFile != "" {
 		trackEgress := &livekit.AutoTrackEgress{}
 		b, err := os.ReadFile(trackEgressFile)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if err = protojson.Unmarshal(b, trackEgress); err != nil {
-			return err
+			return nil, err
 		}
-		if req.Egress == nil {
-			req.Egress = &livekit.RoomEgress{}
+		if egress == nil {
+			egress = &livekit.RoomEgress{}
 		}
-		req.Egress.Tracks = trackEgress
-	}
-
-	if c.Uint("min-playout-delay") != 0 {
-		fmt.Printf("setting min playout delay: %d\n", c.Uint("min-playout-delay"))
-		req.MinPlayoutDelay = uint32(c.Uint("min-playout-delay"))
-	}
-
-	if maxPlayoutDelay := c.Uint("max-playout-delay"); maxPlayoutDelay != 0 {
-		fmt.Printf("setting max playout delay: %d\n", maxPlayoutDelay)
-		req.MaxPlayoutDelay = uint32(maxPlayoutDelay)
-	}
-
-	if syncStreams := c.Bool("sync-streams"); syncStreams {
-		fmt.Printf("setting sync streams: %t\n", syncStreams)
-		req.SyncStreams = syncStreams
-	}
-
-	if emptyTimeout := c.Uint("empty-timeout"); emptyTimeout != 0 {
-		fmt.Printf("setting empty timeout: %d\n", emptyTimeout)
-		req.EmptyTimeout = uint32(emptyTimeout)
-	}
-
-	if departureTimeout := c.Uint("departure-timeout"); departureTimeout != 0 {
-		fmt.Printf("setting departure timeout: %d\n", departureTimeout)
-		req.DepartureTimeout = uint32(departureTimeout)
+		egress.Tracks = trackEgress
 	}
-
-	room, err := roomClient.CreateRoom(context.Background(), req)
-	if err != nil {
-		return err
-	}
-
-	PrintJSON(room)
-	return nil
+	return egress, nil
 }
 
 func listRooms(c *cli.Context) error {
+	if c.Bool("watch") {
+		header := []string{"Sid", "Name", "Participants", "Publishers", "Created At"}
+		return watchList(c, "list-rooms", header, -1, func(ctx context.Context) ([]watchRow, error) {
+			res, err := roomClient.ListRooms(ctx, &livekit.ListRoomsRequest{})
+			if err != nil {
+				return nil, err
+			}
+			rows := make([]watchRow, 0, len(res.Rooms))
+			for _, rm := range res.Rooms {
+				rows = append(rows, watchRow{
+					key:   rm.Sid,
+					label: rm.Name,
+					fields: []string{
+						rm.Sid,
+						rm.Name,
+						fmt.Sprint(rm.NumParticipants),
+						fmt.Sprint(rm.NumPublishers),
+						fmt.Sprint(time.Unix(rm.CreationTime, 0)),
+					},
+					item: rm,
+				})
+			}
+			return rows, nil
+		})
+	}
+
 	res, err := roomClient.ListRooms(context.Background(), &livekit.ListRoomsRequest{})
 	if err != nil {
 		return err
 	}
-	if len(res.Rooms) == 0 {
-		fmt.Println("there are no active rooms")
+	rooms := res.Rooms
+	if rooms == nil {
+		rooms = []*livekit.Room{}
 	}
-	for _, rm := range res.Rooms {
-		fmt.Printf("%s\t%s\t%d participants\n", rm.Sid, rm.Name, rm.NumParticipants)
-	}
-	return nil
+	return printOutput(rooms, func() {
+		if len(rooms) == 0 {
+			printStatus("there are no active rooms")
+		}
+		for _, rm := range rooms {
+			fmt.Printf("%s\t%s\t%d participants\n", rm.Sid, rm.Name, rm.NumParticipants)
+		}
+	})
 }
 
 func listRoom(c *cli.Context) error {
@@ -337,12 +469,11 @@ func listRoom(c *cli.Context) error {
 		return err
 	}
 	if len(res.Rooms) == 0 {
-		fmt.Printf("there is no matching room with name: %s\n", c.String("room"))
+		printStatusf("there is no matching room with name: %s\n", c.String("room"))
 		return nil
 	}
 	rm := res.Rooms[0]
-	PrintJSON(rm)
-	return nil
+	return printOutput(rm, nil)
 }
 
 func deleteRoom(c *cli.Context) error {
@@ -354,8 +485,7 @@ func deleteRoom(c *cli.Context) error {
 		return err
 	}
 
-	fmt.Println("deleted room", roomId)
-	return nil
+	return printResult(&actionResult{Action: "delete-room", Room: roomId}, "deleted room", roomId)
 }
 
 func updateRoomMetadata(c *cli.Context) error {
@@ -368,13 +498,41 @@ func updateRoomMetadata(c *cli.Context) error {
 		return err
 	}
 
-	fmt.Println("Updated room metadata")
-	PrintJSON(res)
-	return nil
+	printStatus("Updated room metadata")
+	return printOutput(res, nil)
 }
 
 func listParticipants(c *cli.Context) error {
 	roomName := c.String("room")
+	if c.Bool("watch") {
+		header := []string{"Identity", "Name", "State", "Tracks", "Joined At"}
+		return watchList(c, "list-participants "+roomName, header, 2, func(ctx context.Context) ([]watchRow, error) {
+			res, err := roomClient.ListParticipants(ctx, &livekit.ListParticipantsRequest{
+				Room: roomName,
+			})
+			if err != nil {
+				return nil, err
+			}
+			rows := make([]watchRow, 0, len(res.Participants))
+			for _, p := range res.Participants {
+				rows = append(rows, watchRow{
+					key:    p.Identity,
+					label:  p.Identity,
+					status: p.State.String(),
+					fields: []string{
+						p.Identity,
+						p.Name,
+						p.State.String(),
+						fmt.Sprint(len(p.Tracks)),
+						fmt.Sprint(time.Unix(p.JoinedAt, 0)),
+					},
+					item: p,
+				})
+			}
+			return rows, nil
+		})
+	}
+
 	res, err := roomClient.ListParticipants(context.Background(), &livekit.ListParticipantsRequest{
 		Room: roomName,
 	})
@@ -382,10 +540,15 @@ func listParticipants(c *cli.Context) error {
 		return err
 	}
 
-	for _, p := range res.Participants {
-		fmt.Printf("%s (%s)\t tracks: %d\n", p.Identity, p.State.String(), len(p.Tracks))
+	participants := res.Participants
+	if participants == nil {
+		participants = []*livekit.ParticipantInfo{}
 	}
-	return nil
+	return printOutput(participants, func() {
+		for _, p := range participants {
+			fmt.Printf("%s (%s)\t tracks: %d\n", p.Identity, p.State.String(), len(p.Tracks))
+		}
+	})
 }
 
 func getParticipant(c *cli.Context) error {
@@ -398,9 +561,7 @@ func getParticipant(c *cli.Context) error {
 		return err
 	}
 
-	PrintJSON(res)
-
-	return nil
+	return printOutput(res, nil)
 }
 
 func updateParticipant(c *cli.Context) error {
@@ -434,14 +595,15 @@ func updateParticipant(c *cli.Context) error {
 		}
 	}
 
-	fmt.Println("updating participant...")
-	PrintJSON(req)
-	if _, err := roomClient.UpdateParticipant(c.Context, req); err != nil {
+	printStatus("updating participant...")
+	fprintJSON(os.Stderr, req)
+	res, err := roomClient.UpdateParticipant(c.Context, req)
+	if err != nil {
 		return err
 	}
-	fmt.Println("participant updated.")
+	printStatus("participant updated.")
 
-	return nil
+	return printOutput(res, func() {})
 }
 
 func removeParticipant(c *cli.Context) error {
@@ -454,15 +616,14 @@ func removeParticipant(c *cli.Context) error {
 		return err
 	}
 
-	fmt.Println("successfully removed participant", identity)
-
-	return nil
+	return printResult(&actionResult{Action: "remove-participant", Room: roomName, Identity: identity},
+		"successfully removed participant", identity)
 }
 
 func muteTrack(c *cli.Context) error {
 	roomName, identity := participantInfoFromCli(c)
 	trackSid := c.String("track")
-	_, err := roomClient.MutePublishedTrack(context.Background(), &livekit.MuteRoomTrackRequest{
+	res, err := roomClient.MutePublishedTrack(context.Background(), &livekit.MuteRoomTrackRequest{
 		Room:     roomName,
 		Identity: identity,
 		TrackSid: trackSid,
@@ -476,8 +637,8 @@ func muteTrack(c *cli.Context) error {
 	if !c.Bool("muted") {
 		verb = "unmuted"
 	}
-	fmt.Println(verb, "track: ", trackSid)
-	return nil
+	printStatus(verb, "track: ", trackSid)
+	return printOutput(res.Track, func() {})
 }
 
 func updateSubscriptions(c *cli.Context) error {
@@ -493,12 +654,12 @@ func updateSubscriptions(c *cli.Context) error {
 		return err
 	}
 
-	verb := "subscribed to"
+	action, verb := "subscribe", "subscribed to"
 	if !c.Bool("subscribe") {
-		verb = "unsubscribed from"
+		action, verb = "unsubscribe", "unsubscribed from"
 	}
-	fmt.Println(verb, "tracks: ", trackSids)
-	return nil
+	return printResult(&actionResult{Action: action, Room: roomName, Identity: identity, TrackSids: trackSids},
+		verb, "tracks: ", trackSids)
 }
 
 func sendData(c *cli.Context) error {
@@ -519,8 +680,7 @@ func sendData(c *cli.Context) error {
 		return err
 	}
 
-	fmt.Println("successfully sent data to room", roomName)
-	return nil
+	return printResult(&actionResult{Action: "send-data", Room: roomName}, "successfully sent data to room", roomName)
 }
 
 func participantInfoFromCli(c *cli.Context) (string, string) {
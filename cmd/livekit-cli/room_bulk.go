@@ -0,0 +1,333 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/manifoldco/promptui"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/term"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+// bulkRoomFlags select rooms by name and age, and control how the operation is applied
+func bulkRoomFlags(flags ...cli.Flag) []cli.Flag {
+	return withDefaultFlags(append(flags,
+		&cli.StringSliceFlag{
+			Name:  "match",
+			Usage: "glob of room names, such as 'testroom*', can be used multiple times",
+		},
+		&cli.StringFlag{
+			Name:  "regex",
+			Usage: "regular expression room names must match",
+		},
+		&cli.DurationFlag{
+			Name:  "unchanged-for",
+			Usage: "only rooms without participants, that haven't changed for at least this long. Rooms don't record when their last participant left, only when they last changed",
+		},
+		&cli.DurationFlag{
+			Name:  "older-than",
+			Usage: "only rooms created at least this long ago",
+		},
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "list what would be changed, without changing anything",
+		},
+		&cli.BoolFlag{
+			Name:    "yes",
+			Aliases: []string{"y"},
+			Usage:   "don't ask for confirmation",
+		},
+		&cli.IntFlag{
+			Name:  "concurrency",
+			Usage: "number of requests in flight at once",
+			Value: 4,
+		},
+	)...)
+}
+
+// roomFilter matches rooms by all of its criteria that are set
+type roomFilter struct {
+	globs        []string
+	re           *regexp.Regexp
+	unchangedFor time.Duration
+	olderThan    time.Duration
+}
+
+func roomFilterFromFlags(c *cli.Context) (*roomFilter, error) {
+	f := &roomFilter{
+		globs:        c.StringSlice("match"),
+		unchangedFor: c.Duration("unchanged-for"),
+		olderThan:    c.Duration("older-than"),
+	}
+	for _, glob := range f.globs {
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid match %q: %w", glob, err)
+		}
+	}
+	if expr := c.String("regex"); expr != "" {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+		f.re = re
+	}
+	if len(f.globs) == 0 && f.re == nil && f.unchangedFor == 0 && f.olderThan == 0 {
+		// so that a missing flag doesn't select every room, --match '*' does
+		return nil, errors.New("at least one of --match, --regex, --unchanged-for or --older-than is required")
+	}
+	return f, nil
+}
+
+func (f *roomFilter) matches(rm *livekit.Room, now time.Time) bool {
+	if !matchesAny(f.globs, rm.Name) {
+		return false
+	}
+	if f.re != nil && !f.re.MatchString(rm.Name) {
+		return false
+	}
+	createdAt := time.Unix(rm.CreationTime, 0)
+	if f.olderThan > 0 && now.Sub(createdAt) < f.olderThan {
+		return false
+	}
+	if f.unchangedFor > 0 {
+		if rm.NumParticipants > 0 {
+			return false
+		}
+		// rooms don't record when the last participant left, the version is bumped when the room changes
+		changedAt := createdAt
+		if v := rm.Version; v != nil && v.UnixMicro > 0 {
+			changedAt = time.UnixMicro(v.UnixMicro)
+		}
+		if now.Sub(changedAt) < f.unchangedFor {
+			return false
+		}
+	}
+	return true
+}
+
+func matchingRooms(ctx context.Context, c *cli.Context) ([]*livekit.Room, error) {
+	f, err := roomFilterFromFlags(c)
+	if err != nil {
+		return nil, err
+	}
+	res, err := roomClient.ListRooms(ctx, &livekit.ListRoomsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var rooms []*livekit.Room
+	for _, rm := range res.Rooms {
+		if f.matches(rm, now) {
+			rooms = append(rooms, rm)
+		}
+	}
+	slices.SortFunc(rooms, func(a, b *livekit.Room) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return rooms, nil
+}
+
+// bulkTarget is a room, or a participant in a room, that a bulk operation applies to
+type bulkTarget struct {
+	Room     string `json:"room"`
+	Sid      string `json:"sid,omitempty"`
+	Identity string `json:"identity,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func roomTargets(rooms []*livekit.Room) []*bulkTarget {
+	targets := make([]*bulkTarget, 0, len(rooms))
+	for _, rm := range rooms {
+		targets = append(targets, &bulkTarget{Room: rm.Name, Sid: rm.Sid})
+	}
+	return targets
+}
+
+// runBulk lists the targets, asks for confirmation and applies op to each of them, at most --concurrency at a time.
+// All targets are attempted, the error reports how many failed
+func runBulk(c *cli.Context, action string, targets []*bulkTarget, op func(ctx context.Context, t *bulkTarget) error) error {
+	if len(targets) == 0 {
+		printStatus("no matching rooms")
+		return printOutput(targets, func() {})
+	}
+	if c.Bool("dry-run") {
+		printStatusf("would %s:\n", action)
+		return printOutput(targets, func() {
+			printBulkTable(targets)
+		})
+	}
+	concurrency := c.Int("concurrency")
+	if concurrency < 1 {
+		return errors.New("concurrency must be at least 1")
+	}
+
+	if !c.Bool("yes") {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return errors.New("not asking for confirmation without a terminal, use --yes")
+		}
+		printStatusf("about to %s:\n", action)
+		for _, t := range targets {
+			if t.Identity != "" {
+				printStatusf("  %s in %s\n", t.Identity, t.Room)
+			} else {
+				printStatusf("  %s (%s)\n", t.Room, t.Sid)
+			}
+		}
+		prompt := promptui.Prompt{
+			Label:     fmt.Sprintf("%s (%d)", action, len(targets)),
+			IsConfirm: true,
+			Stdout:    os.Stderr,
+		}
+		if _, err := prompt.Run(); err != nil {
+			// a declined confirmation is reported as an error too
+			return errors.New("cancelled")
+		}
+	}
+
+	var g errgroup.Group
+	g.SetLimit(concurrency)
+	var lock sync.Mutex
+	failed := 0
+	for _, t := range targets {
+		g.Go(func() error {
+			ctx, cancel := context.WithTimeout(c.Context, 30*time.Second)
+			defer cancel()
+			if err := op(ctx, t); err != nil {
+				lock.Lock()
+				t.Error = err.Error()
+				failed++
+				lock.Unlock()
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	if err := printOutput(targets, func() {
+		printBulkTable(targets)
+	}); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to %s: %d of %d", action, failed, len(targets))
+	}
+	return nil
+}
+
+func printBulkTable(targets []*bulkTarget) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Room", "Sid", "Identity", "Error"})
+	for _, t := range targets {
+		table.Append([]string{t.Room, t.Sid, t.Identity, t.Error})
+	}
+	table.Render()
+}
+
+func deleteRooms(c *cli.Context) error {
+	rooms, err := matchingRooms(c.Context, c)
+	if err != nil {
+		return err
+	}
+	recheck := c.Duration("unchanged-for") > 0
+	return runBulk(c, "delete rooms", roomTargets(rooms), func(ctx context.Context, t *bulkTarget) error {
+		if recheck {
+			// someone may have joined since the rooms were listed and the confirmation was given
+			res, err := roomClient.ListRooms(ctx, &livekit.ListRoomsRequest{Names: []string{t.Room}})
+			if err != nil {
+				return err
+			}
+			if len(res.Rooms) == 1 && res.Rooms[0].NumParticipants > 0 {
+				return errors.New("not deleted, it has participants again")
+			}
+		}
+		_, err := roomClient.DeleteRoom(ctx, &livekit.DeleteRoomRequest{Room: t.Room})
+		return err
+	})
+}
+
+func updateRoomsMetadata(c *cli.Context) error {
+	rooms, err := matchingRooms(c.Context, c)
+	if err != nil {
+		return err
+	}
+	metadata := c.String("metadata")
+	return runBulk(c, "update the metadata of rooms", roomTargets(rooms), func(ctx context.Context, t *bulkTarget) error {
+		_, err := roomClient.UpdateRoomMetadata(ctx, &livekit.UpdateRoomMetadataRequest{
+			Room:     t.Room,
+			Metadata: metadata,
+		})
+		return err
+	})
+}
+
+func removeParticipants(c *cli.Context) error {
+	globs := c.StringSlice("identity")
+	for _, glob := range globs {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid identity %q: %w", glob, err)
+		}
+	}
+	rooms, err := matchingRooms(c.Context, c)
+	if err != nil {
+		return err
+	}
+
+	targets := []*bulkTarget{}
+	for _, rm := range rooms {
+		res, err := roomClient.ListParticipants(c.Context, &livekit.ListParticipantsRequest{Room: rm.Name})
+		if err != nil {
+			return fmt.Errorf("could not list participants of %s: %w", rm.Name, err)
+		}
+		for _, p := range res.Participants {
+			if matchesAny(globs, p.Identity) {
+				targets = append(targets, &bulkTarget{Room: rm.Name, Sid: p.Sid, Identity: p.Identity})
+			}
+		}
+	}
+	return runBulk(c, "remove participants", targets, func(ctx context.Context, t *bulkTarget) error {
+		_, err := roomClient.RemoveParticipant(ctx, &livekit.RoomParticipantIdentity{
+			Room:     t.Room,
+			Identity: t.Identity,
+		})
+		return err
+	})
+}
+
+// matchesAny returns true when s matches one of globs, or there are none
+func matchesAny(globs []string, s string) bool {
+	if len(globs) == 0 {
+		return true
+	}
+	for _, glob := range globs {
+		if ok, _ := path.Match(glob, s); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,249 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/protocol/livekit"
+	lksdk "github.com/livekit/server-sdk-go/v2"
+)
+
+// roomSnapshot is the file written by export-room. Messages are in protojson, with the field names of the protocol
+type roomSnapshot struct {
+	ExportedAt time.Time `json:"exported_at"`
+	// URL of the project the room was exported from
+	URL string `json:"url"`
+	// recreates the room. The server doesn't list egress, playout delays and sync streams, they're only included when
+	// passed to export-room
+	CreateRequest json.RawMessage `json:"create_request"`
+	// the room and its participants as listed when exported, without the TURN password
+	Room         json.RawMessage   `json:"room"`
+	Participants []json.RawMessage `json:"participants"`
+}
+
+var snapshotMarshal = protojson.MarshalOptions{UseProtoNames: true}
+
+// newRoomSnapshot saves a room, with the settings the server doesn't list taken from options
+func newRoomSnapshot(url string, room *livekit.Room, participants []*livekit.ParticipantInfo, options *livekit.CreateRoomRequest) (*roomSnapshot, error) {
+	s := &roomSnapshot{
+		ExportedAt:   time.Now().UTC(),
+		URL:          url,
+		Participants: []json.RawMessage{},
+	}
+	var err error
+	s.CreateRequest, err = snapshotMarshal.Marshal(&livekit.CreateRoomRequest{
+		Name:             room.Name,
+		EmptyTimeout:     room.EmptyTimeout,
+		DepartureTimeout: room.DepartureTimeout,
+		MaxParticipants:  room.MaxParticipants,
+		Metadata:         room.Metadata,
+		Egress:           options.Egress,
+		MinPlayoutDelay:  options.MinPlayoutDelay,
+		MaxPlayoutDelay:  options.MaxPlayoutDelay,
+		SyncStreams:      options.SyncStreams,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// snapshots are shared between projects, credentials stay out of them
+	room = proto.Clone(room).(*livekit.Room)
+	room.TurnPassword = ""
+	if s.Room, err = snapshotMarshal.Marshal(room); err != nil {
+		return nil, err
+	}
+	for _, p := range participants {
+		b, err := snapshotMarshal.Marshal(p)
+		if err != nil {
+			return nil, err
+		}
+		s.Participants = append(s.Participants, b)
+	}
+	return s, nil
+}
+
+func (s *roomSnapshot) createRoomRequest() (*livekit.CreateRoomRequest, error) {
+	req := &livekit.CreateRoomRequest{}
+	if err := unmarshalSnapshotField(s.CreateRequest, req); err != nil {
+		return nil, fmt.Errorf("invalid create_request: %w", err)
+	}
+	if req.Name == "" {
+		return nil, errors.New("invalid create_request: room name is missing")
+	}
+	return req, nil
+}
+
+func (s *roomSnapshot) participants() ([]*livekit.ParticipantInfo, error) {
+	var participants []*livekit.ParticipantInfo
+	for _, b := range s.Participants {
+		p := &livekit.ParticipantInfo{}
+		if err := unmarshalSnapshotField(b, p); err != nil {
+			return nil, fmt.Errorf("invalid participant: %w", err)
+		}
+		participants = append(participants, p)
+	}
+	return participants, nil
+}
+
+// roomOptionsFromFlags reads the room settings that the server doesn't list from the flags shared with create-room
+func roomOptionsFromFlags(c *cli.Context) (*livekit.CreateRoomRequest, error) {
+	egress, err := roomEgressFromFlags(c)
+	if err != nil {
+		return nil, err
+	}
+	return &livekit.CreateRoomRequest{
+		Egress:          egress,
+		MinPlayoutDelay: uint32(c.Uint(minPlayoutDelayFlag.Name)),
+		MaxPlayoutDelay: uint32(c.Uint(maxPlayoutDelayFlag.Name)),
+		SyncStreams:     c.Bool(syncStreamsFlag.Name),
+	}, nil
+}
+
+func unmarshalSnapshotField(b json.RawMessage, m proto.Message) error {
+	if len(b) == 0 {
+		return errors.New("missing")
+	}
+	// snapshots from newer versions may have fields this one doesn't know about
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(b, m)
+}
+
+func exportRoom(c *cli.Context) error {
+	// the snapshot records the URL of the project, so the client is created here rather than in Before
+	pc, err := loadProjectDetails(c)
+	if err != nil {
+		return err
+	}
+	roomClient = lksdk.NewRoomServiceClient(pc.URL, pc.APIKey, pc.APISecret, withDefaultClientOpts(pc)...)
+	options, err := roomOptionsFromFlags(c)
+	if err != nil {
+		return err
+	}
+
+	roomName := c.String("room")
+	res, err := roomClient.ListRooms(c.Context, &livekit.ListRoomsRequest{
+		Names: []string{roomName},
+	})
+	if err != nil {
+		return err
+	}
+	if len(res.Rooms) == 0 {
+		return fmt.Errorf("there is no matching room with name: %s", roomName)
+	}
+	participants, err := roomClient.ListParticipants(c.Context, &livekit.ListParticipantsRequest{
+		Room: roomName,
+	})
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := newRoomSnapshot(pc.URL, res.Rooms[0], participants.Participants, options)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	if file := c.String("file"); file != "" {
+		if err = os.WriteFile(file, b, 0600); err != nil {
+			return err
+		}
+		printStatusf("exported room %s with %d participants to %s\n", roomName, len(snapshot.Participants), file)
+		return nil
+	}
+	_, err = os.Stdout.Write(b)
+	return err
+}
+
+func importRoom(c *cli.Context) error {
+	var b []byte
+	var err error
+	if file := c.String("file"); file != "" && file != "-" {
+		b, err = os.ReadFile(file)
+	} else {
+		b, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return err
+	}
+	snapshot := &roomSnapshot{}
+	if err = json.Unmarshal(b, snapshot); err != nil {
+		return fmt.Errorf("invalid snapshot: %w", err)
+	}
+	req, err := snapshot.createRoomRequest()
+	if err != nil {
+		return err
+	}
+	participants, err := snapshot.participants()
+	if err != nil {
+		return err
+	}
+
+	if name := c.String("name"); name != "" {
+		req.Name = name
+	}
+	options, err := roomOptionsFromFlags(c)
+	if err != nil {
+		return err
+	}
+	if options.Egress != nil {
+		req.Egress = options.Egress
+	}
+	if c.IsSet(minPlayoutDelayFlag.Name) {
+		req.MinPlayoutDelay = options.MinPlayoutDelay
+	}
+	if c.IsSet(maxPlayoutDelayFlag.Name) {
+		req.MaxPlayoutDelay = options.MaxPlayoutDelay
+	}
+	if c.IsSet(syncStreamsFlag.Name) {
+		req.SyncStreams = options.SyncStreams
+	}
+
+	room, err := roomClient.CreateRoom(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	// CreateRoom returns rooms that already exist as they are, so metadata is set separately
+	if req.Metadata != "" && room.Metadata != req.Metadata {
+		room, err = roomClient.UpdateRoomMetadata(context.Background(), &livekit.UpdateRoomMetadataRequest{
+			Room:     req.Name,
+			Metadata: req.Metadata,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	printStatusf("imported room %s, exported from %s at %s\n", room.Name, snapshot.URL, snapshot.ExportedAt.Format(time.RFC3339))
+	if len(participants) > 0 {
+		printStatusf("participants can't be restored, they have to join again (%d):\n", len(participants))
+		for _, p := range participants {
+			printStatusf("  %s (%d tracks)\n", p.Identity, len(p.Tracks))
+		}
+	}
+	return printOutput(room, nil)
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+func TestRoomFilter(t *testing.T) {
+	now := time.Now()
+	rooms := []*livekit.Room{
+		{Name: "testroom001", CreationTime: now.Add(-2 * time.Hour).Unix()},
+		{Name: "testroom002", CreationTime: now.Add(-2 * time.Hour).Unix(), NumParticipants: 3},
+		// emptied a minute ago
+		{Name: "testroom003", CreationTime: now.Add(-2 * time.Hour).Unix(), Version: &livekit.TimedVersion{UnixMicro: now.Add(-time.Minute).UnixMicro()}},
+		{Name: "standup", CreationTime: now.Add(-5 * time.Minute).Unix()},
+	}
+	names := func(f *roomFilter) []string {
+		var matched []string
+		for _, rm := range rooms {
+			if f.matches(rm, now) {
+				matched = append(matched, rm.Name)
+			}
+		}
+		return matched
+	}
+
+	require.Equal(t, []string{"testroom001", "testroom002", "testroom003"}, names(&roomFilter{globs: []string{"testroom*"}}))
+	require.Equal(t, []string{"testroom002", "standup"}, names(&roomFilter{globs: []string{"*2", "stand?p"}}))
+	require.Equal(t, []string{"testroom001", "testroom002", "testroom003"}, names(&roomFilter{re: regexp.MustCompile(`^testroom\d+$`)}))
+	require.Equal(t, []string{"testroom001", "testroom002", "testroom003"}, names(&roomFilter{olderThan: time.Hour}))
+	require.Equal(t, []string{"testroom001", "standup"}, names(&roomFilter{unchangedFor: 2 * time.Minute}))
+	require.Equal(t, []string{"testroom001"}, names(&roomFilter{globs: []string{"testroom*"}, unchangedFor: 2 * time.Minute}))
+}
+
+func TestRoomSnapshot(t *testing.T) {
+	room := &livekit.Room{
+		Sid:              "RM_1",
+		Name:             "support-1234",
+		EmptyTimeout:     300,
+		DepartureTimeout: 20,
+		MaxParticipants:  10,
+		Metadata:         `{"ticket":1234}`,
+		NumParticipants:  1,
+		TurnPassword:     "secret",
+	}
+	participants := []*livekit.ParticipantInfo{{
+		Identity:   "customer",
+		Metadata:   "vip",
+		Permission: &livekit.ParticipantPermission{CanSubscribe: true, CanPublishSources: []livekit.TrackSource{livekit.TrackSource_MICROPHONE}},
+		Tracks:     []*livekit.TrackInfo{{Sid: "TR_1", Type: livekit.TrackType_AUDIO, Source: livekit.TrackSource_MICROPHONE}},
+	}}
+	egress := &livekit.RoomEgress{Tracks: &livekit.AutoTrackEgress{Filepath: "{room_name}/{track_id}"}}
+
+	s, err := newRoomSnapshot("wss://a.livekit.cloud", room, participants, &livekit.CreateRoomRequest{Egress: egress, MinPlayoutDelay: 100})
+	require.NoError(t, err)
+	b, err := json.Marshal(s)
+	require.NoError(t, err)
+	require.Contains(t, string(b), `"can_publish_sources":["MICROPHONE"]`)
+	require.NotContains(t, string(b), "secret")
+	require.Equal(t, "secret", room.TurnPassword)
+
+	restored := &roomSnapshot{}
+	require.NoError(t, json.Unmarshal(b, restored))
+	req, err := restored.createRoomRequest()
+	require.NoError(t, err)
+	require.Equal(t, "support-1234", req.Name)
+	require.Equal(t, uint32(300), req.EmptyTimeout)
+	require.Equal(t, uint32(20), req.DepartureTimeout)
+	require.Equal(t, uint32(10), req.MaxParticipants)
+	require.Equal(t, `{"ticket":1234}`, req.Metadata)
+	require.Equal(t, "{room_name}/{track_id}", req.Egress.Tracks.Filepath)
+	require.Equal(t, uint32(100), req.MinPlayoutDelay)
+
+	restoredParticipants, err := restored.participants()
+	require.NoError(t, err)
+	require.Len(t, restoredParticipants, 1)
+	require.Equal(t, "customer", restoredParticipants[0].Identity)
+	require.Equal(t, []livekit.TrackSource{livekit.TrackSource_MICROPHONE}, restoredParticipants[0].Permission.CanPublishSources)
+	require.Equal(t, "TR_1", restoredParticipants[0].Tracks[0].Sid)
+
+	// fields added by newer versions are ignored
+	_, err = (&roomSnapshot{CreateRequest: json.RawMessage(`{"name":"a","new_field":1}`)}).createRoomRequest()
+	require.NoError(t, err)
+	_, err = (&roomSnapshot{}).createRoomRequest()
+	require.EqualError(t, err, "invalid create_request: missing")
+}
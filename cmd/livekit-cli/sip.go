@@ -25,6 +25,7 @@ import (
 	"github.com/olekukonko/tablewriter"
 	"github.com/urfave/cli/v2"
 	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/livekit/protocol/livekit"
 	lksdk "github.com/livekit/server-sdk-go/v2"
@@ -151,7 +152,7 @@ func createSIPTrunk(c *cli.Context) error {
 	}
 
 	if c.Bool("verbose") {
-		PrintJSON(req)
+		fprintJSON(os.Stderr, req)
 	}
 
 	info, err := sipClient.CreateSIPTrunk(context.Background(), req)
@@ -159,8 +160,7 @@ func createSIPTrunk(c *cli.Context) error {
 		return err
 	}
 
-	printSIPTrunkInfo(info)
-	return nil
+	return printSIPTrunkInfo(info)
 }
 
 func userPass(user string, hasPass bool) string {
@@ -180,6 +180,33 @@ func listSipTrunk(c *cli.Context) error {
 		return err
 	}
 
+	items := make([]*livekit.SIPTrunkInfo, 0, len(res.Items))
+	for _, item := range res.Items {
+		if item != nil {
+			items = append(items, withoutPasswords(item))
+		}
+	}
+	return printOutput(items, func() {
+		printSIPTrunkTable(items)
+		if c.Bool("verbose") {
+			PrintJSON(res)
+		}
+	})
+}
+
+// withoutPasswords masks the passwords of a trunk, like the table does
+func withoutPasswords(info *livekit.SIPTrunkInfo) *livekit.SIPTrunkInfo {
+	info = proto.Clone(info).(*livekit.SIPTrunkInfo)
+	if info.InboundPassword != "" {
+		info.InboundPassword = "****"
+	}
+	if info.OutboundPassword != "" {
+		info.OutboundPassword = "****"
+	}
+	return info
+}
+
+func printSIPTrunkTable(items []*livekit.SIPTrunkInfo) {
 	table := tablewriter.NewWriter(os.Stdout)
 	table.SetHeader([]string{
 		"SipTrunkId", "Name",
@@ -187,10 +214,7 @@ func listSipTrunk(c *cli.Context) error {
 		"OutboundAddress", "OutboundNumber", "OutboundAuth",
 		"Metadata",
 	})
-	for _, item := range res.Items {
-		if item == nil {
-			continue
-		}
+	for _, item := range items {
 		inboundNumbers := item.InboundNumbers
 		//lint:ignore SA1019 we still want to display old ones
 		for _, re := range item.InboundNumbersRegex {
@@ -205,12 +229,6 @@ func listSipTrunk(c *cli.Context) error {
 		})
 	}
 	table.Render()
-
-	if c.Bool("verbose") {
-		PrintJSON(res)
-	}
-
-	return nil
 }
 
 func deleteSIPTrunk(c *cli.Context) error {
@@ -221,12 +239,13 @@ func deleteSIPTrunk(c *cli.Context) error {
 		return err
 	}
 
-	printSIPTrunkInfo(info)
-	return nil
+	return printSIPTrunkInfo(info)
 }
 
-func printSIPTrunkInfo(info *livekit.SIPTrunkInfo) {
-	fmt.Printf("SIPTrunkID: %v\n", info.SipTrunkId)
+func printSIPTrunkInfo(info *livekit.SIPTrunkInfo) error {
+	return printOutput(withoutPasswords(info), func() {
+		fmt.Printf("SIPTrunkID: %v\n", info.SipTrunkId)
+	})
 }
 
 func createSIPDispatchRule(c *cli.Context) error {
@@ -243,7 +262,7 @@ func createSIPDispatchRule(c *cli.Context) error {
 	}
 
 	if c.Bool("verbose") {
-		PrintJSON(req)
+		fprintJSON(os.Stderr, req)
 	}
 
 	info, err := sipClient.CreateSIPDispatchRule(context.Background(), req)
@@ -251,8 +270,7 @@ func createSIPDispatchRule(c *cli.Context) error {
 		return err
 	}
 
-	printSIPDispatchRuleInfo(info)
-	return nil
+	return printSIPDispatchRuleInfo(info)
 }
 
 func listSipDispatchRule(c *cli.Context) error {
@@ -261,12 +279,24 @@ func listSipDispatchRule(c *cli.Context) error {
 		return err
 	}
 
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"SipDispatchRuleId", "Name", "SipTrunks", "Type", "RoomName", "Pin", "HidePhone", "Metadata"})
+	items := make([]*livekit.SIPDispatchRuleInfo, 0, len(res.Items))
 	for _, item := range res.Items {
-		if item == nil {
-			continue
+		if item != nil {
+			items = append(items, item)
+		}
+	}
+	return printOutput(items, func() {
+		printSIPDispatchRuleTable(items)
+		if c.Bool("verbose") {
+			PrintJSON(res)
 		}
+	})
+}
+
+func printSIPDispatchRuleTable(items []*livekit.SIPDispatchRuleInfo) {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"SipDispatchRuleId", "Name", "SipTrunks", "Type", "RoomName", "Pin", "HidePhone", "Metadata"})
+	for _, item := range items {
 		var room, typ, pin string
 		switch r := item.GetRule().GetRule().(type) {
 		case *livekit.SIPDispatchRule_DispatchRuleDirect:
@@ -285,12 +315,6 @@ func listSipDispatchRule(c *cli.Context) error {
 		table.Append([]string{item.SipDispatchRuleId, item.Name, trunks, typ, room, pin, strconv.FormatBool(item.HidePhoneNumber), item.Metadata})
 	}
 	table.Render()
-
-	if c.Bool("verbose") {
-		PrintJSON(res)
-	}
-
-	return nil
 }
 
 func deleteSIPDispatchRule(c *cli.Context) error {
@@ -301,12 +325,13 @@ func deleteSIPDispatchRule(c *cli.Context) error {
 		return err
 	}
 
-	printSIPDispatchRuleInfo(info)
-	return nil
+	return printSIPDispatchRuleInfo(info)
 }
 
-func printSIPDispatchRuleInfo(info *livekit.SIPDispatchRuleInfo) {
-	fmt.Printf("SIPDispatchRuleID: %v\n", info.SipDispatchRuleId)
+func printSIPDispatchRuleInfo(info *livekit.SIPDispatchRuleInfo) error {
+	return printOutput(info, func() {
+		fmt.Printf("SIPDispatchRuleID: %v\n", info.SipDispatchRuleId)
+	})
 }
 
 func createSIPParticipant(c *cli.Context) error {
@@ -323,7 +348,7 @@ func createSIPParticipant(c *cli.Context) error {
 	}
 
 	if c.Bool("verbose") {
-		PrintJSON(req)
+		fprintJSON(os.Stderr, req)
 	}
 
 	// CreateSIPParticipant will wait for LiveKit Participant to be created and that can take some time.
@@ -336,13 +361,14 @@ func createSIPParticipant(c *cli.Context) error {
 		return err
 	}
 
-	printSIPParticipantInfo(info)
-	return nil
+	return printSIPParticipantInfo(info)
 }
 
-func printSIPParticipantInfo(info *livekit.SIPParticipantInfo) {
-	fmt.Printf("SIPCallID: %v\n", info.SipCallId)
-	fmt.Printf("ParticipantID: %v\n", info.ParticipantId)
-	fmt.Printf("ParticipantIdentity: %v\n", info.ParticipantIdentity)
-	fmt.Printf("RoomName: %v\n", info.RoomName)
+func printSIPParticipantInfo(info *livekit.SIPParticipantInfo) error {
+	return printOutput(info, func() {
+		fmt.Printf("SIPCallID: %v\n", info.SipCallId)
+		fmt.Printf("ParticipantID: %v\n", info.ParticipantId)
+		fmt.Printf("ParticipantIdentity: %v\n", info.ParticipantIdentity)
+		fmt.Printf("RoomName: %v\n", info.RoomName)
+	})
 }
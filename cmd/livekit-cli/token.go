@@ -18,12 +18,18 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"text/tabwriter"
 	"time"
 
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
 	"github.com/urfave/cli/v2"
 
 	"github.com/livekit/protocol/auth"
 	"github.com/livekit/protocol/livekit"
+
+	"github.com/livekit/livekit-cli/pkg/config"
 )
 
 var (
@@ -72,6 +78,38 @@ var (
 					Name:  "allow-update-metadata",
 					Usage: "allow participant to update their own name and metadata from the client side",
 				},
+				&cli.BoolFlag{
+					Name:  "can-publish",
+					Usage: "allow participant to publish tracks, used with --join. defaults to true, use --can-publish=false to deny",
+				},
+				&cli.BoolFlag{
+					Name:  "can-subscribe",
+					Usage: "allow participant to subscribe to tracks, used with --join. defaults to true, use --can-subscribe=false to deny",
+				},
+				&cli.BoolFlag{
+					Name:  "can-publish-data",
+					Usage: "allow participant to publish data, used with --join. defaults to true, use --can-publish-data=false to deny",
+				},
+				&cli.BoolFlag{
+					Name:  "hidden",
+					Usage: "hide participant from others in the room, used with --join",
+				},
+				&cli.BoolFlag{
+					Name:  "agent",
+					Usage: "enable token to be used by an Agent framework worker, or to join as an agent",
+				},
+				&cli.BoolFlag{
+					Name:  "sip-admin",
+					Usage: "enable token to manage SIP trunks and dispatch rules",
+				},
+				&cli.BoolFlag{
+					Name:  "sip-call",
+					Usage: "enable token to make SIP calls",
+				},
+				&cli.StringFlag{
+					Name:  "template",
+					Usage: "start from a named set of permissions: viewer, host, recorder, agent, or a template from token_templates in the CLI config",
+				},
 				&cli.StringFlag{
 					Name:    "identity",
 					Aliases: []string{"i"},
@@ -100,64 +138,221 @@ var (
 					Name:  "grant",
 					Usage: "additional VideoGrant fields. It'll be merged with other arguments (JSON formatted)",
 				},
+				&cli.StringFlag{
+					Name:  "batch",
+					Usage: "create a token for each row of a CSV or JSON roster. other flags apply to every row, see README for the columns",
+				},
+				&cli.StringFlag{
+					Name:  "out",
+					Usage: "write batch tokens to a file, as JSON or CSV depending on the extension. defaults to JSON on stdout",
+				},
+				&cli.StringFlag{
+					Name:  "format",
+					Usage: "format of the batch tokens, json, yaml or csv (overrides the --out extension and --output)",
+				},
+				projectFlag,
+			},
+		},
+		{
+			Name:      "decode-token",
+			Aliases:   []string{"verify-token"},
+			Usage:     "prints the grants and validity of an access token, verifying its signature when the API secret is known",
+			UsageText: "livekit-cli decode-token [--project <project-name>] <token>",
+			Action:    decodeToken,
+			Category:  "Token",
+			Flags: []cli.Flag{
+				apiKeyFlag,
+				secretFlag,
+				projectFlag,
+			},
+		},
+		{
+			Name:     "token-server",
+			Usage:    "serves access tokens over HTTP for local development",
+			Action:   runTokenServer,
+			Category: "Token",
+			Flags: []cli.Flag{
+				apiKeyFlag,
+				secretFlag,
+				urlFlag,
 				projectFlag,
+				&cli.StringFlag{
+					Name:  "bind",
+					Usage: "address to listen on",
+					Value: "localhost:8088",
+				},
+				&cli.StringSliceFlag{
+					Name:  "allow",
+					Usage: "permissions that may be requested, i.e. --allow join,hidden. requests without permissions get join",
+					Value: cli.NewStringSlice("join"),
+				},
+				&cli.DurationFlag{
+					Name:  "max-valid-for",
+					Usage: "longest validity that may be requested, also the default",
+					Value: time.Hour,
+				},
+				&cli.StringSliceFlag{
+					Name:  "cors-origin",
+					Usage: "enable CORS for these origins, i.e. http://localhost:3000, or * for any origin",
+				},
 			},
 		},
 	}
 )
 
-func createToken(c *cli.Context) error {
-	p := c.String("identity") // required only for join
-	name := c.String("name")
-	room := c.String("room")
-	metadata := c.String("metadata")
-	validFor := c.String("valid-for")
+// tokenPermissions are the boolean permission flags of create-token, which are also accepted in batch rosters and templates
+var tokenPermissions = []string{
+	"create", "list", "join", "admin", "recorder", "egress", "ingress", "allow-update-metadata",
+	"hidden", "agent", "sip-admin", "sip-call",
+}
 
-	grant := &auth.VideoGrant{
-		Room: room,
-	}
-	hasPerms := false
-	if c.Bool("create") {
-		grant.RoomCreate = true
-		hasPerms = true
+// videoGrantFlags can deny permissions that are granted by default, so they are only applied when set
+var videoGrantFlags = map[string]string{
+	"can-publish":      "canPublish",
+	"can-subscribe":    "canSubscribe",
+	"can-publish-data": "canPublishData",
+}
+
+// tokenParams describes a single token, from command line flags or a row of a batch roster
+type tokenParams struct {
+	identity     string
+	name         string
+	room         string
+	metadata     string
+	validFor     string
+	permissions  []string
+	allowSources []string
+	// additional VideoGrant fields. later ones take precedence
+	grants []tokenGrant
+}
+
+// tokenGrant is a set of VideoGrant fields, JSON formatted
+type tokenGrant struct {
+	json string
+	// set by videoGrantFlags, which only restrict what a participant can do, so they don't count as permissions
+	restriction bool
+}
+
+func tokenParamsFromFlags(c *cli.Context) (tokenParams, error) {
+	p := tokenParams{
+		identity: c.String("identity"), // required only for join
+		name:     c.String("name"),
+		room:     c.String("room"),
+		metadata: c.String("metadata"),
+		validFor: c.String("valid-for"),
 	}
-	if c.Bool("join") {
-		grant.RoomJoin = true
-		if p == "" {
-			return errors.New("participant identity is required")
+	if name := c.String("template"); name != "" {
+		t, err := config.LoadTokenTemplate(name)
+		if err != nil {
+			return p, fmt.Errorf("%w: %s", err, name)
 		}
-		if room == "" {
-			return errors.New("room is required")
+		p.permissions = append(p.permissions, t.Permissions...)
+		if len(t.Grant) != 0 {
+			grant, err := json.Marshal(t.Grant)
+			if err != nil {
+				return p, err
+			}
+			p.grants = append(p.grants, tokenGrant{json: string(grant)})
+		}
+		if t.ValidFor != "" && !c.IsSet("valid-for") {
+			p.validFor = t.ValidFor
 		}
-		hasPerms = true
 	}
-	if c.Bool("admin") {
-		grant.RoomAdmin = true
-		hasPerms = true
+	for _, perm := range tokenPermissions {
+		if c.Bool(perm) {
+			p.permissions = append(p.permissions, perm)
+		}
 	}
-	if c.Bool("list") {
-		grant.RoomList = true
-		hasPerms = true
+	for flag, field := range videoGrantFlags {
+		if c.IsSet(flag) {
+			p.grants = append(p.grants, tokenGrant{
+				json:        fmt.Sprintf(`{%q: %t}`, field, c.Bool(flag)),
+				restriction: true,
+			})
+		}
 	}
-	if c.Bool("recorder") {
-		grant.RoomRecord = true
-		grant.Recorder = true
-		grant.Hidden = true
-		hasPerms = true
+	if c.IsSet("allow-source") {
+		p.allowSources = c.StringSlice("allow-source")
 	}
-	// in the future, this will change to more room specific permissions
-	if c.Bool("egress") {
-		grant.RoomRecord = true
-		hasPerms = true
+	if str := c.String("grant"); str != "" {
+		p.grants = append(p.grants, tokenGrant{json: str})
 	}
-	if c.Bool("ingress") {
-		grant.IngressAdmin = true
-		hasPerms = true
+	return p, nil
+}
+
+// sipGrant is the SIP grant understood by LiveKit servers, which is missing from the auth package in use
+type sipGrant struct {
+	// manage SIP trunks and dispatch rules
+	Admin bool `json:"admin,omitempty"`
+	// make outbound calls
+	Call bool `json:"call,omitempty"`
+}
+
+func buildGrant(p tokenParams) (*auth.VideoGrant, *sipGrant, error) {
+	grant := &auth.VideoGrant{
+		Room: p.room,
 	}
-	if c.IsSet("allow-source") {
-		sourcesStr := c.StringSlice("allow-source")
-		sources := make([]livekit.TrackSource, 0, len(sourcesStr))
-		for _, s := range sourcesStr {
+	var sip *sipGrant
+	hasPerms := false
+	for _, perm := range p.permissions {
+		switch perm {
+		case "create":
+			grant.RoomCreate = true
+			hasPerms = true
+		case "join":
+			grant.RoomJoin = true
+			if p.identity == "" {
+				return nil, nil, errors.New("participant identity is required")
+			}
+			if p.room == "" {
+				return nil, nil, errors.New("room is required")
+			}
+			hasPerms = true
+		case "admin":
+			grant.RoomAdmin = true
+			hasPerms = true
+		case "list":
+			grant.RoomList = true
+			hasPerms = true
+		case "recorder":
+			grant.RoomRecord = true
+			grant.Recorder = true
+			grant.Hidden = true
+			hasPerms = true
+		// in the future, this will change to more room specific permissions
+		case "egress":
+			grant.RoomRecord = true
+			hasPerms = true
+		case "ingress":
+			grant.IngressAdmin = true
+			hasPerms = true
+		case "allow-update-metadata":
+			grant.SetCanUpdateOwnMetadata(true)
+		case "can-publish":
+			grant.SetCanPublish(true)
+		case "can-subscribe":
+			grant.SetCanSubscribe(true)
+		case "can-publish-data":
+			grant.SetCanPublishData(true)
+		case "hidden":
+			grant.Hidden = true
+		case "agent":
+			grant.Agent = true
+			hasPerms = true
+		case "sip-admin", "sip-call":
+			if sip == nil {
+				sip = &sipGrant{}
+			}
+			sip.Admin = sip.Admin || perm == "sip-admin"
+			sip.Call = sip.Call || perm == "sip-call"
+			hasPerms = true
+		default:
+			return nil, nil, fmt.Errorf("invalid permission: %s", perm)
+		}
+	}
+	if p.allowSources != nil {
+		sources := make([]livekit.TrackSource, 0, len(p.allowSources))
+		for _, s := range p.allowSources {
 			var source livekit.TrackSource
 			switch s {
 			case "camera":
@@ -169,25 +364,97 @@ func createToken(c *cli.Context) error {
 			case "screen_share_audio":
 				source = livekit.TrackSource_SCREEN_SHARE_AUDIO
 			default:
-				return fmt.Errorf("invalid source: %s", s)
+				return nil, nil, fmt.Errorf("invalid source: %s", s)
 			}
 			sources = append(sources, source)
 		}
 		grant.SetCanPublishSources(sources)
 	}
-	if c.Bool("allow-update-metadata") {
-		grant.SetCanUpdateOwnMetadata(true)
-	}
 
-	if str := c.String("grant"); str != "" {
-		if err := json.Unmarshal([]byte(str), grant); err != nil {
-			return err
+	for _, g := range p.grants {
+		if err := json.Unmarshal([]byte(g.json), grant); err != nil {
+			return nil, nil, err
 		}
-		hasPerms = true
+		hasPerms = hasPerms || !g.restriction
 	}
 
 	if !hasPerms {
-		return errors.New("no permissions were given in this grant, see --help")
+		return nil, nil, errors.New("no permissions were given in this grant, see --help")
+	}
+	return grant, sip, nil
+}
+
+// same as the auth package
+const defaultTokenValidity = 6 * time.Hour
+
+// tokenClaims are the LiveKit claims of an access token
+type tokenClaims struct {
+	auth.ClaimGrants
+	SIP *sipGrant `json:"sip,omitempty"`
+}
+
+// newTokenClaims returns the claims of the token described by p, along with how long it is valid for
+func newTokenClaims(p tokenParams, grant *auth.VideoGrant, sip *sipGrant) (*tokenClaims, time.Duration, error) {
+	claims := &tokenClaims{
+		ClaimGrants: auth.ClaimGrants{
+			Identity: p.identity,
+			Name:     p.name,
+			Metadata: p.metadata,
+			Video:    grant,
+		},
+		SIP: sip,
+	}
+	if claims.Name == "" {
+		claims.Name = p.identity
+	}
+	validFor := defaultTokenValidity
+	if p.validFor != "" {
+		dur, err := time.ParseDuration(p.validFor)
+		if err != nil {
+			return nil, 0, err
+		}
+		if dur <= 0 {
+			return nil, 0, fmt.Errorf("valid-for must be positive: %s", p.validFor)
+		}
+		validFor = dur
+	}
+	return claims, validFor, nil
+}
+
+// toJWT signs the claims like auth.AccessToken, which cannot carry the SIP grant
+func (t *tokenClaims) toJWT(apiKey, apiSecret string, validFor time.Duration) (string, error) {
+	if apiKey == "" || apiSecret == "" {
+		return "", auth.ErrKeysMissing
+	}
+
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte(apiSecret)},
+		(&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	cl := jwt.Claims{
+		Issuer:    apiKey,
+		NotBefore: jwt.NewNumericDate(now),
+		Expiry:    jwt.NewNumericDate(now.Add(validFor)),
+		Subject:   t.Identity,
+	}
+	return jwt.Signed(sig).Claims(cl).Claims(t).CompactSerialize()
+}
+
+func createToken(c *cli.Context) error {
+	if c.IsSet("batch") {
+		return createBatchTokens(c)
+	}
+
+	p, err := tokenParamsFromFlags(c)
+	if err != nil {
+		return err
+	}
+	grant, sip, err := buildGrant(p)
+	if err != nil {
+		return err
 	}
 
 	pc, err := loadProjectDetails(c, ignoreURL)
@@ -195,43 +462,168 @@ func createToken(c *cli.Context) error {
 		return err
 	}
 
-	at := accessToken(pc.APIKey, pc.APISecret, grant, p)
+	claims, validFor, err := newTokenClaims(p, grant, sip)
+	if err != nil {
+		return err
+	}
 
-	if metadata != "" {
-		at.SetMetadata(metadata)
+	token, err := claims.toJWT(pc.APIKey, pc.APISecret, validFor)
+	if err != nil {
+		return err
 	}
-	if name == "" {
-		name = p
+
+	out := &tokenOutput{
+		Identity:  claims.Identity,
+		Name:      claims.Name,
+		Metadata:  claims.Metadata,
+		ExpiresAt: time.Now().Add(validFor).UTC().Truncate(time.Second),
+		Grant:     grant,
+		SIP:       sip,
+		Token:     token,
 	}
-	at.SetName(name)
-	if validFor != "" {
-		if dur, err := time.ParseDuration(validFor); err == nil {
-			fmt.Println("valid for (mins): ", int(dur/time.Minute))
-			at.SetValidFor(dur)
-		} else {
-			return err
+	return printOutput(out, func() {
+		fmt.Println("valid for (mins): ", int(validFor/time.Minute))
+		fmt.Println("token grants")
+		PrintJSON(grant)
+		if sip != nil {
+			fmt.Println()
+			fmt.Println("sip grants")
+			PrintJSON(sip)
 		}
-	}
+		fmt.Println()
+		fmt.Println("access token: ", token)
+	})
+}
+
+// tokenOutput describes a token in json and yaml output
+type tokenOutput struct {
+	APIKey    string           `json:"api_key,omitempty"`
+	Identity  string           `json:"identity,omitempty"`
+	Name      string           `json:"name,omitempty"`
+	Kind      string           `json:"kind,omitempty"`
+	Metadata  string           `json:"metadata,omitempty"`
+	NotBefore *time.Time       `json:"not_before,omitempty"`
+	ExpiresAt time.Time        `json:"expires_at,omitempty"`
+	Grant     *auth.VideoGrant `json:"grant,omitempty"`
+	SIP       *sipGrant        `json:"sip,omitempty"`
+	Token     string           `json:"token,omitempty"`
+	// result of decode-token verifying the signature
+	Signature string `json:"signature,omitempty"`
+}
 
-	token, err := at.ToJWT()
+type tokenDetails struct {
+	apiKey string
+	claims jwt.Claims
+	grants tokenClaims
+	token  *jwt.JSONWebToken
+}
+
+// parseToken reads the claims of a token without verifying it
+func parseToken(raw string) (*tokenDetails, error) {
+	tok, err := jwt.ParseSigned(raw)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	d := &tokenDetails{token: tok}
+	if err := tok.UnsafeClaimsWithoutVerification(&d.claims, &d.grants); err != nil {
+		return nil, err
+	}
+	d.apiKey = d.claims.Issuer
+	d.grants.Identity = d.claims.Subject
+	if d.grants.Identity == "" {
+		d.grants.Identity = d.claims.ID
 	}
+	return d, nil
+}
+
+// verify checks the signature of the token, expiry is reported separately
+func (d *tokenDetails) verify(apiSecret string) error {
+	return d.token.Claims([]byte(apiSecret), &jwt.Claims{})
+}
 
-	fmt.Println("token grants")
-	PrintJSON(grant)
-	fmt.Println()
-	fmt.Println("access token: ", token)
-	return nil
+// remaining returns how long the token is still valid for, negative once it has expired
+func (d *tokenDetails) remaining(now time.Time) (time.Duration, bool) {
+	if d.claims.Expiry == nil {
+		return 0, false
+	}
+	return d.claims.Expiry.Time().Sub(now), true
 }
 
-func accessToken(apiKey, apiSecret string, grant *auth.VideoGrant, identity string) *auth.AccessToken {
-	if apiKey == "" && apiSecret == "" {
-		// not provided, don't sign request
-		return nil
+func decodeToken(c *cli.Context) error {
+	if c.NArg() == 0 {
+		_ = cli.ShowCommandHelp(c, c.Command.Name)
+		return errors.New("token is required")
+	}
+	d, err := parseToken(c.Args().First())
+	if err != nil {
+		return fmt.Errorf("could not decode token: %w", err)
+	}
+
+	// a missing or mismatching secret isn't an error, the token can still be inspected
+	var signatureErr error
+	signature := "valid"
+	if pc, err := loadProjectDetails(c, ignoreURL); err != nil {
+		signature = fmt.Sprintf("not verified: %s", err)
+	} else if pc.APIKey != d.apiKey {
+		signature = fmt.Sprintf("not verified: token was issued by api key %s, but %s is configured", d.apiKey, pc.APIKey)
+	} else if err = d.verify(pc.APISecret); err != nil {
+		signature = "invalid"
+		signatureErr = fmt.Errorf("signature is invalid: %w", err)
+	}
+
+	out := &tokenOutput{
+		APIKey:    d.apiKey,
+		Identity:  d.grants.Identity,
+		Name:      d.grants.Name,
+		Kind:      d.grants.Kind,
+		Metadata:  d.grants.Metadata,
+		Grant:     d.grants.Video,
+		SIP:       d.grants.SIP,
+		Signature: signature,
+	}
+	if d.claims.NotBefore != nil {
+		notBefore := d.claims.NotBefore.Time().UTC()
+		out.NotBefore = &notBefore
+	}
+	if d.claims.Expiry != nil {
+		out.ExpiresAt = d.claims.Expiry.Time().UTC()
+	}
+	err = printOutput(out, func() {
+		w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
+		_, _ = fmt.Fprintf(w, "api key:\t%s\n", d.apiKey)
+		_, _ = fmt.Fprintf(w, "identity:\t%s\n", d.grants.Identity)
+		_, _ = fmt.Fprintf(w, "name:\t%s\n", d.grants.Name)
+		if d.grants.Kind != "" {
+			_, _ = fmt.Fprintf(w, "kind:\t%s\n", d.grants.Kind)
+		}
+		_, _ = fmt.Fprintf(w, "metadata:\t%s\n", d.grants.Metadata)
+		if d.claims.IssuedAt != nil {
+			_, _ = fmt.Fprintf(w, "issued at:\t%s\n", d.claims.IssuedAt.Time().Format(time.RFC3339))
+		}
+		if d.claims.NotBefore != nil {
+			_, _ = fmt.Fprintf(w, "not before:\t%s\n", d.claims.NotBefore.Time().Format(time.RFC3339))
+		}
+		if remaining, ok := d.remaining(time.Now()); !ok {
+			_, _ = fmt.Fprint(w, "expires:\tnever\n")
+		} else if remaining > 0 {
+			_, _ = fmt.Fprintf(w, "expires:\t%s (valid for %s)\n", d.claims.Expiry.Time().Format(time.RFC3339), remaining.Round(time.Second))
+		} else {
+			_, _ = fmt.Fprintf(w, "expires:\t%s (expired %s ago)\n", d.claims.Expiry.Time().Format(time.RFC3339), (-remaining).Round(time.Second))
+		}
+		_ = w.Flush()
+		fmt.Println()
+		fmt.Println("token grants")
+		PrintJSON(d.grants.Video)
+		if d.grants.SIP != nil {
+			fmt.Println()
+			fmt.Println("sip grants")
+			PrintJSON(d.grants.SIP)
+		}
+		fmt.Println()
+		fmt.Println("signature is", signature)
+	})
+	if err != nil {
+		return err
 	}
-	at := auth.NewAccessToken(apiKey, apiSecret).
-		AddGrant(grant).
-		SetIdentity(identity)
-	return at
+	return signatureErr
 }
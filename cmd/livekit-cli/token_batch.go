@@ -0,0 +1,263 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+// batchToken is a token created from a row of a roster
+type batchToken struct {
+	Identity  string    `json:"identity,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Room      string    `json:"room,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Token     string    `json:"token"`
+}
+
+var batchTokenCSVHeader = []string{"identity", "name", "room", "expires_at", "token"}
+
+func createBatchTokens(c *cli.Context) error {
+	path := c.String("batch")
+	out := c.String("out")
+	format := strings.ToLower(c.String("format"))
+	if format == "" {
+		switch {
+		case strings.EqualFold(filepath.Ext(out), ".csv"):
+			format = "csv"
+		case outputFormat == outputYAML:
+			format = outputYAML
+		default:
+			format = outputJSON
+		}
+	}
+	if format != outputJSON && format != outputYAML && format != "csv" {
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+
+	rows, err := readRoster(path)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("no rows in %s", path)
+	}
+
+	// validate every row before loading the project, so that a bad roster fails fast
+	defaults, err := tokenParamsFromFlags(c)
+	if err != nil {
+		return err
+	}
+	params := make([]tokenParams, 0, len(rows))
+	for i, row := range rows {
+		p := defaults
+		p.permissions = append([]string{}, defaults.permissions...)
+		p.grants = append([]tokenGrant{}, defaults.grants...)
+		for key, value := range row {
+			if err := applyRosterField(&p, key, value); err != nil {
+				return fmt.Errorf("row %d: %w", i+1, err)
+			}
+		}
+		if _, _, err := buildGrant(p); err != nil {
+			return fmt.Errorf("row %d: %w", i+1, err)
+		}
+		params = append(params, p)
+	}
+
+	pc, err := loadProjectDetails(c, ignoreURL)
+	if err != nil {
+		return err
+	}
+
+	tokens := make([]*batchToken, 0, len(params))
+	for i, p := range params {
+		grant, sip, _ := buildGrant(p)
+		claims, validFor, err := newTokenClaims(p, grant, sip)
+		if err != nil {
+			return fmt.Errorf("row %d: %w", i+1, err)
+		}
+		token, err := claims.toJWT(pc.APIKey, pc.APISecret, validFor)
+		if err != nil {
+			return fmt.Errorf("row %d: %w", i+1, err)
+		}
+		d, err := parseToken(token)
+		if err != nil {
+			return err
+		}
+		bt := &batchToken{
+			Identity: d.grants.Identity,
+			Name:     d.grants.Name,
+			Room:     grant.Room,
+			Token:    token,
+		}
+		if d.claims.Expiry != nil {
+			bt.ExpiresAt = d.claims.Expiry.Time().UTC()
+		}
+		tokens = append(tokens, bt)
+	}
+
+	w := io.Writer(os.Stdout)
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	switch format {
+	case "csv":
+		err = writeBatchTokensCSV(w, tokens)
+	case outputYAML:
+		err = fprintYAML(w, tokens)
+	default:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(tokens)
+	}
+	if err != nil {
+		return err
+	}
+	if out != "" {
+		printStatusf("wrote %d tokens to %s\n", len(tokens), out)
+	}
+	return nil
+}
+
+func writeBatchTokensCSV(w io.Writer, tokens []*batchToken) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(batchTokenCSVHeader); err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if err := cw.Write([]string{t.Identity, t.Name, t.Room, t.ExpiresAt.Format(time.RFC3339), t.Token}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// readRoster reads rows of column => value from a JSON array of objects, or a CSV file with a header row
+func readRoster(path string) ([]map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return parseJSONRoster(data)
+	}
+	return parseCSVRoster(data)
+}
+
+func parseCSVRoster(data []byte) ([]map[string]string, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("roster is missing a header row")
+	}
+	header := records[0]
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, key := range header {
+			row[strings.TrimSpace(key)] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseJSONRoster accepts the same columns as CSV. Lists may be given as arrays, and metadata and grants as objects
+func parseJSONRoster(data []byte) ([]map[string]string, error) {
+	var objects []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &objects); err != nil {
+		return nil, err
+	}
+	rows := make([]map[string]string, 0, len(objects))
+	for _, obj := range objects {
+		rows = append(rows, rosterRowFromJSON(obj))
+	}
+	return rows, nil
+}
+
+func rosterRowFromJSON(obj map[string]json.RawMessage) map[string]string {
+	row := make(map[string]string, len(obj))
+	for key, raw := range obj {
+		var str string
+		var list []string
+		if err := json.Unmarshal(raw, &str); err == nil {
+			row[key] = str
+		} else if err := json.Unmarshal(raw, &list); err == nil {
+			row[key] = strings.Join(list, " ")
+		} else {
+			row[key] = string(raw)
+		}
+	}
+	return row
+}
+
+// applyRosterField overrides the defaults from command line flags with a roster column
+func applyRosterField(p *tokenParams, key, value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	switch key {
+	case "identity":
+		p.identity = value
+	case "name":
+		p.name = value
+	case "room":
+		p.room = value
+	case "metadata":
+		p.metadata = value
+	case "valid_for":
+		p.validFor = value
+	case "grants":
+		// either names of permissions, or VideoGrant fields like --grant
+		if strings.HasPrefix(value, "{") {
+			p.grants = append(p.grants, tokenGrant{json: value})
+		} else {
+			p.permissions = append(p.permissions, splitRosterList(value)...)
+		}
+	case "allow_source":
+		p.allowSources = splitRosterList(value)
+	default:
+		return fmt.Errorf("unknown column: %s", key)
+	}
+	return nil
+}
+
+func splitRosterList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ' ' || r == ',' || r == ';'
+	})
+}
@@ -0,0 +1,204 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/livekit-cli/pkg/config"
+)
+
+// tokenServer issues tokens over HTTP for local development
+type tokenServer struct {
+	pc *config.ProjectConfig
+	// permissions that may be requested
+	allowed     []string
+	maxValidFor time.Duration
+	// origins allowed by CORS, empty to disable CORS
+	origins []string
+}
+
+// tokenServerResponse is returned by /token
+type tokenServerResponse struct {
+	URL       string    `json:"url,omitempty"`
+	Identity  string    `json:"identity"`
+	Room      string    `json:"room,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Token     string    `json:"token"`
+}
+
+func runTokenServer(c *cli.Context) error {
+	allowed := c.StringSlice("allow")
+	for _, perm := range allowed {
+		if !slices.Contains(tokenPermissions, perm) {
+			return fmt.Errorf("invalid permission: %s", perm)
+		}
+	}
+	maxValidFor := c.Duration("max-valid-for")
+	if maxValidFor <= 0 {
+		return errors.New("max-valid-for must be positive")
+	}
+
+	pc, err := loadProjectDetails(c, ignoreURL)
+	if err != nil {
+		return err
+	}
+
+	s := &tokenServer{
+		pc:          pc,
+		allowed:     allowed,
+		maxValidFor: maxValidFor,
+		origins:     c.StringSlice("cors-origin"),
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/token", s)
+	srv := &http.Server{Handler: mux}
+
+	ln, err := net.Listen("tcp", c.String("bind"))
+	if err != nil {
+		return err
+	}
+	printStatusf("Issuing tokens on http://%s/token, allowing %s for up to %s\n",
+		ln.Addr().String(), strings.Join(allowed, ", "), maxValidFor)
+
+	done := make(chan os.Signal, 1)
+	signal.Notify(done, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- srv.Serve(ln)
+	}()
+
+	select {
+	case <-done:
+		return srv.Close()
+	case err = <-errChan:
+		return err
+	}
+}
+
+// ServeHTTP accepts the same fields as a batch roster row, as query parameters or a JSON object
+func (s *tokenServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.setCORSHeaders(w, r) && r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var fields map[string]string
+	switch r.Method {
+	case http.MethodGet:
+		fields = make(map[string]string)
+		for key, values := range r.URL.Query() {
+			fields[key] = strings.Join(values, " ")
+		}
+	case http.MethodPost:
+		var body map[string]json.RawMessage
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&body); err != nil {
+			writeTokenServerError(w, http.StatusBadRequest, err)
+			return
+		}
+		fields = rosterRowFromJSON(body)
+	default:
+		writeTokenServerError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+		return
+	}
+
+	res, err := s.issue(fields)
+	if err != nil {
+		writeTokenServerError(w, http.StatusBadRequest, err)
+		return
+	}
+	logger.Infow("issued token", "identity", res.Identity, "room", res.Room, "expiresAt", res.ExpiresAt)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func (s *tokenServer) issue(fields map[string]string) (*tokenServerResponse, error) {
+	p := tokenParams{validFor: s.maxValidFor.String()}
+	for key, value := range fields {
+		if err := applyRosterField(&p, key, value); err != nil {
+			return nil, err
+		}
+	}
+	if len(p.grants) != 0 {
+		return nil, errors.New("grant fields are not allowed, request permissions by name")
+	}
+	if len(p.permissions) == 0 {
+		p.permissions = []string{"join"}
+	}
+	for _, perm := range p.permissions {
+		if !slices.Contains(s.allowed, perm) {
+			return nil, fmt.Errorf("permission is not allowed: %s", perm)
+		}
+	}
+
+	grant, sip, err := buildGrant(p)
+	if err != nil {
+		return nil, err
+	}
+	claims, validFor, err := newTokenClaims(p, grant, sip)
+	if err != nil {
+		return nil, err
+	}
+	if validFor <= 0 || validFor > s.maxValidFor {
+		return nil, fmt.Errorf("valid_for must be at most %s", s.maxValidFor)
+	}
+	token, err := claims.toJWT(s.pc.APIKey, s.pc.APISecret, validFor)
+	if err != nil {
+		return nil, err
+	}
+	return &tokenServerResponse{
+		URL:       s.pc.URL,
+		Identity:  claims.Identity,
+		Room:      grant.Room,
+		ExpiresAt: time.Now().Add(validFor).UTC().Truncate(time.Second),
+		Token:     token,
+	}, nil
+}
+
+// setCORSHeaders returns true when the request comes from an allowed origin
+func (s *tokenServer) setCORSHeaders(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || len(s.origins) == 0 {
+		return false
+	}
+	if !slices.Contains(s.origins, "*") && !slices.Contains(s.origins, origin) {
+		return false
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Add("Vary", "Origin")
+	return true
+}
+
+func writeTokenServerError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
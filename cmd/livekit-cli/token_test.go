@@ -0,0 +1,182 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/auth"
+
+	"github.com/livekit/livekit-cli/pkg/config"
+)
+
+func TestParseToken(t *testing.T) {
+	grant := &auth.VideoGrant{RoomJoin: true, Room: "room"}
+	claims, validFor, err := newTokenClaims(tokenParams{
+		identity: "alice",
+		name:     "Alice",
+		metadata: `{"role":"host"}`,
+		validFor: "1h",
+	}, grant, &sipGrant{Call: true})
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, validFor)
+	token, err := claims.toJWT("key", "secret", validFor)
+	require.NoError(t, err)
+
+	d, err := parseToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, "key", d.apiKey)
+	assert.Equal(t, "alice", d.grants.Identity)
+	assert.Equal(t, "Alice", d.grants.Name)
+	assert.Equal(t, `{"role":"host"}`, d.grants.Metadata)
+	assert.Equal(t, grant, d.grants.Video)
+	assert.Equal(t, &sipGrant{Call: true}, d.grants.SIP)
+
+	remaining, ok := d.remaining(time.Now())
+	assert.True(t, ok)
+	assert.InDelta(t, time.Hour, remaining, float64(time.Minute))
+
+	assert.NoError(t, d.verify("secret"))
+	assert.Error(t, d.verify("other"))
+
+	_, err = parseToken("not a token")
+	assert.Error(t, err)
+}
+
+func TestRoster(t *testing.T) {
+	csvRows, err := parseCSVRoster([]byte(`identity,name,room,grants,valid_for
+student-1,Student 1,class,join,1h
+teacher,,class,"join admin",
+`))
+	require.NoError(t, err)
+	jsonRows, err := parseJSONRoster([]byte(`[
+		{"identity": "student-1", "name": "Student 1", "room": "class", "grants": ["join"], "valid_for": "1h"},
+		{"identity": "teacher", "room": "class", "grants": "join admin", "metadata": {"role": "teacher"}}
+	]`))
+	require.NoError(t, err)
+
+	for _, rows := range [][]map[string]string{csvRows, jsonRows} {
+		require.Len(t, rows, 2)
+
+		var p tokenParams
+		for key, value := range rows[0] {
+			require.NoError(t, applyRosterField(&p, key, value))
+		}
+		require.Equal(t, tokenParams{
+			identity:    "student-1",
+			name:        "Student 1",
+			room:        "class",
+			validFor:    "1h",
+			permissions: []string{"join"},
+		}, p)
+
+		p = tokenParams{permissions: []string{"list"}}
+		for key, value := range rows[1] {
+			require.NoError(t, applyRosterField(&p, key, value))
+		}
+		grant, _, err := buildGrant(p)
+		require.NoError(t, err)
+		require.True(t, grant.RoomJoin)
+		require.True(t, grant.RoomAdmin)
+		require.True(t, grant.RoomList)
+	}
+	require.Equal(t, `{"role": "teacher"}`, jsonRows[1]["metadata"])
+
+	var p tokenParams
+	require.Error(t, applyRosterField(&p, "identiy", "typo"))
+	_, _, err = buildGrant(tokenParams{room: "class", permissions: []string{"join"}})
+	require.Error(t, err, "join requires an identity")
+}
+
+func TestBuildGrant(t *testing.T) {
+	grant, sip, err := buildGrant(tokenParams{
+		identity:    "viewer",
+		room:        "room",
+		permissions: []string{"join", "can-publish", "hidden", "sip-admin"},
+		// later grants take precedence, as with a template followed by --can-publish=false
+		grants: []tokenGrant{{json: `{"canPublish": true}`}, {json: `{"canPublish": false}`, restriction: true}},
+	})
+	require.NoError(t, err)
+	require.False(t, grant.GetCanPublish())
+	require.True(t, grant.GetCanSubscribe())
+	require.True(t, grant.Hidden)
+	require.Equal(t, &sipGrant{Admin: true}, sip)
+
+	_, _, err = buildGrant(tokenParams{permissions: []string{"hidden"}})
+	require.Error(t, err, "hidden alone is not a permission")
+	_, _, err = buildGrant(tokenParams{grants: []tokenGrant{{json: `{"canPublish": false}`, restriction: true}}})
+	require.Error(t, err, "--can-publish=false alone is not a permission")
+	_, _, err = buildGrant(tokenParams{grants: []tokenGrant{{json: `{"roomList": true}`}}})
+	require.NoError(t, err)
+
+	for _, validFor := range []string{"0s", "-1h"} {
+		_, _, err = newTokenClaims(tokenParams{validFor: validFor}, grant, nil)
+		require.Error(t, err, validFor)
+	}
+}
+
+func TestTokenServer(t *testing.T) {
+	s := &tokenServer{
+		pc:          &config.ProjectConfig{URL: "ws://localhost:7880", APIKey: "key", APISecret: "secret"},
+		allowed:     []string{"join", "hidden"},
+		maxValidFor: time.Hour,
+		origins:     []string{"http://localhost:3000"},
+	}
+	request := func(method, target, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("Origin", "http://localhost:3000")
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+
+	w := request(http.MethodGet, "/token?room=room&identity=alice&grants=join,hidden&valid_for=10m", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, "http://localhost:3000", w.Header().Get("Access-Control-Allow-Origin"))
+	var res tokenServerResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	require.Equal(t, "ws://localhost:7880", res.URL)
+	d, err := parseToken(res.Token)
+	require.NoError(t, err)
+	require.NoError(t, d.verify("secret"))
+	require.True(t, d.grants.Video.Hidden)
+	remaining, _ := d.remaining(time.Now())
+	require.InDelta(t, 10*time.Minute, remaining, float64(time.Minute))
+
+	w = request(http.MethodPost, "/token", `{"room": "room", "identity": "bob"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = request(http.MethodOptions, "/token", "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	for _, target := range []string{
+		"/token?room=room&identity=alice&grants=admin",
+		"/token?room=room&identity=alice&valid_for=2h",
+		"/token?room=room&identity=alice&grants=" + url.QueryEscape(`{"roomAdmin":true}`),
+		"/token?room=room",
+	} {
+		w = request(http.MethodGet, target, "")
+		require.Equal(t, http.StatusBadRequest, w.Code, target)
+	}
+}
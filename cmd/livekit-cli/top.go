@@ -0,0 +1,905 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+
+	"github.com/livekit/protocol/livekit"
+	lksdk "github.com/livekit/server-sdk-go/v2"
+)
+
+var TopCommands = []*cli.Command{
+	{
+		Name:     "top",
+		Usage:    "Full screen dashboard of rooms, participants, egress, ingress and SIP, refreshed periodically",
+		Category: roomCategory,
+		Action:   runTop,
+		Flags: withDefaultFlags(
+			&cli.DurationFlag{
+				Name:  "interval",
+				Usage: "how often to refresh",
+				Value: 2 * time.Second,
+			},
+		),
+	},
+}
+
+// topAPI is what the dashboard uses of the server APIs
+type topAPI interface {
+	ListRooms(ctx context.Context) ([]*livekit.Room, error)
+	ListParticipants(ctx context.Context, room string) ([]*livekit.ParticipantInfo, error)
+	ListEgress(ctx context.Context) ([]*livekit.EgressInfo, error)
+	ListIngress(ctx context.Context) ([]*livekit.IngressInfo, error)
+	ListSIPTrunks(ctx context.Context) ([]*livekit.SIPTrunkInfo, error)
+	ListSIPDispatchRules(ctx context.Context) ([]*livekit.SIPDispatchRuleInfo, error)
+	MuteTrack(ctx context.Context, room, identity, trackSid string, muted bool) error
+	RemoveParticipant(ctx context.Context, room, identity string) error
+	StopEgress(ctx context.Context, egressID string) error
+}
+
+type topClients struct {
+	room    *lksdk.RoomServiceClient
+	egress  *lksdk.EgressClient
+	ingress *lksdk.IngressClient
+	sip     *lksdk.SIPClient
+}
+
+func (t *topClients) ListRooms(ctx context.Context) ([]*livekit.Room, error) {
+	res, err := t.room.ListRooms(ctx, &livekit.ListRoomsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return res.Rooms, nil
+}
+
+func (t *topClients) ListParticipants(ctx context.Context, room string) ([]*livekit.ParticipantInfo, error) {
+	res, err := t.room.ListParticipants(ctx, &livekit.ListParticipantsRequest{Room: room})
+	if err != nil {
+		return nil, err
+	}
+	return res.Participants, nil
+}
+
+func (t *topClients) ListEgress(ctx context.Context) ([]*livekit.EgressInfo, error) {
+	res, err := t.egress.ListEgress(ctx, &livekit.ListEgressRequest{Active: true})
+	if err != nil {
+		return nil, err
+	}
+	return res.Items, nil
+}
+
+func (t *topClients) ListIngress(ctx context.Context) ([]*livekit.IngressInfo, error) {
+	res, err := t.ingress.ListIngress(ctx, &livekit.ListIngressRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return res.Items, nil
+}
+
+func (t *topClients) ListSIPTrunks(ctx context.Context) ([]*livekit.SIPTrunkInfo, error) {
+	res, err := t.sip.ListSIPTrunk(ctx, &livekit.ListSIPTrunkRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return res.Items, nil
+}
+
+func (t *topClients) ListSIPDispatchRules(ctx context.Context) ([]*livekit.SIPDispatchRuleInfo, error) {
+	res, err := t.sip.ListSIPDispatchRule(ctx, &livekit.ListSIPDispatchRuleRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return res.Items, nil
+}
+
+func (t *topClients) MuteTrack(ctx context.Context, room, identity, trackSid string, muted bool) error {
+	_, err := t.room.MutePublishedTrack(ctx, &livekit.MuteRoomTrackRequest{
+		Room:     room,
+		Identity: identity,
+		TrackSid: trackSid,
+		Muted:    muted,
+	})
+	return err
+}
+
+func (t *topClients) RemoveParticipant(ctx context.Context, room, identity string) error {
+	_, err := t.room.RemoveParticipant(ctx, &livekit.RoomParticipantIdentity{
+		Room:     room,
+		Identity: identity,
+	})
+	return err
+}
+
+func (t *topClients) StopEgress(ctx context.Context, egressID string) error {
+	_, err := t.egress.StopEgress(ctx, &livekit.StopEgressRequest{EgressId: egressID})
+	return err
+}
+
+// topSnapshot is the result of a refresh. Lists that failed to load are nil
+type topSnapshot struct {
+	at time.Time
+	// participants are listed for the room being browsed, if any
+	room          string
+	rooms         []*livekit.Room
+	participants  []*livekit.ParticipantInfo
+	egress        []*livekit.EgressInfo
+	ingress       []*livekit.IngressInfo
+	trunks        []*livekit.SIPTrunkInfo
+	dispatchRules []*livekit.SIPDispatchRuleInfo
+	err           error
+}
+
+func fetchTopSnapshot(ctx context.Context, api topAPI, room string) *topSnapshot {
+	s := &topSnapshot{at: time.Now(), room: room}
+	var errs []error
+	// list is called with the result of each API, which replaces a failed list with nil
+	list := func(name string, err error, ok func()) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			return
+		}
+		ok()
+	}
+
+	rooms, err := api.ListRooms(ctx)
+	list("rooms", err, func() { s.rooms = append([]*livekit.Room{}, rooms...) })
+	if room != "" {
+		participants, err := api.ListParticipants(ctx, room)
+		list("participants", err, func() { s.participants = append([]*livekit.ParticipantInfo{}, participants...) })
+	}
+	egress, err := api.ListEgress(ctx)
+	list("egress", err, func() { s.egress = append([]*livekit.EgressInfo{}, egress...) })
+	ingress, err := api.ListIngress(ctx)
+	list("ingress", err, func() { s.ingress = append([]*livekit.IngressInfo{}, ingress...) })
+	trunks, err := api.ListSIPTrunks(ctx)
+	list("sip trunks", err, func() { s.trunks = append([]*livekit.SIPTrunkInfo{}, trunks...) })
+	rules, err := api.ListSIPDispatchRules(ctx)
+	list("sip dispatch rules", err, func() { s.dispatchRules = append([]*livekit.SIPDispatchRuleInfo{}, rules...) })
+
+	s.err = errors.Join(errs...)
+	return s
+}
+
+type topTab int
+
+const (
+	topRooms topTab = iota
+	topEgress
+	topIngress
+	topSIP
+)
+
+var topTabNames = []string{"Rooms", "Egress", "Ingress", "SIP"}
+
+// topAction changes something on the server, after confirmation if prompt is set
+type topAction struct {
+	prompt string
+	// shown once the action succeeded
+	done string
+	run  func(ctx context.Context, api topAPI) error
+}
+
+// topModel is the state of the dashboard, updated by keys and refreshes and drawn by render
+type topModel struct {
+	title string
+	tab   topTab
+	// room and participant being browsed on the rooms tab
+	room     string
+	identity string
+
+	rooms         []*livekit.Room
+	participants  []*livekit.ParticipantInfo
+	egress        []*livekit.EgressInfo
+	ingress       []*livekit.IngressInfo
+	trunks        []*livekit.SIPTrunkInfo
+	dispatchRules []*livekit.SIPDispatchRuleInfo
+	// the room participants were listed for
+	participantsRoom string
+	updatedAt        time.Time
+	refreshErr       error
+
+	// selected row of each view, by key so that it stays on the same entry when the list changes
+	selected map[string]string
+	cursor   map[string]int
+	// scroll offset of each view
+	offset map[string]int
+
+	confirm *topAction
+	status  string
+}
+
+func newTopModel(title string) *topModel {
+	return &topModel{
+		title:    title,
+		selected: make(map[string]string),
+		cursor:   make(map[string]int),
+		offset:   make(map[string]int),
+	}
+}
+
+func (m *topModel) apply(s *topSnapshot) {
+	if s.rooms != nil {
+		m.rooms = s.rooms
+	}
+	if s.participants != nil && s.room == m.room {
+		m.participants = s.participants
+		m.participantsRoom = s.room
+	}
+	if s.egress != nil {
+		m.egress = s.egress
+	}
+	if s.ingress != nil {
+		m.ingress = s.ingress
+	}
+	if s.trunks != nil {
+		m.trunks = s.trunks
+	}
+	if s.dispatchRules != nil {
+		m.dispatchRules = s.dispatchRules
+	}
+	m.updatedAt = s.at
+	m.refreshErr = s.err
+}
+
+// view names the list being shown
+func (m *topModel) view() string {
+	switch m.tab {
+	case topEgress:
+		return "egress"
+	case topIngress:
+		return "ingress"
+	case topSIP:
+		return "sip"
+	}
+	switch {
+	case m.identity != "":
+		return "tracks"
+	case m.room != "":
+		return "participants"
+	default:
+		return "rooms"
+	}
+}
+
+func (m *topModel) participant() *livekit.ParticipantInfo {
+	if m.participantsRoom != m.room {
+		return nil
+	}
+	for _, p := range m.participants {
+		if p.Identity == m.identity {
+			return p
+		}
+	}
+	return nil
+}
+
+// table returns the header, keys and rows of the current view
+func (m *topModel) table() ([]string, []string, [][]string) {
+	var keys []string
+	var rows [][]string
+	switch m.view() {
+	case "rooms":
+		for _, rm := range m.rooms {
+			keys = append(keys, rm.Name)
+			rows = append(rows, []string{
+				rm.Name,
+				rm.Sid,
+				fmt.Sprint(rm.NumParticipants),
+				fmt.Sprint(rm.NumPublishers),
+				formatAge(time.Unix(rm.CreationTime, 0), m.updatedAt),
+				rm.Metadata,
+			})
+		}
+		return []string{"Name", "Sid", "Participants", "Publishers", "Age", "Metadata"}, keys, rows
+
+	case "participants":
+		if m.participantsRoom == m.room {
+			for _, p := range m.participants {
+				keys = append(keys, p.Identity)
+				rows = append(rows, []string{
+					p.Identity,
+					p.Name,
+					p.State.String(),
+					p.Kind.String(),
+					fmt.Sprint(len(p.Tracks)),
+					formatAge(time.Unix(p.JoinedAt, 0), m.updatedAt),
+					p.Metadata,
+				})
+			}
+		}
+		return []string{"Identity", "Name", "State", "Kind", "Tracks", "Joined", "Metadata"}, keys, rows
+
+	case "tracks":
+		if p := m.participant(); p != nil {
+			for _, t := range p.Tracks {
+				muted := ""
+				if t.Muted {
+					muted = "muted"
+				}
+				var size string
+				if t.Width != 0 {
+					size = fmt.Sprintf("%dx%d", t.Width, t.Height)
+				}
+				keys = append(keys, t.Sid)
+				rows = append(rows, []string{t.Sid, t.Type.String(), t.Source.String(), t.Name, t.MimeType, size, muted})
+			}
+		}
+		return []string{"Sid", "Type", "Source", "Name", "MimeType", "Size", "Muted"}, keys, rows
+
+	case "egress":
+		for _, item := range m.egress {
+			keys = append(keys, item.EgressId)
+			rows = append(rows, egressTableRow(item))
+		}
+		return egressTableHeader, keys, rows
+
+	case "ingress":
+		for _, item := range m.ingress {
+			if item == nil {
+				continue
+			}
+			keys = append(keys, item.IngressId)
+			rows = append(rows, ingressTableRow(item))
+		}
+		return ingressTableHeader, keys, rows
+
+	default:
+		for _, item := range m.trunks {
+			keys = append(keys, item.SipTrunkId)
+			numbers := append([]string{}, item.InboundNumbers...)
+			if item.OutboundNumber != "" {
+				numbers = append(numbers, item.OutboundNumber)
+			}
+			rows = append(rows, []string{"trunk", item.SipTrunkId, item.Name, strings.Join(numbers, ","), item.Metadata})
+		}
+		for _, item := range m.dispatchRules {
+			keys = append(keys, item.SipDispatchRuleId)
+			var rule string
+			switch r := item.GetRule().GetRule().(type) {
+			case *livekit.SIPDispatchRule_DispatchRuleDirect:
+				rule = "direct to " + r.DispatchRuleDirect.RoomName
+			case *livekit.SIPDispatchRule_DispatchRuleIndividual:
+				rule = "individual to " + r.DispatchRuleIndividual.RoomPrefix + "*"
+			}
+			rows = append(rows, []string{"dispatch rule", item.SipDispatchRuleId, item.Name, rule, item.Metadata})
+		}
+		return []string{"Type", "ID", "Name", "Numbers / Rule", "Metadata"}, keys, rows
+	}
+}
+
+// selection returns the index of the selected row, following its key when the list changed
+func (m *topModel) selection(keys []string) int {
+	view := m.view()
+	if len(keys) == 0 {
+		return -1
+	}
+	if key, ok := m.selected[view]; ok {
+		for i, k := range keys {
+			if k == key {
+				m.cursor[view] = i
+				return i
+			}
+		}
+	}
+	i := min(max(m.cursor[view], 0), len(keys)-1)
+	m.cursor[view] = i
+	m.selected[view] = keys[i]
+	return i
+}
+
+func (m *topModel) move(delta int) {
+	_, keys, _ := m.table()
+	i := m.selection(keys)
+	if i < 0 {
+		return
+	}
+	i = min(max(i+delta, 0), len(keys)-1)
+	view := m.view()
+	m.cursor[view] = i
+	m.selected[view] = keys[i]
+}
+
+func (m *topModel) selectedKey() string {
+	_, keys, _ := m.table()
+	if i := m.selection(keys); i >= 0 {
+		return keys[i]
+	}
+	return ""
+}
+
+// handleKey updates the model, and returns an action to run, and whether the data should be refreshed or the dashboard closed
+func (m *topModel) handleKey(key string, pageSize int) (action *topAction, refresh, quit bool) {
+	if key == "ctrl-c" {
+		return nil, false, true
+	}
+	if m.confirm != nil {
+		action, m.confirm = m.confirm, nil
+		if key == "y" || key == "Y" {
+			return action, false, false
+		}
+		m.status = "cancelled"
+		return nil, false, false
+	}
+
+	m.status = ""
+	switch key {
+	case "q":
+		return nil, false, true
+	case "up", "k":
+		m.move(-1)
+	case "down", "j":
+		m.move(1)
+	case "pgup":
+		m.move(-pageSize)
+	case "pgdn":
+		m.move(pageSize)
+	case "home", "g":
+		m.move(-1 << 30)
+	case "end", "G":
+		m.move(1 << 30)
+	case "tab":
+		m.tab = (m.tab + 1) % topTab(len(topTabNames))
+	case "1", "2", "3", "4":
+		m.tab = topTab(key[0] - '1')
+	case "r":
+		return nil, true, false
+	case "enter", "right", "l":
+		if m.tab != topRooms {
+			break
+		}
+		sel := m.selectedKey()
+		switch m.view() {
+		case "rooms":
+			if sel != "" {
+				m.room = sel
+				return nil, true, false
+			}
+		case "participants":
+			if sel != "" {
+				m.identity = sel
+			}
+		}
+	case "esc", "left", "h", "backspace":
+		if m.tab != topRooms {
+			break
+		}
+		if m.identity != "" {
+			m.identity = ""
+		} else if m.room != "" {
+			m.room = ""
+		}
+	case "m":
+		return m.muteAction(), false, false
+	case "x":
+		action = m.removeAction()
+		if action != nil && action.prompt != "" {
+			m.confirm = action
+			return nil, false, false
+		}
+		return action, false, false
+	}
+	return nil, false, false
+}
+
+func (m *topModel) muteAction() *topAction {
+	if m.view() != "tracks" {
+		return nil
+	}
+	p := m.participant()
+	sid := m.selectedKey()
+	if p == nil || sid == "" {
+		return nil
+	}
+	for _, t := range p.Tracks {
+		if t.Sid != sid {
+			continue
+		}
+		room, identity, muted := m.room, p.Identity, !t.Muted
+		done := "muted " + sid
+		if !muted {
+			done = "unmuted " + sid
+		}
+		return &topAction{
+			done: done,
+			run: func(ctx context.Context, api topAPI) error {
+				return api.MuteTrack(ctx, room, identity, sid, muted)
+			},
+		}
+	}
+	return nil
+}
+
+// removeAction removes the selected participant, or stops the selected egress
+func (m *topModel) removeAction() *topAction {
+	switch m.view() {
+	case "participants", "tracks":
+		room, identity := m.room, m.identity
+		if identity == "" {
+			identity = m.selectedKey()
+		}
+		if identity == "" {
+			return nil
+		}
+		return &topAction{
+			prompt: fmt.Sprintf("remove %s from %s?", identity, room),
+			done:   fmt.Sprintf("removed %s from %s", identity, room),
+			run: func(ctx context.Context, api topAPI) error {
+				return api.RemoveParticipant(ctx, room, identity)
+			},
+		}
+	case "egress":
+		id := m.selectedKey()
+		if id == "" {
+			return nil
+		}
+		return &topAction{
+			prompt: fmt.Sprintf("stop egress %s?", id),
+			done:   "stopped egress " + id,
+			run: func(ctx context.Context, api topAPI) error {
+				return api.StopEgress(ctx, id)
+			},
+		}
+	}
+	return nil
+}
+
+func (m *topModel) keyHints() string {
+	hints := []string{"q quit", "tab/1-4 switch", "↑↓ select", "r refresh"}
+	switch m.view() {
+	case "rooms":
+		hints = append(hints, "enter participants")
+	case "participants":
+		hints = append(hints, "enter tracks", "esc back", "x remove participant")
+	case "tracks":
+		hints = append(hints, "esc back", "m mute/unmute", "x remove participant")
+	case "egress":
+		hints = append(hints, "x stop egress")
+	}
+	return strings.Join(hints, "  ")
+}
+
+// render draws the dashboard as lines of the given width, with ANSI attributes
+func (m *topModel) render(width, height int) []string {
+	var lines []string
+	add := func(attr, s string) {
+		s = runewidth.Truncate(printable(s), width, "…")
+		if attr != "" {
+			s = attr + runewidth.FillRight(s, width) + "\033[0m"
+		}
+		lines = append(lines, s)
+	}
+
+	updated := "loading…"
+	if !m.updatedAt.IsZero() {
+		updated = "updated " + m.updatedAt.Format(time.TimeOnly)
+	}
+	add("\033[7m", fmt.Sprintf(" %s  %s", m.title, updated))
+
+	counts := []int{len(m.rooms), len(m.egress), len(m.ingress), len(m.trunks) + len(m.dispatchRules)}
+	var tabs []string
+	for i, name := range topTabNames {
+		tab := fmt.Sprintf("%d %s (%d)", i+1, name, counts[i])
+		if topTab(i) == m.tab {
+			tab = "[" + tab + "]"
+		} else {
+			tab = " " + tab + " "
+		}
+		tabs = append(tabs, tab)
+	}
+	add("", strings.Join(tabs, " "))
+
+	crumbs := []string{topTabNames[m.tab]}
+	if m.tab == topRooms {
+		if m.room != "" {
+			crumbs = append(crumbs, m.room)
+		}
+		if p := m.participant(); p != nil {
+			crumbs = append(crumbs, fmt.Sprintf("%s (%s)", p.Identity, p.State))
+		} else if m.identity != "" {
+			crumbs = append(crumbs, m.identity)
+		}
+	}
+	add("\033[1m", strings.Join(crumbs, " > "))
+
+	header, keys, rows := m.table()
+	widths := make([]int, len(header))
+	for i, h := range header {
+		widths[i] = runewidth.StringWidth(h)
+	}
+	for _, row := range rows {
+		for i, f := range row {
+			// names and metadata could break the layout, or contain escape codes
+			row[i] = printable(f)
+			widths[i] = max(widths[i], runewidth.StringWidth(row[i]))
+		}
+	}
+	format := func(fields []string) string {
+		cols := make([]string, len(fields))
+		for i, f := range fields {
+			if i < len(fields)-1 {
+				f = runewidth.FillRight(f, widths[i])
+			}
+			cols[i] = f
+		}
+		return " " + strings.Join(cols, "  ")
+	}
+	add("\033[4m", format(header))
+
+	// the header and footer take 6 lines
+	listHeight := max(height-6, 1)
+	view := m.view()
+	sel := m.selection(keys)
+	offset := m.offset[view]
+	if sel >= 0 {
+		offset = min(offset, sel)
+		offset = max(offset, sel-listHeight+1)
+	}
+	offset = max(min(offset, len(rows)-listHeight), 0)
+	m.offset[view] = offset
+
+	switch {
+	case len(rows) == 0 && m.updatedAt.IsZero(), len(rows) == 0 && view == "participants" && m.participantsRoom != m.room:
+		add("", " loading…")
+	case len(rows) == 0:
+		add("", " nothing to show")
+	}
+	for i := offset; i < len(rows) && i < offset+listHeight; i++ {
+		if i == sel {
+			add("\033[7m", format(rows[i]))
+		} else {
+			add("", format(rows[i]))
+		}
+	}
+	for len(lines) < height-2 {
+		lines = append(lines, "")
+	}
+
+	switch {
+	case m.confirm != nil:
+		add("\033[1;33m", m.confirm.prompt+" (y/n)")
+	case m.status != "":
+		add("\033[1m", m.status)
+	case m.refreshErr != nil:
+		add("\033[31m", "refresh failed: "+strings.ReplaceAll(m.refreshErr.Error(), "\n", "; "))
+	default:
+		add("", "")
+	}
+	add("\033[7m", " "+m.keyHints())
+	return lines
+}
+
+// formatAge returns how long ago t was, to the second
+func formatAge(t, now time.Time) string {
+	if t.Unix() <= 0 || now.IsZero() {
+		return ""
+	}
+	return now.Sub(t).Truncate(time.Second).String()
+}
+
+// parseKeys splits terminal input into key names, or the typed character for printable keys
+func parseKeys(b []byte) []string {
+	var keys []string
+	for len(b) > 0 {
+		c := b[0]
+		switch {
+		case c == 0x1b && len(b) == 1:
+			keys = append(keys, "esc")
+			b = b[1:]
+		case c == 0x1b && (b[1] == '[' || b[1] == 'O'):
+			// CSI or SS3 sequence, which ends with a byte in 0x40-0x7e
+			end := 2
+			for end < len(b) && (b[end] < 0x40 || b[end] > 0x7e) {
+				end++
+			}
+			if end == len(b) {
+				return keys
+			}
+			if key, ok := escapeKeys[string(b[2:end+1])]; ok {
+				keys = append(keys, key)
+			}
+			b = b[end+1:]
+		case c == 0x1b:
+			// alt+key, or esc typed quickly before another key
+			keys = append(keys, "esc")
+			b = b[1:]
+		case c == '\r' || c == '\n':
+			keys = append(keys, "enter")
+			b = b[1:]
+		case c == '\t':
+			keys = append(keys, "tab")
+			b = b[1:]
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, "backspace")
+			b = b[1:]
+		case c == 0x03:
+			keys = append(keys, "ctrl-c")
+			b = b[1:]
+		default:
+			// invalid or truncated UTF-8, such as 8-bit meta keys, decodes to RuneError one byte at a time
+			r, size := utf8.DecodeRune(b)
+			keys = append(keys, string(r))
+			b = b[size:]
+		}
+	}
+	return keys
+}
+
+var escapeKeys = map[string]string{
+	"A":  "up",
+	"B":  "down",
+	"C":  "right",
+	"D":  "left",
+	"H":  "home",
+	"F":  "end",
+	"1~": "home",
+	"7~": "home",
+	"4~": "end",
+	"8~": "end",
+	"5~": "pgup",
+	"6~": "pgdn",
+}
+
+func runTop(c *cli.Context) error {
+	interval := c.Duration("interval")
+	if interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+	in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(in) || !term.IsTerminal(out) {
+		return errors.New("top needs an interactive terminal")
+	}
+
+	pc, err := loadProjectDetails(c)
+	if err != nil {
+		return err
+	}
+	opts := withDefaultClientOpts(pc)
+	api := &topClients{
+		room:    lksdk.NewRoomServiceClient(pc.URL, pc.APIKey, pc.APISecret, opts...),
+		egress:  lksdk.NewEgressClient(pc.URL, pc.APIKey, pc.APISecret, opts...),
+		ingress: lksdk.NewIngressClient(pc.URL, pc.APIKey, pc.APISecret, opts...),
+		sip:     lksdk.NewSIPClient(pc.URL, pc.APIKey, pc.APISecret, opts...),
+	}
+	model := newTopModel(fmt.Sprintf("livekit-cli top  %s", pc.URL))
+
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = term.Restore(in, state)
+	}()
+	// switch to the alternate screen and hide the cursor, so that the shell is left as it was
+	fmt.Print("\033[?1049h\033[?25l")
+	defer fmt.Print("\033[?25h\033[?1049l")
+
+	ctx, cancel := context.WithCancel(c.Context)
+	defer cancel()
+	return topLoop(ctx, model, api, interval, in)
+}
+
+func topLoop(ctx context.Context, model *topModel, api topAPI, interval time.Duration, fd int) error {
+	keys := make(chan []string)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			select {
+			case keys <- parseKeys(buf[:n]):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// terminal input is raw, so ctrl-c arrives as a key. These are sent when the session ends
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	resized := make(chan os.Signal, 1)
+	notifyResize(resized)
+	defer signal.Stop(resized)
+
+	snapshots := make(chan *topSnapshot, 1)
+	refreshing := false
+	refresh := func() {
+		if refreshing {
+			return
+		}
+		refreshing = true
+		room := model.room
+		go func() {
+			rctx, cancel := context.WithTimeout(ctx, max(interval, 10*time.Second))
+			defer cancel()
+			snapshots <- fetchTopSnapshot(rctx, api, room)
+		}()
+	}
+	results := make(chan string, 1)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	refresh()
+	for {
+		width, height, err := term.GetSize(fd)
+		if err != nil || width <= 0 || height <= 0 {
+			width, height = 80, 24
+		}
+		// draw over the previous frame in a single write, clearing what's left of each line
+		lines := model.render(width, height)
+		_, _ = fmt.Print("\033[H" + strings.Join(lines, "\033[K\r\n") + "\033[K\033[J")
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-signals:
+			return nil
+		case <-resized:
+		case pressed, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			for _, key := range pressed {
+				action, refreshNow, quit := model.handleKey(key, max(height-6, 1))
+				if quit {
+					return nil
+				}
+				if refreshNow {
+					refresh()
+				}
+				if action != nil {
+					model.status = "working…"
+					go func() {
+						actx, cancel := context.WithTimeout(ctx, 10*time.Second)
+						defer cancel()
+						if err := action.run(actx, api); err != nil {
+							results <- "failed: " + err.Error()
+						} else {
+							results <- action.done
+						}
+					}()
+				}
+			}
+		case status := <-results:
+			model.status = status
+			refresh()
+		case s := <-snapshots:
+			refreshing = false
+			model.apply(s)
+			if s.room != model.room {
+				// moved to another room while refreshing
+				refresh()
+			}
+		case <-ticker.C:
+			refresh()
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+type fakeTopAPI struct {
+	rooms        []*livekit.Room
+	participants map[string][]*livekit.ParticipantInfo
+	egress       []*livekit.EgressInfo
+	calls        []string
+}
+
+func (f *fakeTopAPI) ListRooms(ctx context.Context) ([]*livekit.Room, error) {
+	return f.rooms, nil
+}
+
+func (f *fakeTopAPI) ListParticipants(ctx context.Context, room string) ([]*livekit.ParticipantInfo, error) {
+	return f.participants[room], nil
+}
+
+func (f *fakeTopAPI) ListEgress(ctx context.Context) ([]*livekit.EgressInfo, error) {
+	return f.egress, nil
+}
+
+func (f *fakeTopAPI) ListIngress(ctx context.Context) ([]*livekit.IngressInfo, error) {
+	return nil, errors.New("unavailable")
+}
+
+func (f *fakeTopAPI) ListSIPTrunks(ctx context.Context) ([]*livekit.SIPTrunkInfo, error) {
+	return nil, nil
+}
+
+func (f *fakeTopAPI) ListSIPDispatchRules(ctx context.Context) ([]*livekit.SIPDispatchRuleInfo, error) {
+	return nil, nil
+}
+
+func (f *fakeTopAPI) MuteTrack(ctx context.Context, room, identity, trackSid string, muted bool) error {
+	f.calls = append(f.calls, "mute "+room+" "+identity+" "+trackSid+" "+map[bool]string{true: "on", false: "off"}[muted])
+	return nil
+}
+
+func (f *fakeTopAPI) RemoveParticipant(ctx context.Context, room, identity string) error {
+	f.calls = append(f.calls, "remove "+room+" "+identity)
+	return nil
+}
+
+func (f *fakeTopAPI) StopEgress(ctx context.Context, egressID string) error {
+	f.calls = append(f.calls, "stop "+egressID)
+	return nil
+}
+
+func TestTopModel(t *testing.T) {
+	api := &fakeTopAPI{
+		rooms: []*livekit.Room{{Name: "lobby", Sid: "RM_1"}, {Name: "stage", Sid: "RM_2", NumParticipants: 2}},
+		participants: map[string][]*livekit.ParticipantInfo{
+			"stage": {
+				{Identity: "alice", State: livekit.ParticipantInfo_ACTIVE},
+				{Identity: "bob", State: livekit.ParticipantInfo_ACTIVE, Tracks: []*livekit.TrackInfo{
+					{Sid: "TR_1", Type: livekit.TrackType_AUDIO},
+					{Sid: "TR_2", Type: livekit.TrackType_VIDEO, Muted: true},
+				}},
+			},
+		},
+		egress: []*livekit.EgressInfo{{EgressId: "EG_1", Status: livekit.EgressStatus_EGRESS_ACTIVE}},
+	}
+	ctx := context.Background()
+	m := newTopModel("top")
+	press := func(keys ...string) *topAction {
+		var action *topAction
+		for _, key := range keys {
+			var refresh bool
+			action, refresh, _ = m.handleKey(key, 10)
+			if refresh {
+				m.apply(fetchTopSnapshot(ctx, api, m.room))
+			}
+		}
+		return action
+	}
+
+	s := fetchTopSnapshot(ctx, api, "")
+	require.EqualError(t, s.err, "ingress: unavailable")
+	m.apply(s)
+	require.Equal(t, "rooms", m.view())
+
+	// drill into the second room and the second participant
+	require.Nil(t, press("down", "enter"))
+	require.Equal(t, "participants", m.view())
+	require.Nil(t, press("j", "enter"))
+	require.Equal(t, "tracks", m.view())
+
+	// the selected track is unmuted, and the second one muted again
+	action := press("m")
+	require.NoError(t, action.run(ctx, api))
+	action = press("down", "m")
+	require.NoError(t, action.run(ctx, api))
+
+	// removing a participant needs confirmation
+	require.Nil(t, press("x"))
+	require.Nil(t, press("n"))
+	require.Equal(t, "cancelled", m.status)
+	require.Nil(t, press("x"))
+	require.NoError(t, press("y").run(ctx, api))
+
+	// selection follows the participant when the list changes
+	press("esc")
+	api.participants["stage"] = api.participants["stage"][1:]
+	m.apply(fetchTopSnapshot(ctx, api, m.room))
+	require.Equal(t, "bob", m.selectedKey())
+
+	press("2", "x")
+	require.NoError(t, press("y").run(ctx, api))
+	require.Equal(t, []string{
+		"mute stage bob TR_1 on",
+		"mute stage bob TR_2 off",
+		"remove stage bob",
+		"stop EG_1",
+	}, api.calls)
+
+	press("1", "esc")
+	api.rooms[1].Metadata = "line\nbreak \x1b[31m"
+	m.apply(fetchTopSnapshot(ctx, api, ""))
+	lines := m.render(60, 12)
+	require.Len(t, lines, 12)
+	require.Equal(t, "[1 Rooms (2)]  2 Egress (1)   3 Ingress (0)   4 SIP (0) ", lines[1])
+	// the selected room is highlighted, and control characters in metadata are replaced
+	require.Equal(t, "\x1b[7m stage  RM_2  2             0                line break  [3…\x1b[0m", lines[5])
+	require.Contains(t, lines[10], "refresh failed: ingress: unavailable")
+}
+
+func TestParseKeys(t *testing.T) {
+	require.Equal(t,
+		[]string{"up", "down", "pgdn", "enter", "esc", "x", "é", "ctrl-c", "home"},
+		parseKeys([]byte("\x1b[A\x1bOB\x1b[6~\r\x1bxé\x03\x1b[H")),
+	)
+	require.Equal(t, []string{"esc"}, parseKeys([]byte("\x1b")))
+	// truncated UTF-8, as when a paste is split between reads
+	require.Equal(t, []string{"\uFFFD", "\uFFFD"}, parseKeys([]byte{0xe2, 0x86}))
+	require.Equal(t, []string{"\uFFFD", "q"}, parseKeys([]byte{0xf1, 'q'}))
+}
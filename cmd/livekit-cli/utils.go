@@ -18,13 +18,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"unicode"
 
 	"github.com/livekit/protocol/utils/interceptors"
 	"github.com/twitchtv/twirp"
 	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
 
 	"github.com/livekit/livekit-cli/pkg/config"
 )
@@ -65,12 +69,75 @@ var (
 	printCurl bool
 	curlFlag  = &cli.BoolFlag{
 		Name:        "curl",
-		Usage:       "print curl commands for API actions",
+		Usage:       "print curl commands for API actions to stderr",
 		Destination: &printCurl,
 		Required:    false,
 	}
+	outputFormat string
+	outputFlag   = &cli.StringFlag{
+		Name:        "output",
+		Aliases:     []string{"o"},
+		Usage:       "output format: table, json or yaml. status messages are printed to stderr",
+		Value:       outputTable,
+		Destination: &outputFormat,
+	}
+	// globalOutputFlag accepts --output before the command. Commands reset outputFormat to the default of their own flag,
+	// so it is copied over by withOutputFlag unless the command's flag is set
+	globalOutputFlag = &cli.StringFlag{
+		Name:    outputFlag.Name,
+		Aliases: outputFlag.Aliases,
+		Usage:   outputFlag.Usage,
+		Value:   outputTable,
+	}
 )
 
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// withOutputFlag adds --output to commands and their subcommands, except those with a flag of the same name
+func withOutputFlag(commands []*cli.Command) {
+	for _, cmd := range commands {
+		withOutputFlag(cmd.Subcommands)
+		if cmd.Action == nil || hasFlag(cmd, outputFlag.Names()...) {
+			continue
+		}
+		cmd.Flags = append(cmd.Flags, outputFlag)
+		before := cmd.Before
+		cmd.Before = func(c *cli.Context) error {
+			// the closest command where --output was given wins
+			for _, ctx := range c.Lineage() {
+				if slices.Contains(ctx.LocalFlagNames(), outputFlag.Name) {
+					outputFormat = ctx.String(outputFlag.Name)
+					break
+				}
+			}
+			switch outputFormat {
+			case outputTable, outputJSON, outputYAML:
+			default:
+				return fmt.Errorf("unsupported output format: %s", outputFormat)
+			}
+			if before != nil {
+				return before(c)
+			}
+			return nil
+		}
+	}
+}
+
+func hasFlag(cmd *cli.Command, names ...string) bool {
+	for _, f := range cmd.Flags {
+		for _, name := range f.Names() {
+			if slices.Contains(names, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func withDefaultFlags(flags ...cli.Flag) []cli.Flag {
 	return append([]cli.Flag{
 		urlFlag,
@@ -88,7 +155,7 @@ func withDefaultClientOpts(c *config.ProjectConfig) []twirp.ClientOption {
 		ics  []twirp.Interceptor
 	)
 	if printCurl {
-		ics = append(ics, interceptors.NewCurlPrinter(os.Stdout, c.URL))
+		ics = append(ics, interceptors.NewCurlPrinter(os.Stderr, c.URL))
 	}
 	if len(ics) != 0 {
 		opts = append(opts, twirp.WithClientInterceptors(ics...))
@@ -97,8 +164,99 @@ func withDefaultClientOpts(c *config.ProjectConfig) []twirp.ClientOption {
 }
 
 func PrintJSON(obj interface{}) {
+	fprintJSON(os.Stdout, obj)
+}
+
+func fprintJSON(w io.Writer, obj interface{}) {
 	txt, _ := json.MarshalIndent(obj, "", "  ")
-	fmt.Println(string(txt))
+	_, _ = fmt.Fprintln(w, string(txt))
+}
+
+// PrintYAML prints obj with the same field names as PrintJSON
+func PrintYAML(obj interface{}) error {
+	return fprintYAML(os.Stdout, obj)
+}
+
+func fprintYAML(w io.Writer, obj interface{}) error {
+	txt, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	// JSON is valid YAML, decoding it into a node keeps the order of fields
+	var node yaml.Node
+	if err = yaml.Unmarshal(txt, &node); err != nil {
+		return err
+	}
+	blockStyle(&node)
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err = enc.Encode(&node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// blockStyle clears the flow style and quoting left over from JSON
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, n := range node.Content {
+		blockStyle(n)
+	}
+}
+
+// printOutput prints obj in the selected output format. In table format, table is called when set, otherwise obj is
+// printed as JSON
+func printOutput(obj interface{}, table func()) error {
+	switch outputFormat {
+	case outputJSON:
+		PrintJSON(obj)
+	case outputYAML:
+		return PrintYAML(obj)
+	default:
+		if table != nil {
+			table()
+		} else {
+			PrintJSON(obj)
+		}
+	}
+	return nil
+}
+
+// actionResult is printed by commands that have nothing else to return, so that scripts can check what was done
+type actionResult struct {
+	Action    string   `json:"action"`
+	Room      string   `json:"room,omitempty"`
+	Identity  string   `json:"identity,omitempty"`
+	TrackSids []string `json:"track_sids,omitempty"`
+	Project   string   `json:"project,omitempty"`
+	Success   bool     `json:"success"`
+}
+
+// printResult prints the status in every format, and the result only with json or yaml
+func printResult(result *actionResult, status ...interface{}) error {
+	printStatus(status...)
+	result.Success = true
+	return printOutput(result, func() {})
+}
+
+// printStatus prints messages that aren't part of the output to stderr, so that the output can be piped
+func printStatus(a ...interface{}) {
+	_, _ = fmt.Fprintln(os.Stderr, a...)
+}
+
+func printStatusf(format string, a ...interface{}) {
+	_, _ = fmt.Fprintf(os.Stderr, format, a...)
+}
+
+// printable replaces line breaks, tabs and other control characters with spaces,
+// so that names and errors from the server can't break the layout of tables or contain escape codes
+func printable(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, s)
 }
 
 func ExpandUser(p string) string {
@@ -130,7 +288,7 @@ func loadProjectDetails(c *cli.Context, opts ...loadOption) (*config.ProjectConf
 	}
 	logDetails := func(c *cli.Context, pc *config.ProjectConfig) {
 		if c.Bool("verbose") {
-			fmt.Printf("URL: %s, api-key: %s, api-secret: %s\n",
+			printStatusf("URL: %s, api-key: %s, api-secret: %s\n",
 				pc.URL,
 				pc.APIKey,
 				"************",
@@ -145,7 +303,7 @@ func loadProjectDetails(c *cli.Context, opts ...loadOption) (*config.ProjectConf
 		if err != nil {
 			return nil, err
 		}
-		fmt.Println("Using project:", c.String("project"))
+		printStatus("Using project:", c.String("project"))
 		logDetails(c, pc)
 		return pc, nil
 	}
@@ -173,7 +331,7 @@ func loadProjectDetails(c *cli.Context, opts ...loadOption) (*config.ProjectConf
 			envVars = append(envVars, "api-secret")
 		}
 		if len(envVars) > 0 {
-			fmt.Printf("Using %s from environment\n", strings.Join(envVars, ", "))
+			printStatusf("Using %s from environment\n", strings.Join(envVars, ", "))
 			logDetails(c, pc)
 		}
 		return pc, nil
@@ -182,7 +340,7 @@ func loadProjectDetails(c *cli.Context, opts ...loadOption) (*config.ProjectConf
 	// load default project
 	dp, err := config.LoadDefaultProject()
 	if err == nil {
-		fmt.Println("Using default project", dp.Name)
+		printStatus("Using default project", dp.Name)
 		logDetails(c, dp)
 		return dp, nil
 	}
@@ -0,0 +1,81 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+func TestPrintYAML(t *testing.T) {
+	var buf bytes.Buffer
+	err := fprintYAML(&buf, []*livekit.Room{{
+		Sid:             "RM_1",
+		Name:            "true",
+		NumParticipants: 2,
+		EnabledCodecs:   []*livekit.Codec{{Mime: "video/vp8"}},
+	}})
+	require.NoError(t, err)
+	// fields keep the order and names of JSON, and strings stay strings
+	require.Equal(t, `- sid: RM_1
+  name: "true"
+  enabled_codecs:
+    - mime: video/vp8
+  num_participants: 2
+`, buf.String())
+}
+
+func TestOutputFlagPosition(t *testing.T) {
+	t.Cleanup(func() { outputFormat = outputTable })
+	run := func(args ...string) (string, error) {
+		var format string
+		app := &cli.App{
+			Flags: []cli.Flag{globalOutputFlag},
+			Commands: []*cli.Command{{
+				Name: "list",
+				Action: func(c *cli.Context) error {
+					format = outputFormat
+					return nil
+				},
+			}},
+		}
+		withOutputFlag(app.Commands)
+		err := app.Run(append([]string{"livekit-cli"}, args...))
+		return format, err
+	}
+
+	for _, tc := range []struct {
+		args   []string
+		format string
+	}{
+		{[]string{"list"}, outputTable},
+		{[]string{"-o", "json", "list"}, outputJSON},
+		{[]string{"list", "--output", "yaml"}, outputYAML},
+		// the flag after the command takes precedence
+		{[]string{"-o", "json", "list", "-o", "table"}, outputTable},
+	} {
+		format, err := run(tc.args...)
+		require.NoError(t, err, tc.args)
+		require.Equal(t, tc.format, format, tc.args)
+	}
+
+	_, err := run("-o", "xml", "list")
+	require.EqualError(t, err, "unsupported output format: xml")
+}
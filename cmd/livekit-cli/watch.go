@@ -0,0 +1,339 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+)
+
+var (
+	watchFlag = &cli.BoolFlag{
+		Name:  "watch",
+		Usage: "keep polling and show a live view, highlighting what was added, removed or changed",
+	}
+	watchIntervalFlag = &cli.DurationFlag{
+		Name:  "interval",
+		Usage: "how often to poll with --watch",
+		Value: 2 * time.Second,
+	}
+)
+
+const (
+	// the entry was in the first poll
+	watchListed  = "listed"
+	watchAdded   = "added"
+	watchRemoved = "removed"
+	// the status of the entry changed
+	watchChanged = "changed"
+)
+
+// number of changes kept in the log below the live view
+const watchLogSize = 10
+
+// watchRow is an entry of a watched list
+type watchRow struct {
+	// identifies the entry between polls
+	key string
+	// shown in the change log
+	label string
+	// transitions are highlighted, empty for entries without a status
+	status string
+	fields []string
+	item   interface{}
+}
+
+type watchChange struct {
+	At    time.Time   `json:"at"`
+	Type  string      `json:"type"`
+	Key   string      `json:"key"`
+	From  string      `json:"from,omitempty"`
+	To    string      `json:"to,omitempty"`
+	Item  interface{} `json:"item,omitempty"`
+	label string
+}
+
+// diffWatchRows returns the entries added, removed and changed between two polls, in list order
+func diffWatchRows(prev, next []watchRow, now time.Time) []watchChange {
+	prevByKey := make(map[string]watchRow, len(prev))
+	for _, row := range prev {
+		prevByKey[row.key] = row
+	}
+	nextKeys := make(map[string]bool, len(next))
+	var changes []watchChange
+	for _, row := range next {
+		nextKeys[row.key] = true
+		old, ok := prevByKey[row.key]
+		switch {
+		case !ok:
+			changes = append(changes, watchChange{At: now, Type: watchAdded, Key: row.key, To: row.status, Item: row.item, label: row.label})
+		case old.status != row.status:
+			changes = append(changes, watchChange{At: now, Type: watchChanged, Key: row.key, From: old.status, To: row.status, Item: row.item, label: row.label})
+		}
+	}
+	for _, row := range prev {
+		if !nextKeys[row.key] {
+			changes = append(changes, watchChange{At: now, Type: watchRemoved, Key: row.key, From: row.status, Item: row.item, label: row.label})
+		}
+	}
+	return changes
+}
+
+// watchView is the live view of a watched list
+type watchView struct {
+	title  string
+	header []string
+	// index of the status in fields, -1 when entries don't have one
+	statusColumn int
+	color        bool
+
+	rows []watchRow
+	// removed in the last poll, shown once more
+	removed   []watchRow
+	marks     map[string]watchChange
+	log       []watchChange
+	polled    bool
+	updatedAt time.Time
+	err       error
+}
+
+// update records a poll and returns what changed since the previous one
+func (v *watchView) update(rows []watchRow, now time.Time) []watchChange {
+	changes := diffWatchRows(v.rows, rows, now)
+	first := !v.polled
+	v.polled = true
+	v.removed = nil
+	v.marks = make(map[string]watchChange)
+	keys := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		keys[row.key] = true
+	}
+	for _, row := range v.rows {
+		if !keys[row.key] {
+			v.removed = append(v.removed, row)
+		}
+	}
+	v.rows = rows
+	v.updatedAt = now
+	v.err = nil
+
+	if first {
+		// everything is new on the first poll, there's nothing to highlight
+		for i := range changes {
+			changes[i].Type = watchListed
+		}
+		return changes
+	}
+	for _, ch := range changes {
+		v.marks[ch.Key] = ch
+	}
+	v.log = append(v.log, changes...)
+	if len(v.log) > watchLogSize {
+		v.log = v.log[len(v.log)-watchLogSize:]
+	}
+	return changes
+}
+
+func (v *watchView) render(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "%s, updated %s", v.title, v.updatedAt.Format(time.TimeOnly))
+	if v.err != nil {
+		_, _ = fmt.Fprint(w, v.paint(watchRemoved, "  poll failed: "+printable(v.err.Error())))
+	}
+	_, _ = fmt.Fprint(w, "\n\n")
+
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 1, 1, 2, ' ', 0)
+	// every entry has to be a single line, so that lines can be matched with the type of change
+	writeRow := func(changeType string, fields []string) {
+		cols := make([]string, len(fields))
+		for i, f := range fields {
+			cols[i] = printable(f)
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\n", watchMarker(changeType), strings.Join(cols, "\t"))
+	}
+	_, _ = fmt.Fprintf(tw, " \t%s\n", strings.Join(v.header, "\t"))
+	types := []string{""}
+	for _, row := range v.rows {
+		fields := row.fields
+		ch, ok := v.marks[row.key]
+		if ok && ch.Type == watchChanged && v.statusColumn >= 0 {
+			fields = append([]string{}, fields...)
+			fields[v.statusColumn] = ch.From + " -> " + ch.To
+		}
+		writeRow(ch.Type, fields)
+		types = append(types, ch.Type)
+	}
+	for _, row := range v.removed {
+		writeRow(watchRemoved, row.fields)
+		types = append(types, watchRemoved)
+	}
+	_ = tw.Flush()
+	// colors are added once columns are aligned, so that escape codes don't count towards widths
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	for i, line := range lines {
+		_, _ = fmt.Fprintln(w, v.paint(types[i], line))
+	}
+
+	if len(v.log) > 0 {
+		_, _ = fmt.Fprint(w, "\nRecent changes\n")
+		for i := len(v.log) - 1; i >= 0; i-- {
+			ch := v.log[i]
+			_, _ = fmt.Fprintln(w, v.paint(ch.Type, fmt.Sprintf("%s  %s %s", ch.At.Format(time.TimeOnly), watchMarker(ch.Type), printable(describeWatchChange(ch)))))
+		}
+	}
+}
+
+func (v *watchView) paint(changeType, s string) string {
+	if !v.color {
+		return s
+	}
+	var code string
+	switch changeType {
+	case watchAdded:
+		code = "32"
+	case watchRemoved:
+		code = "31"
+	case watchChanged:
+		code = "33"
+	default:
+		return s
+	}
+	return "\033[" + code + "m" + s + "\033[0m"
+}
+
+func watchMarker(changeType string) string {
+	switch changeType {
+	case watchAdded:
+		return "+"
+	case watchRemoved:
+		return "-"
+	case watchChanged:
+		return "~"
+	default:
+		return " "
+	}
+}
+
+func describeWatchChange(ch watchChange) string {
+	switch ch.Type {
+	case watchChanged:
+		return fmt.Sprintf("%s %s -> %s", ch.label, ch.From, ch.To)
+	case watchAdded:
+		if ch.To != "" {
+			return fmt.Sprintf("%s added (%s)", ch.label, ch.To)
+		}
+		return ch.label + " added"
+	default:
+		return ch.label + " " + ch.Type
+	}
+}
+
+// watchList polls fetch until interrupted. In table mode the list is redrawn after every poll,
+// in json and yaml mode every change is printed as it's seen, starting with the entries of the first poll
+func watchList(c *cli.Context, title string, header []string, statusColumn int, fetch func(ctx context.Context) ([]watchRow, error)) error {
+	interval := c.Duration("interval")
+	if interval <= 0 {
+		return errors.New("interval must be positive")
+	}
+
+	ctx, stop := signal.NotifyContext(c.Context, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	defer stop()
+
+	tty := term.IsTerminal(int(os.Stdout.Fd()))
+	view := &watchView{
+		title:        fmt.Sprintf("%s: polling every %s", title, interval),
+		header:       header,
+		statusColumn: statusColumn,
+		color:        tty,
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		rows, err := fetch(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		now := time.Now()
+		if err != nil {
+			if !view.polled {
+				// most likely bad credentials or arguments, which won't get better by polling again
+				return err
+			}
+			// keep polling, the service may be back by the next one
+			view.err = err
+			view.updatedAt = now
+			if outputFormat != outputTable {
+				printStatusf("%s poll failed: %v\n", now.Format(time.TimeOnly), err)
+			}
+		} else {
+			changes := view.update(rows, now)
+			if outputFormat != outputTable {
+				if err = printWatchChanges(changes); err != nil {
+					return err
+				}
+			}
+		}
+
+		if outputFormat == outputTable {
+			if tty {
+				// move to the top and clear the screen
+				fmt.Print("\033[H\033[2J")
+			} else {
+				fmt.Println()
+			}
+			view.render(os.Stdout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// printWatchChanges prints one JSON object per line, or one YAML document per change
+func printWatchChanges(changes []watchChange) error {
+	for _, ch := range changes {
+		switch outputFormat {
+		case outputYAML:
+			fmt.Println("---")
+			if err := fprintYAML(os.Stdout, ch); err != nil {
+				return err
+			}
+		default:
+			txt, err := json.Marshal(ch)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(txt))
+		}
+	}
+	return nil
+}
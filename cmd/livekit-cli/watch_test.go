@@ -0,0 +1,75 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchView(t *testing.T) {
+	row := func(key, status string) watchRow {
+		return watchRow{key: key, label: key, status: status, fields: []string{key, status}}
+	}
+	v := &watchView{title: "list-egress", header: []string{"EgressID", "Status"}, statusColumn: 1}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	changes := v.update([]watchRow{row("EG_1", "EGRESS_ACTIVE"), row("EG_2", "EGRESS_ACTIVE")}, now)
+	require.Len(t, changes, 2)
+	require.Equal(t, watchListed, changes[0].Type)
+	require.Empty(t, v.log)
+
+	changes = v.update([]watchRow{row("EG_1", "EGRESS_ENDING"), row("EG_3", "EGRESS_STARTING")}, now.Add(time.Second))
+	require.Equal(t, []watchChange{
+		{At: now.Add(time.Second), Type: watchChanged, Key: "EG_1", From: "EGRESS_ACTIVE", To: "EGRESS_ENDING", label: "EG_1"},
+		{At: now.Add(time.Second), Type: watchAdded, Key: "EG_3", To: "EGRESS_STARTING", label: "EG_3"},
+		{At: now.Add(time.Second), Type: watchRemoved, Key: "EG_2", From: "EGRESS_ACTIVE", label: "EG_2"},
+	}, changes)
+
+	var buf bytes.Buffer
+	v.render(&buf)
+	lines := strings.Split(buf.String(), "\n")
+	require.Equal(t, "list-egress, updated 12:00:01", lines[0])
+	require.Equal(t, "   EgressID  Status", lines[2])
+	require.Equal(t, "~  EG_1      EGRESS_ACTIVE -> EGRESS_ENDING", lines[3])
+	require.Equal(t, "+  EG_3      EGRESS_STARTING", lines[4])
+	require.Equal(t, "-  EG_2      EGRESS_ACTIVE", lines[5])
+	require.Contains(t, buf.String(), "12:00:01  ~ EG_1 EGRESS_ACTIVE -> EGRESS_ENDING")
+
+	// removed entries are only shown for one poll, and unchanged entries aren't highlighted
+	changes = v.update([]watchRow{row("EG_1", "EGRESS_ENDING"), row("EG_3", "EGRESS_STARTING")}, now.Add(2*time.Second))
+	require.Empty(t, changes)
+	buf.Reset()
+	v.render(&buf)
+	require.NotContains(t, buf.String(), "-  EG_2")
+	require.Contains(t, buf.String(), "   EG_1      EGRESS_ENDING")
+	require.Len(t, v.log, 3)
+}
+
+func TestWatchViewMultilineFields(t *testing.T) {
+	v := &watchView{title: "list-egress", header: []string{"EgressID", "Status", "Error"}, statusColumn: 1, color: true}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	v.update([]watchRow{{key: "EG_1", label: "EG_1", status: "EGRESS_ACTIVE", fields: []string{"EG_1", "EGRESS_ACTIVE", ""}}}, now)
+	v.update([]watchRow{{key: "EG_1", label: "EG_1", status: "EGRESS_FAILED", fields: []string{"EG_1", "EGRESS_FAILED", "pipeline failed:\nmissing\ttrack"}}}, now.Add(time.Second))
+
+	var buf bytes.Buffer
+	v.render(&buf)
+	lines := strings.Split(buf.String(), "\n")
+	require.Equal(t, "\033[33m~  EG_1      EGRESS_ACTIVE -> EGRESS_FAILED  pipeline failed: missing track\033[0m", lines[3])
+}
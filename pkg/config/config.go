@@ -26,6 +26,8 @@ import (
 type CLIConfig struct {
 	DefaultProject string          `yaml:"default_project"`
 	Projects       []ProjectConfig `yaml:"projects"`
+	// token templates, which override the built-in ones of the same name
+	TokenTemplates []TokenTemplate `yaml:"token_templates,omitempty"`
 	// absent from YAML
 	hasPersisted bool
 }
@@ -37,6 +39,37 @@ type ProjectConfig struct {
 	APISecret string `yaml:"api_secret"`
 }
 
+// TokenTemplate is a named set of permissions for create-token
+type TokenTemplate struct {
+	Name string `yaml:"name"`
+	// names of create-token permission flags, i.e. join, admin, can-subscribe
+	Permissions []string `yaml:"permissions,omitempty"`
+	// additional VideoGrant fields, as in --grant
+	Grant    map[string]interface{} `yaml:"grant,omitempty"`
+	ValidFor string                 `yaml:"valid_for,omitempty"`
+}
+
+var DefaultTokenTemplates = []TokenTemplate{
+	{
+		Name:        "viewer",
+		Permissions: []string{"join"},
+		Grant:       map[string]interface{}{"canPublish": false, "canPublishData": false, "canSubscribe": true},
+	},
+	{
+		Name:        "host",
+		Permissions: []string{"join", "admin", "allow-update-metadata"},
+	},
+	{
+		Name:        "recorder",
+		Permissions: []string{"join", "recorder"},
+		Grant:       map[string]interface{}{"canPublish": false, "canSubscribe": true},
+	},
+	{
+		Name:        "agent",
+		Permissions: []string{"join", "agent"},
+	},
+}
+
 func LoadDefaultProject() (*ProjectConfig, error) {
 	conf, err := LoadOrCreate()
 	if err != nil {
@@ -70,6 +103,22 @@ func LoadProject(name string) (*ProjectConfig, error) {
 	return nil, errors.New("project not found")
 }
 
+// LoadTokenTemplate returns the template from the config file, or one of DefaultTokenTemplates
+func LoadTokenTemplate(name string) (*TokenTemplate, error) {
+	conf, err := LoadOrCreate()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range append(conf.TokenTemplates, DefaultTokenTemplates...) {
+		if t.Name == name {
+			return &t, nil
+		}
+	}
+
+	return nil, errors.New("token template not found")
+}
+
 // LoadOrCreate loads config file from ~/.livekit/cli-config.yaml
 // if it doesn't exist, it'll return an empty config file
 func LoadOrCreate() (*CLIConfig, error) {
@@ -123,7 +172,7 @@ func (c *CLIConfig) PersistIfNeeded() error {
 	if err = os.WriteFile(configPath, data, 0600); err != nil {
 		return err
 	}
-	fmt.Println("Saved CLI config to", configPath)
+	fmt.Fprintln(os.Stderr, "Saved CLI config to", configPath)
 	return nil
 }
 
@@ -0,0 +1,264 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// ArrivalModel decides when testers join
+type ArrivalModel string
+
+const (
+	// ArrivalConstant - testers join at NumPerSecond, evenly spaced
+	ArrivalConstant ArrivalModel = "constant"
+	// ArrivalPoisson - testers join at NumPerSecond on average, with exponentially distributed gaps
+	ArrivalPoisson ArrivalModel = "poisson"
+	// ArrivalStep - StepSize testers join at once, every StepInterval
+	ArrivalStep ArrivalModel = "step"
+	// ArrivalBurst - BurstSize testers join at once, the rest follow at NumPerSecond
+	ArrivalBurst ArrivalModel = "burst"
+)
+
+func ArrivalModelFromString(str string) (ArrivalModel, error) {
+	switch m := ArrivalModel(str); m {
+	case "", ArrivalConstant:
+		return ArrivalConstant, nil
+	case ArrivalPoisson, ArrivalStep, ArrivalBurst:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown arrival model: %s", str)
+	}
+}
+
+type ArrivalParams struct {
+	Model        ArrivalModel  `json:"model"`
+	StepSize     int           `json:"step_size,omitempty"`
+	StepInterval time.Duration `json:"step_interval,omitempty"`
+	// all testers join at once when 0
+	BurstSize int `json:"burst_size,omitempty"`
+}
+
+// split divides group sizes between agents, which join at the same time
+func (p ArrivalParams) split(agents int) ArrivalParams {
+	p.StepSize = (p.StepSize + agents - 1) / agents
+	p.BurstSize = (p.BurstSize + agents - 1) / agents
+	return p
+}
+
+// arrivalSchedule returns the delay before each tester joins, counted from the previous one
+type arrivalSchedule struct {
+	params       ArrivalParams
+	numPerSecond float64
+	rnd          *rand.Rand
+}
+
+func newArrivalSchedule(params ArrivalParams, numPerSecond float64) *arrivalSchedule {
+	return &arrivalSchedule{
+		params:       params,
+		numPerSecond: numPerSecond,
+		rnd:          rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// delay returns how long after tester i-1 tester i joins
+func (s *arrivalSchedule) delay(i int) time.Duration {
+	if i == 0 {
+		return 0
+	}
+	perTester := time.Duration(float64(time.Second) / s.numPerSecond)
+	switch s.params.Model {
+	case ArrivalPoisson:
+		return time.Duration(s.rnd.ExpFloat64() * float64(perTester))
+	case ArrivalStep:
+		if i%max(s.params.StepSize, 1) != 0 {
+			return 0
+		}
+		return s.params.StepInterval
+	case ArrivalBurst:
+		if s.params.BurstSize <= 0 || i < s.params.BurstSize {
+			return 0
+		}
+		return perTester
+	default:
+		return perTester
+	}
+}
+
+// arrivalPacer waits for each tester's turn to join. Delays are added up from the start, so that slow starts don't add drift
+type arrivalPacer struct {
+	schedule *arrivalSchedule
+	next     time.Time
+	count    int
+}
+
+func newArrivalPacer(params ArrivalParams, numPerSecond float64) *arrivalPacer {
+	return &arrivalPacer{
+		schedule: newArrivalSchedule(params, numPerSecond),
+		next:     time.Now(),
+	}
+}
+
+func (p *arrivalPacer) wait(ctx context.Context) error {
+	p.next = p.next.Add(p.schedule.delay(p.count))
+	p.count++
+	d := time.Until(p.next)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// rampTracker follows joins that have started but not yet completed
+type rampTracker struct {
+	startedAt time.Time
+
+	lock     sync.Mutex
+	inFlight int
+	joined   int
+	failed   int
+	samples  []rampSample
+}
+
+type rampSample struct {
+	at       time.Duration
+	inFlight int
+	joined   int
+}
+
+func newRampTracker() *rampTracker {
+	return &rampTracker{startedAt: time.Now()}
+}
+
+func (r *rampTracker) joinStarted() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.inFlight++
+	r.sample()
+}
+
+func (r *rampTracker) joinEnded(err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.inFlight--
+	if err != nil {
+		r.failed++
+	} else {
+		r.joined++
+	}
+	r.sample()
+}
+
+// sample records the current counts, r.lock must be held
+func (r *rampTracker) sample() {
+	r.samples = append(r.samples, rampSample{
+		at:       time.Since(r.startedAt),
+		inFlight: r.inFlight,
+		joined:   r.joined,
+	})
+}
+
+func (r *rampTracker) joinsInFlight() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.inFlight
+}
+
+// timeline splits the ramp into buckets of width, with the most joins in flight during each bucket
+func (r *rampTracker) timeline(width time.Duration) []rampSample {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var buckets []rampSample
+	// counts as of the latest sample, which carry over to buckets without changes
+	inFlight, joined := 0, 0
+	for _, s := range r.samples {
+		i := int(s.at / width)
+		for len(buckets) <= i {
+			buckets = append(buckets, rampSample{
+				at:       time.Duration(len(buckets)+1) * width,
+				inFlight: inFlight,
+				joined:   joined,
+			})
+		}
+		buckets[i].inFlight = max(buckets[i].inFlight, s.inFlight)
+		buckets[i].joined = s.joined
+		inFlight, joined = s.inFlight, s.joined
+	}
+	return buckets
+}
+
+func (r *rampTracker) toReport() *RampReport {
+	r.lock.Lock()
+	report := &RampReport{
+		Joined: r.joined,
+		Failed: r.failed,
+	}
+	for _, s := range r.samples {
+		if s.inFlight > report.PeakInFlight {
+			report.PeakInFlight = s.inFlight
+			report.PeakAtMs = s.at.Milliseconds()
+		}
+		report.DurationMs = s.at.Milliseconds()
+	}
+	r.lock.Unlock()
+
+	for _, b := range r.timeline(rampReportInterval) {
+		report.Timeline = append(report.Timeline, &RampSampleReport{
+			ElapsedMs: b.at.Milliseconds(),
+			InFlight:  b.inFlight,
+			Joined:    b.joined,
+		})
+	}
+	return report
+}
+
+// the JSON report has the most joins in flight during each interval
+const rampReportInterval = 100 * time.Millisecond
+
+func printRampResults(out io.Writer, r *RampReport) {
+	if r == nil || len(r.Timeline) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "\nRamp: %d joined, %d failed in %s, peak of %d joins in flight at %s\n",
+		r.Joined, r.Failed, time.Duration(r.DurationMs)*time.Millisecond,
+		r.PeakInFlight, time.Duration(r.PeakAtMs)*time.Millisecond)
+
+	// at most 20 rows
+	step := (len(r.Timeline) + 19) / 20
+	w := tabwriter.NewWriter(out, 1, 1, 1, ' ', 0)
+	_, _ = fmt.Fprint(w, "Elapsed\t| In Flight (max)\t| Joined\n")
+	for i := 0; i < len(r.Timeline); i += step {
+		end := min(i+step, len(r.Timeline))
+		inFlight := 0
+		for _, s := range r.Timeline[i:end] {
+			inFlight = max(inFlight, s.InFlight)
+		}
+		last := r.Timeline[end-1]
+		_, _ = fmt.Fprintf(w, "%s\t| %d\t| %d\n",
+			time.Duration(last.ElapsedMs)*time.Millisecond, inFlight, last.Joined)
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,93 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestArrivalSchedule(t *testing.T) {
+	constant := newArrivalSchedule(ArrivalParams{Model: ArrivalConstant}, 4)
+	require.Equal(t, time.Duration(0), constant.delay(0))
+	require.Equal(t, 250*time.Millisecond, constant.delay(1))
+
+	step := newArrivalSchedule(ArrivalParams{Model: ArrivalStep, StepSize: 3, StepInterval: time.Second}, 4)
+	var delays []time.Duration
+	for i := 0; i < 7; i++ {
+		delays = append(delays, step.delay(i))
+	}
+	require.Equal(t, []time.Duration{0, 0, 0, time.Second, 0, 0, time.Second}, delays)
+
+	burst := newArrivalSchedule(ArrivalParams{Model: ArrivalBurst, BurstSize: 2}, 4)
+	require.Equal(t, time.Duration(0), burst.delay(1))
+	require.Equal(t, 250*time.Millisecond, burst.delay(2))
+
+	all := newArrivalSchedule(ArrivalParams{Model: ArrivalBurst}, 4)
+	require.Equal(t, time.Duration(0), all.delay(100))
+
+	poisson := newArrivalSchedule(ArrivalParams{Model: ArrivalPoisson}, 100)
+	var total time.Duration
+	for i := 1; i <= 1000; i++ {
+		total += poisson.delay(i)
+	}
+	require.InDelta(t, float64(10*time.Second), float64(total), float64(2*time.Second))
+}
+
+func TestArrivalSplit(t *testing.T) {
+	p := ArrivalParams{Model: ArrivalStep, StepSize: 10, BurstSize: 5}.split(3)
+	require.Equal(t, 4, p.StepSize)
+	require.Equal(t, 2, p.BurstSize)
+}
+
+func TestRampTracker(t *testing.T) {
+	startedAt := time.Now()
+	r := &rampTracker{startedAt: startedAt}
+	r.samples = []rampSample{
+		{at: 10 * time.Millisecond, inFlight: 1},
+		{at: 20 * time.Millisecond, inFlight: 2},
+		{at: 150 * time.Millisecond, inFlight: 1, joined: 1},
+		{at: 350 * time.Millisecond, inFlight: 0, joined: 2},
+	}
+	r.joined = 2
+
+	timeline := r.timeline(100 * time.Millisecond)
+	require.Len(t, timeline, 4)
+	require.Equal(t, 2, timeline[0].inFlight)
+	// joins in flight when the bucket started count towards its max
+	require.Equal(t, 2, timeline[1].inFlight)
+	// no changes, counts carry over
+	require.Equal(t, 1, timeline[2].inFlight)
+	require.Equal(t, 1, timeline[2].joined)
+	require.Equal(t, 2, timeline[3].joined)
+
+	report := r.toReport()
+	require.Equal(t, 2, report.PeakInFlight)
+	require.Equal(t, int64(20), report.PeakAtMs)
+	require.Equal(t, int64(350), report.DurationMs)
+	require.Len(t, report.Timeline, 4)
+
+	live := newRampTracker()
+	live.joinStarted()
+	live.joinStarted()
+	require.Equal(t, 2, live.joinsInFlight())
+	live.joinEnded(nil)
+	live.joinEnded(errors.New("failed"))
+	require.Equal(t, 0, live.joinsInFlight())
+	require.Equal(t, 1, live.toReport().Failed)
+}
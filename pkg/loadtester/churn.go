@@ -0,0 +1,368 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ChurnParams makes a fraction of testers leave and come back while the test is running
+type ChurnParams struct {
+	// fraction of publishers and subscribers that churn, 0 to disable
+	Fraction float64
+	// average time between churn events of a tester, also the length of a reporting cycle
+	Interval time.Duration
+}
+
+func (p ChurnParams) enabled() bool {
+	return p.Fraction > 0 && p.Interval > 0
+}
+
+type churnAction int
+
+const (
+	churnRejoin churnAction = iota
+	churnRepublish
+	churnMute
+)
+
+// churnCycle holds the churn events that started during one interval
+type churnCycle struct {
+	joins        int
+	joinFailures int
+	joinLatency  latencyStats
+	republishes  int
+	mutes        int
+	failures     int
+	reconnects   int64
+}
+
+func (c *churnCycle) successRate() float64 {
+	if c.joins+c.joinFailures == 0 {
+		return 1
+	}
+	return float64(c.joins) / float64(c.joins+c.joinFailures)
+}
+
+type churner struct {
+	test      *LoadTest
+	params    ChurnParams
+	testers   []*LoadTester
+	startedAt time.Time
+
+	lock   sync.Mutex
+	cycles []*churnCycle
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// startChurn picks the churning testers and starts their events, until stop is called
+func (t *LoadTest) startChurn(ctx context.Context, params ChurnParams, testers []*LoadTester) *churner {
+	n := int(params.Fraction*float64(len(testers)) + 0.5)
+	n = min(max(n, 1), len(testers))
+	picked := make([]*LoadTester, len(testers))
+	copy(picked, testers)
+	rand.Shuffle(len(picked), func(i, j int) {
+		picked[i], picked[j] = picked[j], picked[i]
+	})
+	picked = picked[:n]
+
+	ctx, cancel := context.WithCancel(ctx)
+	c := &churner{
+		test:      t,
+		params:    params,
+		testers:   picked,
+		startedAt: time.Now(),
+		cancel:    cancel,
+	}
+	fmt.Fprintf(t.Params.output(), "Churning %d of %d testers, every %s on average\n", n, len(testers), params.Interval)
+
+	for _, tester := range picked {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.churn(ctx, tester)
+		}()
+	}
+
+	// reconnects are sampled at the end of each cycle, across all testers
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(params.Interval)
+		defer ticker.Stop()
+		last := totalReconnects(testers)
+		for {
+			select {
+			case <-ctx.Done():
+				c.lock.Lock()
+				c.cycleAt(time.Now()).reconnects += totalReconnects(testers) - last
+				c.lock.Unlock()
+				return
+			case now := <-ticker.C:
+				total := totalReconnects(testers)
+				c.lock.Lock()
+				// attributed to the cycle that just ended
+				c.cycleAt(now.Add(-params.Interval / 2)).reconnects += total - last
+				c.lock.Unlock()
+				last = total
+			}
+		}
+	}()
+	return c
+}
+
+func (c *churner) stop() {
+	c.cancel()
+	c.wg.Wait()
+}
+
+func (c *churner) churn(ctx context.Context, tester *LoadTester) {
+	isPublisher := !tester.params.Subscribe
+	for {
+		// 0.5x to 1.5x the interval, so that testers do not churn in lockstep
+		wait := c.params.Interval/2 + time.Duration(rand.Int63n(int64(c.params.Interval)))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		action := churnRejoin
+		if isPublisher {
+			action = churnAction(rand.Intn(3))
+		}
+		now := time.Now()
+		switch action {
+		case churnRejoin:
+			sids, err := tester.Rejoin()
+			c.test.renameTracks(sids)
+			c.lock.Lock()
+			cycle := c.cycleAt(now)
+			if err != nil {
+				cycle.joinFailures++
+				fmt.Fprintln(c.test.Params.output(), errors.Wrapf(err, "could not rejoin %s", tester.params.name))
+			} else {
+				cycle.joins++
+				cycle.joinLatency.add(time.Since(now))
+			}
+			c.lock.Unlock()
+
+		case churnRepublish:
+			oldSID, newSID, err := tester.Republish()
+			if newSID != "" {
+				c.test.renameTracks(map[string]string{oldSID: newSID})
+			}
+			c.lock.Lock()
+			if err != nil {
+				c.cycleAt(now).failures++
+				fmt.Fprintln(c.test.Params.output(), errors.Wrapf(err, "could not republish %s", tester.params.name))
+			} else {
+				c.cycleAt(now).republishes++
+			}
+			c.lock.Unlock()
+
+		case churnMute:
+			err := tester.MuteTrack(ctx, c.params.Interval/4)
+			c.lock.Lock()
+			if err != nil {
+				c.cycleAt(now).failures++
+			} else {
+				c.cycleAt(now).mutes++
+			}
+			c.lock.Unlock()
+		}
+	}
+}
+
+// cycleAt returns the cycle at a point in time, c.lock must be held
+func (c *churner) cycleAt(at time.Time) *churnCycle {
+	i := max(int(at.Sub(c.startedAt)/c.params.Interval), 0)
+	for len(c.cycles) <= i {
+		c.cycles = append(c.cycles, &churnCycle{})
+	}
+	return c.cycles[i]
+}
+
+func totalReconnects(testers []*LoadTester) int64 {
+	var total int64
+	for _, tester := range testers {
+		total += tester.reconnects.Load()
+	}
+	return total
+}
+
+// renameTracks carries track names and stats over to tracks that were published again, so that each is counted once
+func (t *LoadTest) renameTracks(sids map[string]string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for oldSID, newSID := range sids {
+		if name, ok := t.trackNames[oldSID]; ok {
+			t.trackNames[newSID] = name
+		}
+		for _, tester := range t.testers {
+			tester.renameTrack(oldSID, newSID)
+		}
+	}
+}
+
+// renameTrack moves the stats of a subscribed track to the SID it was published again with,
+// merging them with stats of the new track if it was already subscribed. The LoadTest lock must be held
+func (t *LoadTester) renameTrack(oldSID, newSID string) {
+	value, ok := t.stats.LoadAndDelete(oldSID)
+	if !ok {
+		return
+	}
+	old := value.(*trackStats)
+	old.trackID = newSID
+	if current, loaded := t.stats.LoadOrStore(newSID, old); loaded {
+		current.(*trackStats).merge(old)
+	}
+}
+
+// Rejoin disconnects and joins again, publishing the same tracks. Returns new track SIDs by their previous SID
+func (t *LoadTester) Rejoin() (map[string]string, error) {
+	t.rejoinLock.Lock()
+	defer t.rejoinLock.Unlock()
+
+	t.disconnect()
+	t.lock.Lock()
+	published := t.published
+	t.published = make(map[string]*publishedTrack)
+	t.lock.Unlock()
+
+	if err := t.Start(); err != nil {
+		// kept to be published on the next rejoin
+		t.lock.Lock()
+		t.published = published
+		t.lock.Unlock()
+		return nil, err
+	}
+	sids := make(map[string]string)
+	for oldSID, p := range published {
+		sid, err := t.publish(p.publish)
+		if err != nil {
+			return sids, err
+		}
+		sids[oldSID] = sid
+	}
+	return sids, nil
+}
+
+// Republish unpublishes a random track and publishes it again
+func (t *LoadTester) Republish() (string, string, error) {
+	if !t.IsRunning() {
+		return "", "", errors.New("not connected")
+	}
+	p := t.randomPublishedTrack(true)
+	if p == nil {
+		return "", "", errors.New("no published tracks")
+	}
+	oldSID := p.pub.SID()
+	if err := t.getRoom().LocalParticipant.UnpublishTrack(oldSID); err != nil {
+		t.lock.Lock()
+		t.published[oldSID] = p
+		t.lock.Unlock()
+		return "", "", err
+	}
+	sid, err := t.publish(p.publish)
+	return oldSID, sid, err
+}
+
+// MuteTrack mutes a random track, then unmutes it after d
+func (t *LoadTester) MuteTrack(ctx context.Context, d time.Duration) error {
+	if !t.IsRunning() {
+		return errors.New("not connected")
+	}
+	p := t.randomPublishedTrack(false)
+	if p == nil {
+		return errors.New("no published tracks")
+	}
+	p.pub.SetMuted(true)
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+	p.pub.SetMuted(false)
+	return nil
+}
+
+// randomPublishedTrack picks one of the published tracks, optionally forgetting it
+func (t *LoadTester) randomPublishedTrack(remove bool) *publishedTrack {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if len(t.published) == 0 {
+		return nil
+	}
+	i := rand.Intn(len(t.published))
+	for sid, p := range t.published {
+		if i == 0 {
+			if remove {
+				delete(t.published, sid)
+			}
+			return p
+		}
+		i--
+	}
+	return nil
+}
+
+func (c *churner) toReport() []*ChurnCycleReport {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var reports []*ChurnCycleReport
+	for i, cycle := range c.cycles {
+		reports = append(reports, &ChurnCycleReport{
+			Cycle:        i + 1,
+			Joins:        cycle.joins,
+			JoinFailures: cycle.joinFailures,
+			SuccessRate:  cycle.successRate(),
+			JoinP50Ms:    cycle.joinLatency.percentile(50).Milliseconds(),
+			JoinP95Ms:    cycle.joinLatency.percentile(95).Milliseconds(),
+			Republishes:  cycle.republishes,
+			Mutes:        cycle.mutes,
+			Failures:     cycle.failures,
+			Reconnects:   cycle.reconnects,
+		})
+	}
+	return reports
+}
+
+func printChurnResults(out io.Writer, cycles []*ChurnCycleReport) {
+	if len(cycles) == 0 {
+		return
+	}
+	w := tabwriter.NewWriter(out, 1, 1, 1, ' ', 0)
+	_, _ = fmt.Fprint(w, "\nChurn\t| Cycle\t| Rejoins\t| Success\t| Join (p50/p95)\t| Republishes\t| Mutes\t| Failures\t| Reconnects\n")
+	for _, cycle := range cycles {
+		joinLatency := "-"
+		if cycle.Joins > 0 {
+			joinLatency = fmt.Sprintf("%dms/%dms", cycle.JoinP50Ms, cycle.JoinP95Ms)
+		}
+		_, _ = fmt.Fprintf(w, "\t| %d\t| %d/%d\t| %.1f%%\t| %s\t| %d\t| %d\t| %d\t| %d\n",
+			cycle.Cycle, cycle.Joins, cycle.Joins+cycle.JoinFailures, 100*cycle.SuccessRate, joinLatency,
+			cycle.Republishes, cycle.Mutes, cycle.Failures, cycle.Reconnects)
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,80 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChurnCycles(t *testing.T) {
+	startedAt := time.Now()
+	c := &churner{
+		params:    ChurnParams{Fraction: 0.5, Interval: 10 * time.Second},
+		startedAt: startedAt,
+	}
+
+	first := c.cycleAt(startedAt.Add(time.Second))
+	first.joins++
+	first.joinLatency.add(200 * time.Millisecond)
+	first.joinFailures++
+
+	third := c.cycleAt(startedAt.Add(25 * time.Second))
+	third.joins++
+	third.joinLatency.add(300 * time.Millisecond)
+	third.reconnects = 2
+	require.Len(t, c.cycles, 3)
+	require.Same(t, first, c.cycleAt(startedAt))
+
+	reports := c.toReport()
+	require.Len(t, reports, 3)
+	require.Equal(t, 1, reports[0].Cycle)
+	require.Equal(t, 0.5, reports[0].SuccessRate)
+	require.Equal(t, int64(200), reports[0].JoinP95Ms)
+	require.Equal(t, 1.0, reports[1].SuccessRate)
+	require.Equal(t, int64(300), reports[2].JoinP50Ms)
+	require.Equal(t, int64(2), reports[2].Reconnects)
+}
+
+func TestRenameTracks(t *testing.T) {
+	test := NewLoadTest(Params{})
+	test.trackNames["TR_old"] = "0V"
+
+	// one subscriber already received the new track, the other one not yet
+	subscribed := NewLoadTester(TesterParams{})
+	pending := NewLoadTester(TesterParams{})
+	for _, tester := range []*LoadTester{subscribed, pending} {
+		old := &trackStats{trackID: "TR_old"}
+		old.packets.Add(100)
+		tester.stats.Store("TR_old", old)
+	}
+	current := &trackStats{trackID: "TR_new"}
+	current.packets.Add(20)
+	subscribed.stats.Store("TR_new", current)
+	test.testers = []*LoadTester{subscribed, pending}
+
+	test.renameTracks(map[string]string{"TR_old": "TR_new"})
+	require.Equal(t, "0V", test.trackNames["TR_new"])
+	for _, tester := range test.testers {
+		stats := tester.getStats()
+		require.Len(t, stats.trackStats, 1)
+		require.Contains(t, stats.trackStats, "TR_new")
+		require.Equal(t, "TR_new", stats.trackStats["TR_new"].trackID)
+	}
+	require.Equal(t, int64(120), subscribed.getStats().trackStats["TR_new"].packets.Load())
+	require.Equal(t, int64(100), pending.getStats().trackStats["TR_new"].packets.Load())
+}
@@ -0,0 +1,63 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"strings"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v3"
+)
+
+// codecName returns a short name for a mime type, such as vp8 for video/VP8
+func codecName(mimeType string) string {
+	if i := strings.IndexByte(mimeType, '/'); i >= 0 {
+		mimeType = mimeType[i+1:]
+	}
+	return strings.ToLower(mimeType)
+}
+
+// depacketizerForCodec returns a depacketizer for the mime type of a track, nil if it isn't supported
+func depacketizerForCodec(mimeType string) rtp.Depacketizer {
+	switch {
+	case strings.EqualFold(mimeType, webrtc.MimeTypeVP8):
+		return &codecs.VP8Packet{}
+	case strings.EqualFold(mimeType, webrtc.MimeTypeVP9):
+		return &codecs.VP9Packet{}
+	case strings.EqualFold(mimeType, webrtc.MimeTypeH264):
+		return &codecs.H264Packet{}
+	case strings.EqualFold(mimeType, webrtc.MimeTypeAV1):
+		return &av1Depacketizer{}
+	case strings.EqualFold(mimeType, webrtc.MimeTypeOpus):
+		return &codecs.OpusPacket{}
+	default:
+		return nil
+	}
+}
+
+// av1Depacketizer adds partition checks to codecs.AV1Packet, which doesn't implement them
+type av1Depacketizer struct {
+	codecs.AV1Packet
+}
+
+// IsPartitionHead is true unless the first OBU element continues a fragment from the previous packet
+func (d *av1Depacketizer) IsPartitionHead(payload []byte) bool {
+	return len(payload) > 0 && payload[0]&0x80 == 0
+}
+
+func (d *av1Depacketizer) IsPartitionTail(marker bool, _ []byte) bool {
+	return marker
+}
@@ -0,0 +1,36 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"testing"
+
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDepacketizerForCodec(t *testing.T) {
+	require.IsType(t, &codecs.VP8Packet{}, depacketizerForCodec(webrtc.MimeTypeVP8))
+	require.IsType(t, &codecs.VP8Packet{}, depacketizerForCodec("video/vp8"))
+	require.IsType(t, &codecs.VP9Packet{}, depacketizerForCodec(webrtc.MimeTypeVP9))
+	require.IsType(t, &codecs.H264Packet{}, depacketizerForCodec(webrtc.MimeTypeH264))
+	require.IsType(t, &av1Depacketizer{}, depacketizerForCodec(webrtc.MimeTypeAV1))
+	require.IsType(t, &codecs.OpusPacket{}, depacketizerForCodec(webrtc.MimeTypeOpus))
+	require.Nil(t, depacketizerForCodec(webrtc.MimeTypeG722))
+
+	require.Equal(t, "h264", codecName(webrtc.MimeTypeH264))
+	require.Equal(t, "opus", codecName(webrtc.MimeTypeOpus))
+}
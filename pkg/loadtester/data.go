@@ -0,0 +1,360 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	lksdk "github.com/livekit/server-sdk-go/v2"
+)
+
+// DataMode is the delivery mode of data messages
+type DataMode string
+
+const (
+	DataReliable DataMode = "reliable"
+	DataLossy    DataMode = "lossy"
+)
+
+func DataModeFromString(str string) (DataMode, error) {
+	switch m := DataMode(str); m {
+	case DataReliable, DataLossy:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown data mode: %s", str)
+	}
+}
+
+// MaxDataRate is the highest rate of data messages, one every millisecond
+const MaxDataRate = 1000
+
+// DataParams makes publishers send data messages alongside their tracks
+type DataParams struct {
+	// number of testers sending data, counted among publishers
+	Publishers int `json:"publishers"`
+	// bytes per message, at least DataHeaderSize
+	Size int `json:"size"`
+	// messages per second sent by each publisher, on each topic and in each mode, at most MaxDataRate
+	Rate   float64    `json:"rate"`
+	Topics []string   `json:"topics,omitempty"`
+	Modes  []DataMode `json:"modes"`
+}
+
+func (p DataParams) enabled() bool {
+	return p.Publishers > 0 && p.Rate > 0 && len(p.Modes) > 0
+}
+
+// data messages start with a header, followed by padding up to the configured size:
+// magic byte, mode, sequence number of the sender's stream, and send time in unix nanoseconds
+const (
+	dataMagic      = 0xd7
+	DataHeaderSize = 18
+)
+
+type dataMessage struct {
+	mode     DataMode
+	sequence uint64
+	sentAt   time.Time
+}
+
+func encodeDataMessage(buf []byte, msg dataMessage) {
+	buf[0] = dataMagic
+	buf[1] = 0
+	if msg.mode == DataReliable {
+		buf[1] = 1
+	}
+	binary.BigEndian.PutUint64(buf[2:], msg.sequence)
+	binary.BigEndian.PutUint64(buf[10:], uint64(msg.sentAt.UnixNano()))
+}
+
+func parseDataMessage(payload []byte) (dataMessage, bool) {
+	if len(payload) < DataHeaderSize || payload[0] != dataMagic {
+		return dataMessage{}, false
+	}
+	msg := dataMessage{
+		mode:     DataLossy,
+		sequence: binary.BigEndian.Uint64(payload[2:]),
+		sentAt:   time.Unix(0, int64(binary.BigEndian.Uint64(payload[10:]))),
+	}
+	if payload[1] == 1 {
+		msg.mode = DataReliable
+	}
+	return msg, true
+}
+
+// dataStats counts the data messages of one mode sent or received by a tester
+type dataStats struct {
+	sent       int64
+	received   int64
+	lost       int64
+	outOfOrder int64
+	// time from sending to receiving, based on the embedded send time
+	delay latencyStats
+}
+
+func (s *dataStats) merge(other *dataStats) {
+	s.sent += other.sent
+	s.received += other.received
+	s.lost += other.lost
+	s.outOfOrder += other.outOfOrder
+	s.delay.merge(&other.delay)
+}
+
+type dataStreamKey struct {
+	sender string
+	topic  string
+	mode   DataMode
+}
+
+// dataStream follows the sequence numbers received from one sender, on one topic and in one mode
+type dataStream struct {
+	lowest   uint64
+	highest  uint64
+	received int64
+}
+
+// dataTracker counts the data messages of a tester. It is kept across rejoins
+type dataTracker struct {
+	lock    sync.Mutex
+	sent    map[DataMode]int64
+	streams map[dataStreamKey]*dataStream
+	// mode => counters of received messages, without losses
+	received map[DataMode]*dataStats
+}
+
+func newDataTracker() *dataTracker {
+	return &dataTracker{
+		sent:     make(map[DataMode]int64),
+		streams:  make(map[dataStreamKey]*dataStream),
+		received: make(map[DataMode]*dataStats),
+	}
+}
+
+func (d *dataTracker) messageSent(mode DataMode) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.sent[mode]++
+}
+
+func (d *dataTracker) messageReceived(sender, topic string, msg dataMessage, now time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	s := d.received[msg.mode]
+	if s == nil {
+		s = &dataStats{}
+		d.received[msg.mode] = s
+	}
+	s.received++
+	s.delay.add(now.Sub(msg.sentAt))
+
+	key := dataStreamKey{sender: sender, topic: topic, mode: msg.mode}
+	stream := d.streams[key]
+	if stream == nil {
+		d.streams[key] = &dataStream{lowest: msg.sequence, highest: msg.sequence, received: 1}
+		return
+	}
+	stream.received++
+	if msg.sequence <= stream.highest {
+		s.outOfOrder++
+	} else {
+		stream.highest = msg.sequence
+	}
+	stream.lowest = min(stream.lowest, msg.sequence)
+}
+
+// stats returns the counters of each mode. Messages missing between the first and last one received from a stream are lost.
+// Messages after the last one received, or from senders that were never heard from, can't be told apart from messages
+// that weren't sent, and aren't counted
+func (d *dataTracker) stats() map[DataMode]*dataStats {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	stats := make(map[DataMode]*dataStats)
+	get := func(mode DataMode) *dataStats {
+		if stats[mode] == nil {
+			stats[mode] = &dataStats{}
+		}
+		return stats[mode]
+	}
+	for mode, sent := range d.sent {
+		get(mode).sent = sent
+	}
+	for mode, received := range d.received {
+		s := get(mode)
+		s.received = received.received
+		s.outOfOrder = received.outOfOrder
+		s.delay.merge(&received.delay)
+	}
+	for key, stream := range d.streams {
+		span := int64(stream.highest - stream.lowest + 1)
+		get(key.mode).lost += max(span-stream.received, 0)
+	}
+	return stats
+}
+
+// PublishData sends data messages as configured in the tester params, until the tester is stopped
+func (t *LoadTester) PublishData() {
+	params := t.params.Data
+	if params == nil || !t.IsRunning() {
+		return
+	}
+	if !t.publishingData.CompareAndSwap(false, true) {
+		return
+	}
+
+	fmt.Fprintln(t.params.output(), "publishing data -", t.identity())
+	go t.sendData(params, t.stopped())
+}
+
+func (t *LoadTester) sendData(params *DataParams, done chan struct{}) {
+	topics := params.Topics
+	if len(topics) == 0 {
+		topics = []string{""}
+	}
+	// sequence numbers only advance when a message is sent, so that messages not sent while rejoining are not counted as lost
+	sequences := make(map[dataStreamKey]uint64)
+	buf := make([]byte, max(params.Size, DataHeaderSize))
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / params.Rate))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		// waits for a rejoin to finish, messages are not sent while disconnected
+		t.withRoom(func(room *lksdk.Room) {
+			for _, mode := range params.Modes {
+				for _, topic := range topics {
+					key := dataStreamKey{topic: topic, mode: mode}
+					encodeDataMessage(buf, dataMessage{mode: mode, sequence: sequences[key], sentAt: syncedNow()})
+					// the SDK keeps the payload, so each message gets its own copy
+					err := room.LocalParticipant.PublishDataPacket(
+						&lksdk.UserDataPacket{Payload: append([]byte{}, buf...), Topic: topic},
+						lksdk.WithDataPublishReliable(mode == DataReliable),
+					)
+					if err != nil {
+						continue
+					}
+					sequences[key]++
+					t.data.messageSent(mode)
+				}
+			}
+		})
+	}
+}
+
+func (t *LoadTester) onDataPacket(data lksdk.DataPacket, params lksdk.DataReceiveParams) {
+	packet, ok := data.(*lksdk.UserDataPacket)
+	if !ok {
+		return
+	}
+	msg, ok := parseDataMessage(packet.Payload)
+	if !ok {
+		return
+	}
+	t.data.messageReceived(params.SenderIdentity, packet.Topic, msg, syncedNow())
+}
+
+// getDataSummaries adds up the data messages of testers by mode
+func getDataSummaries(stats []*testerStats) map[DataMode]*dataStats {
+	summaries := make(map[DataMode]*dataStats)
+	for _, testerStats := range stats {
+		for mode, s := range testerStats.data {
+			if summaries[mode] == nil {
+				summaries[mode] = &dataStats{}
+			}
+			summaries[mode].merge(s)
+		}
+	}
+	return summaries
+}
+
+func dataModes(stats map[DataMode]*dataStats) []DataMode {
+	modes := make([]DataMode, 0, len(stats))
+	for mode := range stats {
+		modes = append(modes, mode)
+	}
+	sort.Slice(modes, func(i, j int) bool {
+		return modes[i] < modes[j]
+	})
+	return modes
+}
+
+// printDataResults prints the data messages received by each subscriber, and totals including messages sent by publishers
+func printDataResults(out io.Writer, names []string, stats map[string]*testerStats) {
+	all := make([]*testerStats, 0, len(stats))
+	for _, s := range stats {
+		all = append(all, s)
+	}
+	totals := getDataSummaries(all)
+	if len(totals) == 0 {
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 1, 1, 1, ' ', 0)
+	_, _ = fmt.Fprint(w, "\nData\t| Tester\t| Mode\t| Sent\t| Received\t| Lost (between received)\t| Out of Order\t| Delay (p50/p95/p99)\n")
+	for _, name := range names {
+		data := stats[name].data
+		for _, mode := range dataModes(data) {
+			s := data[mode]
+			_, _ = fmt.Fprintf(w, "\t| %s\t| %s\t| -\t| %d\t| %s\t| %d\t| %s\n",
+				name, mode, s.received, formatStrings(s.received, s.lost), s.outOfOrder, formatLatency(&s.delay))
+		}
+	}
+	var delay latencyStats
+	for _, mode := range dataModes(totals) {
+		s := totals[mode]
+		_, _ = fmt.Fprintf(w, "\t| %s\t| %s\t| %d\t| %d\t| %s\t| %d\t| %s\n",
+			"Total", mode, s.sent, s.received, formatStrings(s.received, s.lost), s.outOfOrder, formatLatency(&s.delay))
+		delay.merge(&s.delay)
+	}
+	_ = w.Flush()
+	_, _ = fmt.Fprintln(out, "Lost: messages missing between the first and last received from each sender, later messages and senders never received aren't counted")
+	printClockWarning(out, "data delay", &delay)
+}
+
+func newDataReports(stats map[DataMode]*dataStats) []*DataReport {
+	var reports []*DataReport
+	for _, mode := range dataModes(stats) {
+		s := stats[mode]
+		r := &DataReport{
+			Mode:       string(mode),
+			Sent:       s.sent,
+			Received:   s.received,
+			Lost:       s.lost,
+			OutOfOrder: s.outOfOrder,
+		}
+		if s.received+s.lost > 0 {
+			r.Loss = float64(s.lost) / float64(s.received+s.lost)
+		}
+		if n := s.delay.samples(); n > 0 {
+			r.DelaySamples = n
+			r.DelayP50Ms = s.delay.percentile(50).Milliseconds()
+			r.DelayP95Ms = s.delay.percentile(95).Milliseconds()
+			r.DelayP99Ms = s.delay.percentile(99).Milliseconds()
+		}
+		reports = append(reports, r)
+	}
+	return reports
+}
@@ -0,0 +1,77 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataMessage(t *testing.T) {
+	sentAt := time.Unix(0, time.Now().UnixNano())
+	buf := make([]byte, 64)
+	encodeDataMessage(buf, dataMessage{mode: DataReliable, sequence: 42, sentAt: sentAt})
+
+	msg, ok := parseDataMessage(buf)
+	require.True(t, ok)
+	require.Equal(t, DataReliable, msg.mode)
+	require.Equal(t, uint64(42), msg.sequence)
+	require.True(t, sentAt.Equal(msg.sentAt))
+
+	_, ok = parseDataMessage([]byte("hello"))
+	require.False(t, ok)
+}
+
+func TestDataTracker(t *testing.T) {
+	d := newDataTracker()
+	now := time.Now()
+	receive := func(sender string, mode DataMode, seq uint64) {
+		d.messageReceived(sender, "chat", dataMessage{mode: mode, sequence: seq, sentAt: now.Add(-10 * time.Millisecond)}, now)
+	}
+	// 15 lost, 12 out of order
+	for _, seq := range []uint64{10, 11, 13, 12, 14, 16} {
+		receive("a", DataLossy, seq)
+	}
+	// a separate stream, joined late
+	for _, seq := range []uint64{100, 101} {
+		receive("b", DataLossy, seq)
+	}
+	receive("a", DataReliable, 0)
+	d.messageSent(DataReliable)
+
+	stats := d.stats()
+	lossy := stats[DataLossy]
+	require.Equal(t, int64(8), lossy.received)
+	require.Equal(t, int64(1), lossy.lost)
+	require.Equal(t, int64(1), lossy.outOfOrder)
+	require.Equal(t, 10*time.Millisecond, lossy.delay.percentile(50))
+
+	reliable := stats[DataReliable]
+	require.Equal(t, int64(1), reliable.sent)
+	require.Equal(t, int64(1), reliable.received)
+	require.Equal(t, int64(0), reliable.lost)
+}
+
+func TestDataPublishersAcceptableUse(t *testing.T) {
+	params := &Params{TesterParams: TesterParams{URL: "wss://test.livekit.cloud"}}
+	params.Data.Publishers = 50
+	require.NoError(t, checkAcceptableUse(params))
+
+	// data publishers count towards the limit like other publishers
+	params.Data.Publishers = 51
+	require.Error(t, checkAcceptableUse(params))
+}
@@ -0,0 +1,609 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/atomic"
+
+	lksdk "github.com/livekit/server-sdk-go/v2"
+)
+
+// A distributed test is run by a coordinator and a number of agents. Agents connect to the coordinator over TCP,
+// and messages are exchanged as JSON documents in this order:
+//
+//	agent -> coordinator: agentHello
+//	coordinator -> agent: agentAssignment
+//	coordinator -> agent: agentControl (only when the test is stopped early)
+//	agent -> coordinator: agentResult
+//
+// Latency and data delay are measured by comparing the clocks of publishers and subscribers, which may run on
+// different agents. The hello and the assignment carry timestamps, from which agents estimate the offset of their
+// clock from the coordinator's, and then use the coordinator's clock for timestamps.
+
+type CoordinatorParams struct {
+	// address to listen on for agents
+	Address string
+	// number of agents to wait for before starting the test
+	Agents int
+	// time between sending assignments and starting, so that agents start together
+	StartDelay time.Duration
+}
+
+type agentHello struct {
+	Name   string    `json:"name"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// agentAssignment is the part of a test run by a single agent
+type agentAssignment struct {
+	Room             string                 `json:"room"`
+	IdentityPrefix   string                 `json:"identity_prefix"`
+	StartAt          time.Time              `json:"start_at"`
+	HelloReceivedAt  time.Time              `json:"hello_received_at"`
+	SentAt           time.Time              `json:"sent_at"`
+	Duration         time.Duration          `json:"duration"`
+	Sequences        []int                  `json:"sequences"`
+	VideoPublishers  int                    `json:"video_publishers"`
+	AudioPublishers  int                    `json:"audio_publishers"`
+	Subscribers      int                    `json:"subscribers"`
+	Rooms            int                    `json:"rooms"`
+	RoomDistribution RoomDistribution       `json:"room_distribution"`
+	Layouts          []Layout               `json:"layouts,omitempty"`
+	VideoResolution  string                 `json:"video_resolution"`
+	VideoCodec       string                 `json:"video_codec"`
+	NumPerSecond     float64                `json:"num_per_second"`
+	Arrival          ArrivalParams          `json:"arrival"`
+	Data             DataParams             `json:"data"`
+	LayoutSwitch     time.Duration          `json:"layout_switch,omitempty"`
+	Simulcast        bool                   `json:"simulcast"`
+	SimulateSpeakers bool                   `json:"simulate_speakers"`
+	Layout           Layout                 `json:"layout"`
+	MeasureLatency   bool                   `json:"measure_latency"`
+	Impairments      []ImpairmentAssignment `json:"impairments,omitempty"`
+	Churn            ChurnParams            `json:"churn"`
+}
+
+type agentControl struct {
+	Stop bool `json:"stop"`
+}
+
+type agentResult struct {
+	Stats map[string]*agentTesterStats `json:"stats"`
+	Error string                       `json:"error,omitempty"`
+}
+
+type agentTesterStats struct {
+	Room                 string                       `json:"room"`
+	Impairment           string                       `json:"impairment,omitempty"`
+	ExpectedTracks       int                          `json:"expected_tracks"`
+	Tracks               []*agentTrackStats           `json:"tracks"`
+	SubscriptionFailures int64                        `json:"subscription_failures"`
+	Reconnects           int64                        `json:"reconnects"`
+	RTT                  uint32                       `json:"rtt"`
+	Setup                *SetupReport                 `json:"setup"`
+	Data                 map[DataMode]*agentDataStats `json:"data,omitempty"`
+	Speakers             *agentSpeakerStats           `json:"speakers,omitempty"`
+	Error                string                       `json:"error,omitempty"`
+}
+
+type agentSpeakerStats struct {
+	Updates    int64 `json:"updates"`
+	Observed   int64 `json:"observed"`
+	Missed     int64 `json:"missed"`
+	Unexpected int64 `json:"unexpected,omitempty"`
+	// latency histogram, sample counts by latency in ms
+	Latency map[int64]int64 `json:"latency,omitempty"`
+}
+
+type agentDataStats struct {
+	Sent       int64 `json:"sent"`
+	Received   int64 `json:"received"`
+	Lost       int64 `json:"lost"`
+	OutOfOrder int64 `json:"out_of_order"`
+	// delay histogram, sample counts by latency in ms
+	Delay      map[int64]int64 `json:"delay,omitempty"`
+	DelayEarly int64           `json:"delay_early,omitempty"`
+}
+
+type agentTrackStats struct {
+	TrackID   string    `json:"track_id"`
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
+	Codec     string    `json:"codec"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	Packets   int64     `json:"packets"`
+	Bytes     int64     `json:"bytes"`
+	Dropped   int64     `json:"dropped"`
+	// latency histogram, sample counts by latency in ms
+	Latency        map[int64]int64 `json:"latency,omitempty"`
+	LatencyEarly   int64           `json:"latency_early,omitempty"`
+	Jitter         time.Duration   `json:"jitter"`
+	Nacks          int64           `json:"nacks"`
+	Plis           int64           `json:"plis"`
+	Freezes        int64           `json:"freezes"`
+	FreezeDuration time.Duration   `json:"freeze_duration"`
+	LayerSwitches  int64           `json:"layer_switches,omitempty"`
+	LayerTimeouts  int64           `json:"layer_timeouts,omitempty"`
+	LayerReplaced  int64           `json:"layer_replaced,omitempty"`
+	// layer convergence histogram, sample counts by latency in ms
+	LayerLatency map[int64]int64 `json:"layer_latency,omitempty"`
+}
+
+type agentConn struct {
+	name    string
+	helloAt time.Time
+	conn    net.Conn
+	enc     *json.Encoder
+	dec     *json.Decoder
+}
+
+// clockOffset is added to the local clock to get the coordinator's, while running as an agent
+var clockOffset atomic.Duration
+
+// syncedNow returns the time used for timestamps that are compared across hosts
+func syncedNow() time.Time {
+	return time.Now().Add(clockOffset.Load())
+}
+
+// estimateClockOffset returns the offset of the coordinator's clock from the agent's, and its maximum error,
+// from the times the hello was sent and the assignment received by the agent, and their counterparts on the coordinator
+func estimateClockOffset(helloSentAt, helloReceivedAt, assignmentSentAt, assignmentReceivedAt time.Time) (time.Duration, time.Duration) {
+	offset := (helloReceivedAt.Sub(helloSentAt) + assignmentSentAt.Sub(assignmentReceivedAt)) / 2
+	rtt := assignmentReceivedAt.Sub(helloSentAt) - assignmentSentAt.Sub(helloReceivedAt)
+	return offset, rtt / 2
+}
+
+func newAgentConn(conn net.Conn) *agentConn {
+	return &agentConn{
+		conn: conn,
+		enc:  json.NewEncoder(conn),
+		dec:  json.NewDecoder(conn),
+	}
+}
+
+// splitTesters deals tester sequences to agents in turn, publishers first, so that each runs a similar number of testers
+func splitTesters(publishers, subscribers, agents int) [][]int {
+	split := make([][]int, agents)
+	for i := 0; i < publishers+subscribers; i++ {
+		split[i%agents] = append(split[i%agents], i)
+	}
+	return split
+}
+
+// RunCoordinator waits for agents to connect, splits the test between them and merges their results
+func (t *LoadTest) RunCoordinator(ctx context.Context, cp CoordinatorParams) error {
+	if cp.Agents < 1 {
+		return errors.New("at least one agent is required")
+	}
+
+	ln, err := net.Listen("tcp", cp.Address)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	stopListening := context.AfterFunc(ctx, func() {
+		_ = ln.Close()
+	})
+	defer stopListening()
+
+	fmt.Fprintf(t.Params.output(), "Waiting for %d agents on %s\n", cp.Agents, ln.Addr().String())
+	var agents []*agentConn
+	defer func() {
+		for _, a := range agents {
+			_ = a.conn.Close()
+		}
+	}()
+	for len(agents) < cp.Agents {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		a := newAgentConn(conn)
+		hello := &agentHello{}
+		if err = a.dec.Decode(hello); err != nil {
+			fmt.Fprintf(t.Params.output(), "agent %s failed to connect: %v\n", conn.RemoteAddr().String(), err)
+			_ = conn.Close()
+			continue
+		}
+		a.name = hello.Name
+		a.helloAt = time.Now()
+		agents = append(agents, a)
+		fmt.Fprintf(t.Params.output(), "Agent %s connected from %s (%d/%d)\n", a.name, conn.RemoteAddr().String(), len(agents), cp.Agents)
+	}
+	_ = ln.Close()
+
+	params := t.Params
+	if params.Room == "" {
+		params.Room = fmt.Sprintf("testroom%d", rand.Int31n(1000))
+	}
+	if params.IdentityPrefix == "" {
+		params.IdentityPrefix = randStringRunes(5)
+	}
+	maxPublishers := params.numPublishers()
+	split := splitTesters(maxPublishers, params.Subscribers, len(agents))
+	startedAt := time.Now().Add(cp.StartDelay)
+	for i, a := range agents {
+		err = a.enc.Encode(&agentAssignment{
+			Room:             params.Room,
+			IdentityPrefix:   params.IdentityPrefix,
+			StartAt:          startedAt,
+			HelloReceivedAt:  a.helloAt,
+			SentAt:           time.Now(),
+			Duration:         params.Duration,
+			Sequences:        split[i],
+			VideoPublishers:  params.VideoPublishers,
+			AudioPublishers:  params.AudioPublishers,
+			Subscribers:      params.Subscribers,
+			Rooms:            params.Rooms,
+			RoomDistribution: params.RoomDistribution,
+			Layouts:          params.Layouts,
+			VideoResolution:  params.VideoResolution,
+			VideoCodec:       params.VideoCodec,
+			NumPerSecond:     params.NumPerSecond / float64(len(agents)),
+			Arrival:          params.Arrival.split(len(agents)),
+			Data:             params.Data,
+			LayoutSwitch:     params.LayoutSwitchInterval,
+			Simulcast:        params.Simulcast,
+			SimulateSpeakers: params.SimulateSpeakers,
+			Layout:           params.Layout,
+			MeasureLatency:   params.MeasureLatency,
+			Impairments:      params.Impairments,
+			Churn:            params.Churn,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "could not send assignment to agent %s", a.name)
+		}
+	}
+	fmt.Fprintf(t.Params.output(), "Starting load test on %d agents, room: %s\n", len(agents), params.Room)
+
+	// agents run until their duration elapses, or they are told to stop
+	stopAgents := context.AfterFunc(ctx, func() {
+		for _, a := range agents {
+			_ = a.enc.Encode(&agentControl{Stop: true})
+		}
+	})
+	defer stopAgents()
+
+	results := make([]*agentResult, len(agents))
+	var wg sync.WaitGroup
+	for i, a := range agents {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := &agentResult{}
+			if err := a.dec.Decode(res); err != nil {
+				res.Error = err.Error()
+			}
+			results[i] = res
+		}()
+	}
+	wg.Wait()
+
+	stats := make(map[string]*testerStats)
+	for i, res := range results {
+		if res.Error != "" {
+			// count a failed agent as a tester with an error, as its testers are missing from the results
+			fmt.Fprintf(t.Params.output(), "agent %s failed: %s\n", agents[i].name, res.Error)
+			stats["Agent "+agents[i].name] = &testerStats{
+				trackStats: make(map[string]*trackStats),
+				err:        errors.New(res.Error),
+			}
+		}
+		t.decodeStats(res.Stats, stats)
+	}
+
+	if t.Params.writesReport() {
+		params.Duration = time.Since(startedAt)
+		report := &Report{
+			Tests: []*TestReport{t.newTestReport(params, startedAt, time.Now(), stats)},
+		}
+		if err = t.writeReport(report); err != nil {
+			return err
+		}
+	}
+
+	t.printResults(stats)
+	return t.checkThresholds(stats)
+}
+
+// RunAgent connects to a coordinator, runs the testers assigned to it and sends back their stats
+func (t *LoadTest) RunAgent(ctx context.Context, coordinator string) error {
+	conn, err := dialCoordinator(t.Params.output(), ctx, coordinator)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	a := newAgentConn(conn)
+
+	hostname, _ := os.Hostname()
+	helloSentAt := time.Now()
+	if err = a.enc.Encode(&agentHello{Name: fmt.Sprintf("%s-%d", hostname, os.Getpid()), SentAt: helloSentAt}); err != nil {
+		return err
+	}
+	assignment := &agentAssignment{}
+	if err = a.dec.Decode(assignment); err != nil {
+		return errors.Wrap(err, "could not receive assignment")
+	}
+	if !assignment.SentAt.IsZero() {
+		offset, maxErr := estimateClockOffset(helloSentAt, assignment.HelloReceivedAt, assignment.SentAt, time.Now())
+		clockOffset.Store(offset)
+		defer clockOffset.Store(0)
+		fmt.Fprintf(t.Params.output(), "Clock offset from coordinator: %s (±%s)\n",
+			offset.Round(time.Microsecond), maxErr.Round(time.Microsecond))
+	}
+
+	params := t.Params
+	params.Room = assignment.Room
+	params.IdentityPrefix = assignment.IdentityPrefix
+	params.Duration = assignment.Duration
+	params.sequences = assignment.Sequences
+	params.VideoPublishers = assignment.VideoPublishers
+	params.AudioPublishers = assignment.AudioPublishers
+	params.Subscribers = assignment.Subscribers
+	params.Rooms = assignment.Rooms
+	params.RoomDistribution = assignment.RoomDistribution
+	params.Layouts = assignment.Layouts
+	params.VideoResolution = assignment.VideoResolution
+	params.VideoCodec = assignment.VideoCodec
+	params.NumPerSecond = assignment.NumPerSecond
+	params.Arrival = assignment.Arrival
+	params.Data = assignment.Data
+	params.LayoutSwitchInterval = assignment.LayoutSwitch
+	params.Simulcast = assignment.Simulcast
+	params.SimulateSpeakers = assignment.SimulateSpeakers
+	params.Layout = assignment.Layout
+	params.MeasureLatency = assignment.MeasureLatency
+	params.Impairments = assignment.Impairments
+	params.Churn = assignment.Churn
+
+	result := &agentResult{}
+	defer func() {
+		_ = a.enc.Encode(result)
+	}()
+
+	err = checkAcceptableUse(&params)
+	if err != nil {
+		result.Error = err.Error()
+		return err
+	}
+	if len(params.sequences) == 0 {
+		fmt.Fprintln(t.Params.output(), "No testers assigned to this agent")
+		result.Stats = map[string]*agentTesterStats{}
+		return nil
+	}
+
+	if t.Params.PrometheusPort != 0 {
+		stop, err := t.startMetricsServer()
+		if err != nil {
+			result.Error = err.Error()
+			return err
+		}
+		defer stop()
+	}
+
+	// any message from the coordinator, or losing the connection, stops the test
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		_ = a.dec.Decode(&agentControl{})
+		cancel()
+	}()
+
+	fmt.Fprintf(t.Params.output(), "Assigned %d testers, starting at %s\n", len(params.sequences), assignment.StartAt.Format(time.TimeOnly))
+	select {
+	case <-ctx.Done():
+		result.Error = "stopped before starting"
+		return ctx.Err()
+	case <-time.After(assignment.StartAt.Sub(syncedNow())):
+	}
+
+	stats, err := t.run(ctx, &params)
+	if err != nil {
+		result.Error = err.Error()
+		return err
+	}
+	result.Stats = t.encodeStats(stats)
+
+	t.printResults(stats)
+	// ramp and churn are only reported by the agents
+	t.printRunResults()
+	return nil
+}
+
+func dialCoordinator(out io.Writer, ctx context.Context, address string) (net.Conn, error) {
+	dialer := &net.Dialer{}
+	waiting := false
+	for {
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err == nil {
+			fmt.Fprintf(out, "Connected to coordinator at %s\n", address)
+			return conn, nil
+		}
+		if !waiting {
+			fmt.Fprintf(out, "Waiting for coordinator at %s\n", address)
+			waiting = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (t *LoadTest) encodeStats(stats map[string]*testerStats) map[string]*agentTesterStats {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	encoded := make(map[string]*agentTesterStats, len(stats))
+	for name, s := range stats {
+		ats := &agentTesterStats{
+			Room:                 s.room,
+			Impairment:           s.impairment,
+			ExpectedTracks:       s.expectedTracks,
+			SubscriptionFailures: s.subscriptionFailures,
+			Reconnects:           s.reconnects,
+			RTT:                  s.rtt,
+			Setup:                s.setup.toReport(),
+		}
+		if s.err != nil {
+			ats.Error = s.err.Error()
+		}
+		if s.speakers != nil {
+			ats.Speakers = &agentSpeakerStats{
+				Updates:    s.speakers.updates,
+				Observed:   s.speakers.observed,
+				Missed:     s.speakers.missed,
+				Unexpected: s.speakers.unexpected,
+				Latency:    s.speakers.latency.histogram(),
+			}
+		}
+		if len(s.data) > 0 {
+			ats.Data = make(map[DataMode]*agentDataStats, len(s.data))
+			for mode, ds := range s.data {
+				ats.Data[mode] = &agentDataStats{
+					Sent:       ds.sent,
+					Received:   ds.received,
+					Lost:       ds.lost,
+					OutOfOrder: ds.outOfOrder,
+					Delay:      ds.delay.histogram(),
+					DelayEarly: ds.delay.earlySamples(),
+				}
+			}
+		}
+		for _, ts := range s.trackStats {
+			// testers have been stopped, so tracks without an end ended now
+			endedAt := ts.endedAt.Load()
+			if endedAt.IsZero() {
+				endedAt = time.Now()
+			}
+			ats.Tracks = append(ats.Tracks, &agentTrackStats{
+				TrackID:        ts.trackID,
+				Name:           t.trackNames[ts.trackID],
+				Kind:           string(ts.kind),
+				Codec:          ts.codec,
+				StartedAt:      ts.startedAt.Load(),
+				EndedAt:        endedAt,
+				Packets:        ts.packets.Load(),
+				Bytes:          ts.bytes.Load(),
+				Dropped:        ts.dropped.Load(),
+				Latency:        ts.latency.histogram(),
+				LatencyEarly:   ts.latency.earlySamples(),
+				Jitter:         ts.jitter.Load(),
+				Nacks:          ts.nacks.Load(),
+				Plis:           ts.plis.Load(),
+				Freezes:        ts.freezes.Load(),
+				FreezeDuration: ts.freezeDuration.Load(),
+				LayerSwitches:  ts.layerSwitches.Load(),
+				LayerTimeouts:  ts.layerTimeouts.Load(),
+				LayerReplaced:  ts.layerReplaced.Load(),
+				LayerLatency:   ts.layerLatency.histogram(),
+			})
+		}
+		encoded[name] = ats
+	}
+	return encoded
+}
+
+// decodeStats adds stats received from an agent to stats, and records their track names
+func (t *LoadTest) decodeStats(encoded map[string]*agentTesterStats, stats map[string]*testerStats) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for name, ats := range encoded {
+		s := &testerStats{
+			room:                 ats.Room,
+			impairment:           ats.Impairment,
+			expectedTracks:       ats.ExpectedTracks,
+			trackStats:           make(map[string]*trackStats),
+			subscriptionFailures: ats.SubscriptionFailures,
+			reconnects:           ats.Reconnects,
+			rtt:                  ats.RTT,
+		}
+		if ats.Setup != nil {
+			s.setup = ats.Setup.timing()
+		}
+		if ats.Error != "" {
+			s.err = errors.New(ats.Error)
+		}
+		if ats.Speakers != nil {
+			s.speakers = &speakerStats{
+				updates:    ats.Speakers.Updates,
+				observed:   ats.Speakers.Observed,
+				missed:     ats.Speakers.Missed,
+				unexpected: ats.Speakers.Unexpected,
+			}
+			s.speakers.latency.load(ats.Speakers.Latency)
+		}
+		if len(ats.Data) > 0 {
+			s.data = make(map[DataMode]*dataStats, len(ats.Data))
+			for mode, ad := range ats.Data {
+				ds := &dataStats{
+					sent:       ad.Sent,
+					received:   ad.Received,
+					lost:       ad.Lost,
+					outOfOrder: ad.OutOfOrder,
+				}
+				ds.delay.load(ad.Delay)
+				ds.delay.early = ad.DelayEarly
+				s.data[mode] = ds
+			}
+		}
+		for _, at := range ats.Tracks {
+			ts := &trackStats{
+				trackID: at.TrackID,
+				kind:    lksdk.TrackKind(at.Kind),
+				codec:   at.Codec,
+			}
+			ts.startedAt.Store(at.StartedAt)
+			ts.endedAt.Store(at.EndedAt)
+			ts.packets.Store(at.Packets)
+			ts.bytes.Store(at.Bytes)
+			ts.dropped.Store(at.Dropped)
+			ts.latency.load(at.Latency)
+			ts.latency.early = at.LatencyEarly
+			ts.jitter.Store(at.Jitter)
+			ts.nacks.Store(at.Nacks)
+			ts.plis.Store(at.Plis)
+			ts.freezes.Store(at.Freezes)
+			ts.freezeDuration.Store(at.FreezeDuration)
+			ts.layerSwitches.Store(at.LayerSwitches)
+			ts.layerTimeouts.Store(at.LayerTimeouts)
+			ts.layerReplaced.Store(at.LayerReplaced)
+			ts.layerLatency.load(at.LayerLatency)
+			s.trackStats[at.TrackID] = ts
+			if at.Name != "" {
+				t.trackNames[at.TrackID] = at.Name
+			}
+		}
+		stats[name] = s
+	}
+}
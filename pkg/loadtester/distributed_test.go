@@ -0,0 +1,107 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	lksdk "github.com/livekit/server-sdk-go/v2"
+)
+
+func TestSplitTesters(t *testing.T) {
+	split := splitTesters(2, 5, 3)
+	require.Equal(t, [][]int{{0, 3, 6}, {1, 4}, {2, 5}}, split)
+
+	var all []int
+	for _, s := range splitTesters(7, 20, 4) {
+		all = append(all, s...)
+	}
+	require.Len(t, all, 27)
+}
+
+func TestAgentStats(t *testing.T) {
+	ts := &trackStats{trackID: "TR_1", kind: lksdk.TrackKindVideo}
+	ts.startedAt.Store(time.Now().Add(-time.Minute))
+	ts.packets.Store(100)
+	ts.dropped.Store(2)
+	ts.latency.add(20 * time.Millisecond)
+	ts.latency.add(40 * time.Millisecond)
+	ts.latency.add(-5 * time.Millisecond)
+
+	agent := NewLoadTest(Params{})
+	agent.trackNames["TR_1"] = "1V"
+	encoded := agent.encodeStats(map[string]*testerStats{
+		"Sub 0": {expectedTracks: 1, trackStats: map[string]*trackStats{"TR_1": ts}, reconnects: 1},
+		"Sub 1": {trackStats: map[string]*trackStats{}, err: errors.New("could not connect")},
+	})
+
+	coordinator := NewLoadTest(Params{})
+	stats := make(map[string]*testerStats)
+	coordinator.decodeStats(encoded, stats)
+	require.Len(t, stats, 2)
+	require.Equal(t, "1V", coordinator.trackNames["TR_1"])
+	require.EqualError(t, stats["Sub 1"].err, "could not connect")
+
+	s := getTesterSummary(stats["Sub 0"])
+	require.Equal(t, 1, s.tracks)
+	require.Equal(t, int64(100), s.packets)
+	require.Equal(t, int64(2), s.dropped)
+	require.Equal(t, int64(1), s.reconnects)
+	require.Equal(t, int64(3), s.latency.samples())
+	require.Equal(t, int64(1), s.latency.earlySamples())
+	require.Equal(t, 40*time.Millisecond, s.latency.percentile(100))
+	require.InDelta(t, time.Minute.Seconds(), s.elapsed.Seconds(), 1)
+}
+
+func TestEstimateClockOffset(t *testing.T) {
+	// the agent's clock is 2s behind, and messages take 10ms each way
+	helloSentAt := time.Unix(1000, 0)
+	helloReceivedAt := helloSentAt.Add(2*time.Second + 10*time.Millisecond)
+	assignmentSentAt := helloReceivedAt.Add(time.Second)
+	assignmentReceivedAt := assignmentSentAt.Add(-2*time.Second + 10*time.Millisecond)
+
+	offset, maxErr := estimateClockOffset(helloSentAt, helloReceivedAt, assignmentSentAt, assignmentReceivedAt)
+	require.Equal(t, 2*time.Second, offset)
+	require.Equal(t, 10*time.Millisecond, maxErr)
+}
+
+func TestCoordinatorAgents(t *testing.T) {
+	// reserve a port for the coordinator
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// with no testers to run, agents report back without connecting to a server
+	errs := make(chan error, 3)
+	for i := 0; i < 2; i++ {
+		go func() {
+			errs <- (&LoadTest{Params: Params{}, trackNames: map[string]string{}}).RunAgent(ctx, address)
+		}()
+	}
+	coordinator := &LoadTest{Params: Params{Duration: time.Second}, trackNames: map[string]string{}}
+	require.NoError(t, coordinator.RunCoordinator(ctx, CoordinatorParams{Address: address, Agents: 2}))
+	require.NoError(t, <-errs)
+	require.NoError(t, <-errs)
+}
@@ -0,0 +1,365 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+)
+
+// Impairment degrades RTP packets sent and received by a participant, simulating a poor network
+type Impairment struct {
+	Name string `json:"name"`
+	// ratio of packets dropped, 0-1
+	Loss   float64       `json:"loss"`
+	Delay  time.Duration `json:"delay"`
+	Jitter time.Duration `json:"jitter"`
+	// in bps, unlimited when 0
+	Bandwidth int64 `json:"bandwidth"`
+}
+
+// ImpairmentProfiles are named impairments for common network conditions
+var ImpairmentProfiles = map[string]Impairment{
+	"2g":         {Name: "2g", Loss: 0.02, Delay: 300 * time.Millisecond, Jitter: 60 * time.Millisecond, Bandwidth: 200_000},
+	"3g":         {Name: "3g", Loss: 0.015, Delay: 150 * time.Millisecond, Jitter: 30 * time.Millisecond, Bandwidth: 750_000},
+	"4g":         {Name: "4g", Loss: 0.005, Delay: 50 * time.Millisecond, Jitter: 15 * time.Millisecond, Bandwidth: 8_000_000},
+	"wifi-lossy": {Name: "wifi-lossy", Loss: 0.05, Delay: 20 * time.Millisecond, Jitter: 30 * time.Millisecond},
+	"satellite":  {Name: "satellite", Loss: 0.005, Delay: 300 * time.Millisecond, Jitter: 20 * time.Millisecond, Bandwidth: 5_000_000},
+}
+
+// packets that would wait longer than this for bandwidth are dropped, as by a full router queue
+const maxImpairmentQueueDelay = time.Second
+
+// ParseImpairment returns a named profile, or a custom impairment such as "loss=2;delay=100ms;jitter=20ms;bandwidth=500",
+// with loss in percent and bandwidth in kbps
+func ParseImpairment(spec string) (Impairment, error) {
+	if p, ok := ImpairmentProfiles[spec]; ok {
+		return p, nil
+	}
+	if !strings.Contains(spec, "=") {
+		return Impairment{}, fmt.Errorf("unknown impairment profile: %s", spec)
+	}
+
+	imp := Impairment{Name: spec}
+	for _, field := range strings.Split(spec, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		var err error
+		switch key {
+		case "loss":
+			var loss float64
+			loss, err = strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+			if err == nil && (loss < 0 || loss > 100) {
+				err = fmt.Errorf("must be between 0 and 100")
+			}
+			imp.Loss = loss / 100
+		case "delay":
+			imp.Delay, err = time.ParseDuration(value)
+			if err == nil && imp.Delay < 0 {
+				err = fmt.Errorf("must not be negative")
+			}
+		case "jitter":
+			imp.Jitter, err = time.ParseDuration(value)
+			if err == nil && imp.Jitter < 0 {
+				err = fmt.Errorf("must not be negative")
+			}
+		case "bandwidth":
+			var kbps int64
+			kbps, err = strconv.ParseInt(value, 10, 64)
+			if err == nil && kbps < 0 {
+				err = fmt.Errorf("must not be negative")
+			}
+			imp.Bandwidth = kbps * 1000
+		default:
+			err = fmt.Errorf("unknown setting")
+		}
+		if err != nil {
+			return Impairment{}, fmt.Errorf("invalid impairment %q, %s: %v", spec, key, err)
+		}
+	}
+	return imp, nil
+}
+
+// ImpairmentAssignment applies an impairment to a percentage of participants
+type ImpairmentAssignment struct {
+	Impairment Impairment `json:"impairment"`
+	Percent    float64    `json:"percent"`
+}
+
+// ParseImpairmentAssignment parses "profile:percent", where percent defaults to 100
+func ParseImpairmentAssignment(str string) (ImpairmentAssignment, error) {
+	spec, percent := str, "100"
+	if i := strings.LastIndex(str, ":"); i >= 0 {
+		spec, percent = str[:i], str[i+1:]
+	}
+	imp, err := ParseImpairment(spec)
+	if err != nil {
+		return ImpairmentAssignment{}, err
+	}
+	p, err := strconv.ParseFloat(strings.TrimSuffix(percent, "%"), 64)
+	if err != nil || p < 0 || p > 100 {
+		return ImpairmentAssignment{}, fmt.Errorf("invalid impairment percentage: %s", percent)
+	}
+	return ImpairmentAssignment{Impairment: imp, Percent: p}, nil
+}
+
+// impairmentPlan assigns impairments to tester sequences, nil for testers on a clean network
+func impairmentPlan(params *Params) []*Impairment {
+	n := params.numPublishers() + params.Subscribers
+	plan := make([]*Impairment, n)
+	if len(params.Impairments) == 0 {
+		return plan
+	}
+
+	// seeded by the identity prefix, so that agents of a distributed test agree on the plan
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(params.IdentityPrefix + "_impairment"))
+	order := rand.New(rand.NewSource(int64(h.Sum64()))).Perm(n)
+
+	assigned := 0
+	for _, a := range params.Impairments {
+		count := min(int(a.Percent*float64(n)/100+0.5), n-assigned)
+		for _, i := range order[assigned : assigned+count] {
+			plan[i] = &a.Impairment
+		}
+		assigned += count
+	}
+	return plan
+}
+
+type impairmentFactory struct {
+	impairment Impairment
+}
+
+// NewImpairmentInterceptorFactory returns an interceptor that drops, delays and rate limits RTP packets in both directions.
+// It should come first, so that other interceptors see the impaired stream
+func NewImpairmentInterceptorFactory(impairment Impairment) interceptor.Factory {
+	return &impairmentFactory{impairment: impairment}
+}
+
+func (f *impairmentFactory) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	return &impairmentInterceptor{
+		outgoing: newImpairedLink(f.impairment, rand.Int63()),
+		incoming: newImpairedLink(f.impairment, rand.Int63()),
+		done:     make(map[uint32]chan struct{}),
+	}, nil
+}
+
+type impairmentInterceptor struct {
+	interceptor.NoOp
+	outgoing *impairedLink
+	incoming *impairedLink
+
+	lock sync.Mutex
+	// SSRC => closed when the local stream is unbound
+	done map[uint32]chan struct{}
+}
+
+type impairedPacket struct {
+	at         time.Time
+	header     *rtp.Header
+	buf        []byte
+	attributes interceptor.Attributes
+	err        error
+}
+
+func (i *impairmentInterceptor) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	done := make(chan struct{})
+	i.lock.Lock()
+	i.done[info.SSRC] = done
+	i.lock.Unlock()
+
+	queue := make(chan *impairedPacket, 1000)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case pkt := <-queue:
+				select {
+				case <-done:
+					return
+				case <-time.After(time.Until(pkt.at)):
+				}
+				_, _ = writer.Write(pkt.header, pkt.buf, pkt.attributes)
+			}
+		}
+	}()
+
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		size := header.MarshalSize() + len(payload)
+		at, ok := i.outgoing.schedule(size, time.Now())
+		if !ok {
+			return size, nil
+		}
+		// the caller owns header and payload, so they are copied before queueing
+		h := header.Clone()
+		pkt := &impairedPacket{at: at, header: &h, buf: append([]byte{}, payload...), attributes: attributes}
+		select {
+		case queue <- pkt:
+		default:
+			// queue is full, dropped
+		}
+		return size, nil
+	})
+}
+
+func (i *impairmentInterceptor) UnbindLocalStream(info *interceptor.StreamInfo) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if done, ok := i.done[info.SSRC]; ok {
+		close(done)
+		delete(i.done, info.SSRC)
+	}
+}
+
+func (i *impairmentInterceptor) BindRemoteStream(_ *interceptor.StreamInfo, reader interceptor.RTPReader) interceptor.RTPReader {
+	queue := make(chan *impairedPacket, 1000)
+	var readAhead sync.Once
+
+	return interceptor.RTPReaderFunc(func(b []byte, attributes interceptor.Attributes) (int, interceptor.Attributes, error) {
+		// packets are read ahead, so that delaying one does not hold back the ones behind it
+		readAhead.Do(func() {
+			go func() {
+				defer close(queue)
+				for {
+					buf := make([]byte, len(b))
+					n, a, err := reader.Read(buf, nil)
+					if err != nil {
+						select {
+						case queue <- &impairedPacket{err: err}:
+						default:
+						}
+						return
+					}
+					at, ok := i.incoming.schedule(n, time.Now())
+					if !ok {
+						continue
+					}
+					select {
+					case queue <- &impairedPacket{at: at, buf: buf[:n], attributes: a}:
+					default:
+					}
+				}
+			}()
+		})
+
+		pkt, ok := <-queue
+		if !ok {
+			return 0, nil, io.EOF
+		}
+		if pkt.err != nil {
+			return 0, nil, pkt.err
+		}
+		time.Sleep(time.Until(pkt.at))
+		return copy(b, pkt.buf), pkt.attributes, nil
+	})
+}
+
+func (i *impairmentInterceptor) Close() error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	for ssrc, done := range i.done {
+		close(done)
+		delete(i.done, ssrc)
+	}
+	return nil
+}
+
+// impairedLink schedules packets travelling in one direction
+type impairedLink struct {
+	impairment Impairment
+
+	lock sync.Mutex
+	rnd  *rand.Rand
+	// time at which the link has finished sending queued packets, for bandwidth limits
+	free time.Time
+	// delivery time of the last packet, to keep packets in order
+	last time.Time
+}
+
+func newImpairedLink(impairment Impairment, seed int64) *impairedLink {
+	return &impairedLink{
+		impairment: impairment,
+		rnd:        rand.New(rand.NewSource(seed)),
+	}
+}
+
+// schedule returns when a packet of size bytes sent now should be delivered, or false if it is dropped
+func (l *impairedLink) schedule(size int, now time.Time) (time.Time, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	imp := l.impairment
+	if imp.Loss > 0 && l.rnd.Float64() < imp.Loss {
+		return time.Time{}, false
+	}
+
+	sent := now
+	if imp.Bandwidth > 0 {
+		if l.free.After(now) {
+			if l.free.Sub(now) > maxImpairmentQueueDelay {
+				return time.Time{}, false
+			}
+			sent = l.free
+		}
+		sent = sent.Add(time.Duration(int64(size) * 8 * int64(time.Second) / imp.Bandwidth))
+		l.free = sent
+	}
+
+	at := sent.Add(imp.Delay)
+	if imp.Jitter > 0 {
+		at = at.Add(time.Duration(l.rnd.Int63n(2*int64(imp.Jitter))) - imp.Jitter)
+	}
+	if at.Before(l.last) {
+		at = l.last
+	}
+	if at.Before(now) {
+		at = now
+	}
+	l.last = at
+	return at, true
+}
+
+// printImpairmentResults prints a summary of the subscribers with each impairment, when some were impaired
+func printImpairmentResults(out io.Writer, names []string, summaries map[string]*summary, stats map[string]*testerStats) {
+	printGroupResults(out, "Impairments", "Profile", names, summaries, stats, impairmentGroup)
+}
+
+func impairmentGroup(s *testerStats) string {
+	if s.impairment == "" {
+		return "none"
+	}
+	return s.impairment
+}
+
+// impairmentNames lists the impairments of a plan, for printing
+func impairmentNames(assignments []ImpairmentAssignment) string {
+	names := make([]string, 0, len(assignments))
+	for _, a := range assignments {
+		names = append(names, fmt.Sprintf("%s (%.0f%%)", a.Impairment.Name, a.Percent))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
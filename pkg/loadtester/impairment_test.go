@@ -0,0 +1,143 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImpairment(t *testing.T) {
+	imp, err := ParseImpairment("3g")
+	require.NoError(t, err)
+	require.Equal(t, ImpairmentProfiles["3g"], imp)
+
+	imp, err = ParseImpairment("loss=2%;delay=100ms;jitter=20ms;bandwidth=500")
+	require.NoError(t, err)
+	require.Equal(t, 0.02, imp.Loss)
+	require.Equal(t, 100*time.Millisecond, imp.Delay)
+	require.Equal(t, 20*time.Millisecond, imp.Jitter)
+	require.Equal(t, int64(500_000), imp.Bandwidth)
+
+	_, err = ParseImpairment("dialup")
+	require.Error(t, err)
+	_, err = ParseImpairment("loss=200")
+	require.Error(t, err)
+	for _, spec := range []string{"delay=-100ms", "jitter=-1ms", "bandwidth=-500"} {
+		_, err = ParseImpairment(spec)
+		require.Error(t, err, spec)
+	}
+
+	a, err := ParseImpairmentAssignment("wifi-lossy:25")
+	require.NoError(t, err)
+	require.Equal(t, "wifi-lossy", a.Impairment.Name)
+	require.Equal(t, 25.0, a.Percent)
+
+	a, err = ParseImpairmentAssignment("delay=50ms")
+	require.NoError(t, err)
+	require.Equal(t, 100.0, a.Percent)
+}
+
+func TestImpairmentPlan(t *testing.T) {
+	params := &Params{
+		VideoPublishers: 2,
+		Subscribers:     18,
+		Impairments: []ImpairmentAssignment{
+			{Impairment: ImpairmentProfiles["3g"], Percent: 25},
+			{Impairment: ImpairmentProfiles["wifi-lossy"], Percent: 10},
+		},
+		TesterParams: TesterParams{IdentityPrefix: "abc"},
+	}
+	plan := impairmentPlan(params)
+	require.Len(t, plan, 20)
+	counts := make(map[string]int)
+	for _, imp := range plan {
+		if imp != nil {
+			counts[imp.Name]++
+		}
+	}
+	require.Equal(t, map[string]int{"3g": 5, "wifi-lossy": 2}, counts)
+	require.Equal(t, plan, impairmentPlan(params))
+}
+
+func TestImpairedLink(t *testing.T) {
+	now := time.Now()
+
+	// 1 Mbps, so a 1250 byte packet takes 10ms to send
+	link := newImpairedLink(Impairment{Delay: 50 * time.Millisecond, Bandwidth: 1_000_000}, 1)
+	at, ok := link.schedule(1250, now)
+	require.True(t, ok)
+	require.Equal(t, now.Add(60*time.Millisecond), at)
+	at, ok = link.schedule(1250, now)
+	require.True(t, ok)
+	require.Equal(t, now.Add(70*time.Millisecond), at)
+
+	// queued for longer than the limit
+	for i := 0; i < 100; i++ {
+		link.schedule(1250, now)
+	}
+	_, ok = link.schedule(1250, now)
+	require.False(t, ok)
+
+	// jittered packets stay in order
+	link = newImpairedLink(Impairment{Delay: 20 * time.Millisecond, Jitter: 20 * time.Millisecond}, 1)
+	var last time.Time
+	for i := 0; i < 100; i++ {
+		at, ok := link.schedule(100, now.Add(time.Duration(i)*time.Millisecond))
+		require.True(t, ok)
+		require.False(t, at.Before(last))
+		last = at
+	}
+
+	link = newImpairedLink(Impairment{Loss: 0.5}, 1)
+	dropped := 0
+	for i := 0; i < 1000; i++ {
+		if _, ok := link.schedule(100, now); !ok {
+			dropped++
+		}
+	}
+	require.InDelta(t, 500, dropped, 100)
+}
+
+func TestImpairedReader(t *testing.T) {
+	i, err := NewImpairmentInterceptorFactory(Impairment{Delay: 20 * time.Millisecond}).NewInterceptor("")
+	require.NoError(t, err)
+
+	packets := [][]byte{{1}, {2}, {3}}
+	reader := i.BindRemoteStream(&interceptor.StreamInfo{}, interceptor.RTPReaderFunc(
+		func(b []byte, _ interceptor.Attributes) (int, interceptor.Attributes, error) {
+			if len(packets) == 0 {
+				return 0, nil, io.EOF
+			}
+			n := copy(b, packets[0])
+			packets = packets[1:]
+			return n, nil, nil
+		}))
+
+	start := time.Now()
+	b := make([]byte, 1500)
+	for _, expected := range []byte{1, 2, 3} {
+		n, _, err := reader.Read(b, nil)
+		require.NoError(t, err)
+		require.Equal(t, []byte{expected}, b[:n])
+	}
+	require.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	_, _, err = reader.Read(b, nil)
+	require.ErrorIs(t, err, io.EOF)
+}
@@ -0,0 +1,152 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/nack"
+	"github.com/pion/interceptor/pkg/twcc"
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v3"
+
+	lkinterceptor "github.com/livekit/mediatransportutil/pkg/interceptor"
+	sdkinterceptor "github.com/livekit/server-sdk-go/v2/pkg/interceptor"
+)
+
+// interceptors returns the interceptors used by the SDK by default, which are replaced when any are given,
+// along with the ones that collect stats for this tester
+func (t *LoadTester) interceptors() ([]interceptor.Factory, error) {
+	var extra []interceptor.Factory
+	if t.params.Impairment != nil {
+		extra = append(extra, NewImpairmentInterceptorFactory(*t.params.Impairment))
+	}
+	return []interceptor.Factory{
+		&signalTimerFactory{onCreate: t.times.signaled},
+		newTransportInterceptorFactory(true, t.rtt.Store, append(extra, &feedbackCounterFactory{onFeedback: t.onFeedback})...),
+	}, nil
+}
+
+// ImpairedInterceptors returns the interceptors used by the SDK by default, with RTP packets impaired.
+// Pass them to lksdk.WithInterceptors
+func ImpairedInterceptors(impairment Impairment) ([]interceptor.Factory, error) {
+	return []interceptor.Factory{newTransportInterceptorFactory(true, nil, NewImpairmentInterceptorFactory(impairment))}, nil
+}
+
+// transportInterceptorFactory builds the SDK's default interceptors for each peer connection, after extra ones.
+// The SDK only uses given interceptors for the publisher transport, so the sender rules apply there. Neither a pacer
+// nor the retransmit buffer size are set by load tests, so they are left out
+type transportInterceptorFactory struct {
+	sender bool
+	onRTT  func(rtt uint32)
+	// closest to the network first, so that NACKs and reports reflect impaired packets
+	extra []interceptor.Factory
+}
+
+func newTransportInterceptorFactory(sender bool, onRTT func(rtt uint32), extra ...interceptor.Factory) *transportInterceptorFactory {
+	return &transportInterceptorFactory{sender: sender, onRTT: onRTT, extra: extra}
+}
+
+func (f *transportInterceptorFactory) NewInterceptor(id string) (interceptor.Interceptor, error) {
+	registry := &interceptor.Registry{}
+	for _, extra := range f.extra {
+		registry.Add(extra)
+	}
+
+	// each transport has its own NACK generator, timed by its own RTT
+	rtt := newTransportRTT(f.sender, &sdkinterceptor.NackGeneratorInterceptorFactory{}, f.onRTT)
+	responder, err := nack.NewResponderInterceptor()
+	if err != nil {
+		return nil, err
+	}
+	registry.Add(rtt.nackGenerator)
+	registry.Add(responder)
+	if err := webrtc.ConfigureRTCPReports(registry); err != nil {
+		return nil, err
+	}
+	twccSender, err := twcc.NewSenderInterceptor()
+	if err != nil {
+		return nil, err
+	}
+	registry.Add(twccSender)
+	registry.Add(sdkinterceptor.NewLimitSizeInterceptorFactory())
+	if f.sender {
+		registry.Add(sdkinterceptor.NewRTTInterceptorFactory(rtt.onReportRTT))
+	}
+	registry.Add(lkinterceptor.NewRTTFromXRFactory(rtt.onXRRTT))
+	return registry.Build(id)
+}
+
+// transportRTT follows the SDK's rules for the round trip time of a transport: the publisher measures it from
+// reports and only responds to XR requests, so that the server can measure it. The subscriber measures it from XR
+type transportRTT struct {
+	sender        bool
+	nackGenerator *sdkinterceptor.NackGeneratorInterceptorFactory
+	onRTT         func(rtt uint32)
+}
+
+func newTransportRTT(sender bool, nackGenerator *sdkinterceptor.NackGeneratorInterceptorFactory, onRTT func(rtt uint32)) *transportRTT {
+	return &transportRTT{sender: sender, nackGenerator: nackGenerator, onRTT: onRTT}
+}
+
+func (r *transportRTT) onReportRTT(rtt uint32) {
+	if r.sender {
+		r.set(rtt)
+	}
+}
+
+func (r *transportRTT) onXRRTT(rtt uint32) {
+	if !r.sender {
+		r.set(rtt)
+	}
+}
+
+func (r *transportRTT) set(rtt uint32) {
+	r.nackGenerator.SetRTT(rtt)
+	if r.onRTT != nil {
+		r.onRTT(rtt)
+	}
+}
+
+type feedbackCounterFactory struct {
+	onFeedback func(mediaSSRC uint32, nacks, plis int64)
+}
+
+func (f *feedbackCounterFactory) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	return &feedbackCounter{onFeedback: f.onFeedback}, nil
+}
+
+// feedbackCounter counts NACKed packets and PLIs written for each media SSRC
+type feedbackCounter struct {
+	interceptor.NoOp
+	onFeedback func(mediaSSRC uint32, nacks, plis int64)
+}
+
+func (c *feedbackCounter) BindRTCPWriter(writer interceptor.RTCPWriter) interceptor.RTCPWriter {
+	return interceptor.RTCPWriterFunc(func(pkts []rtcp.Packet, attributes interceptor.Attributes) (int, error) {
+		for _, pkt := range pkts {
+			switch p := pkt.(type) {
+			case *rtcp.TransportLayerNack:
+				var nacks int64
+				for _, pair := range p.Nacks {
+					nacks += int64(len(pair.PacketList()))
+				}
+				c.onFeedback(p.MediaSSRC, nacks, 0)
+			case *rtcp.PictureLossIndication:
+				c.onFeedback(p.MediaSSRC, 0, 1)
+			}
+		}
+		return writer.Write(pkts, attributes)
+	})
+}
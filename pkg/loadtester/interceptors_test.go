@@ -0,0 +1,55 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	sdkinterceptor "github.com/livekit/server-sdk-go/v2/pkg/interceptor"
+)
+
+func TestTransportRTT(t *testing.T) {
+	var measured []uint32
+	onRTT := func(rtt uint32) {
+		measured = append(measured, rtt)
+	}
+
+	// the publisher ignores RTT from XR, which it only answers for the server
+	sender := newTransportRTT(true, &sdkinterceptor.NackGeneratorInterceptorFactory{}, onRTT)
+	sender.onXRRTT(10)
+	sender.onReportRTT(20)
+	require.Equal(t, []uint32{20}, measured)
+
+	// the subscriber measures it from XR
+	measured = nil
+	receiver := newTransportRTT(false, &sdkinterceptor.NackGeneratorInterceptorFactory{}, onRTT)
+	receiver.onReportRTT(20)
+	receiver.onXRRTT(10)
+	require.Equal(t, []uint32{10}, measured)
+}
+
+func TestTransportInterceptorFactory(t *testing.T) {
+	// built again for each peer connection, such as when the SDK reconnects
+	f := newTransportInterceptorFactory(true, nil, &feedbackCounterFactory{onFeedback: func(uint32, int64, int64) {}})
+	first, err := f.NewInterceptor("pc1")
+	require.NoError(t, err)
+	second, err := f.NewInterceptor("pc2")
+	require.NoError(t, err)
+	require.NotSame(t, first, second)
+	require.NoError(t, first.Close())
+	require.NoError(t, second.Close())
+}
@@ -0,0 +1,487 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+
+	"github.com/livekit/protocol/livekit"
+	lksdk "github.com/livekit/server-sdk-go/v2"
+)
+
+const (
+	// layer switches that have not converged within this time are counted as timed out
+	layerSwitchTimeout = 10 * time.Second
+	// received bitrate is measured over windows of this length
+	layerBitrateWindow = 500 * time.Millisecond
+	// when the resolution of a codec is unknown, the layer has converged once the bitrate is within this ratio of the layer bitrate
+	layerBitrateTolerance = 0.5
+)
+
+// layerTracker follows the layer received on a video track after the subscriber requested a different one
+type layerTracker struct {
+	lock sync.Mutex
+	// target of the latest request, nil once converged or timed out
+	target      *livekit.VideoLayer
+	requestedAt time.Time
+
+	// resolution of the latest key frame, 0 when unknown
+	width, height uint32
+	// bytes received during the current window, and the bitrate of the previous one
+	windowStart time.Time
+	windowBytes int64
+	bitrate     float64
+}
+
+// request starts timing a switch to the target layer. Returns true when it replaced a pending switch, which is then
+// neither converged nor timed out
+func (l *layerTracker) request(target *livekit.VideoLayer, now time.Time) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	replaced := l.target != nil
+	l.target = target
+	l.requestedAt = now
+	return replaced
+}
+
+// onPacket updates the received layer, and returns how long the pending switch took once it has converged.
+// timedOut is true when the pending switch did not converge in time
+func (l *layerTracker) onPacket(pkt *rtp.Packet, mimeType string, now time.Time) (latency time.Duration, converged, timedOut bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if width, height, ok := keyFrameResolution(pkt.Payload, mimeType); ok {
+		l.width, l.height = width, height
+	}
+	if l.windowStart.IsZero() {
+		l.windowStart = now
+	}
+	l.windowBytes += int64(len(pkt.Payload))
+	if elapsed := now.Sub(l.windowStart); elapsed >= layerBitrateWindow {
+		l.bitrate = float64(l.windowBytes*8) / elapsed.Seconds()
+		l.windowStart = now
+		l.windowBytes = 0
+	}
+
+	if l.target == nil {
+		return 0, false, false
+	}
+	latency = now.Sub(l.requestedAt)
+	if l.matches(l.target) {
+		l.target = nil
+		return latency, true, false
+	}
+	if latency > layerSwitchTimeout {
+		l.target = nil
+		return 0, false, true
+	}
+	return 0, false, false
+}
+
+// matches compares the received resolution with the layer, or the bitrate when the resolution is unknown
+func (l *layerTracker) matches(layer *livekit.VideoLayer) bool {
+	if l.width != 0 && l.height != 0 {
+		return l.width == layer.Width && l.height == layer.Height
+	}
+	if layer.Bitrate == 0 || l.bitrate == 0 {
+		return false
+	}
+	expected := float64(layer.Bitrate)
+	return l.bitrate >= expected*(1-layerBitrateTolerance) && l.bitrate <= expected*(1+layerBitrateTolerance)
+}
+
+// expectedLayer returns the published layer that should be received for a quality, the highest one available at or below it
+func expectedLayer(info *livekit.TrackInfo, quality livekit.VideoQuality) *livekit.VideoLayer {
+	if info == nil || len(info.Layers) == 0 || quality == livekit.VideoQuality_OFF {
+		return nil
+	}
+	layers := append([]*livekit.VideoLayer{}, info.Layers...)
+	sort.Slice(layers, func(i, j int) bool {
+		return layers[i].Quality < layers[j].Quality
+	})
+	layer := layers[0]
+	for _, l := range layers {
+		if l.Quality <= quality {
+			layer = l
+		}
+	}
+	return layer
+}
+
+// keyFrameResolution returns the resolution carried by a packet starting a VP8 key frame, or by an H.264 SPS
+func keyFrameResolution(payload []byte, mimeType string) (uint32, uint32, bool) {
+	switch strings.ToLower(mimeType) {
+	case "video/vp8":
+		vp8 := &codecs.VP8Packet{}
+		frame, err := vp8.Unmarshal(payload)
+		if err != nil || vp8.S != 1 || vp8.PID != 0 || len(frame) < 10 {
+			return 0, 0, false
+		}
+		// key frames have the inverse key frame bit unset, and a start code
+		if frame[0]&0x01 != 0 || frame[3] != 0x9d || frame[4] != 0x01 || frame[5] != 0x2a {
+			return 0, 0, false
+		}
+		width := uint32(binary.LittleEndian.Uint16(frame[6:]) & 0x3fff)
+		height := uint32(binary.LittleEndian.Uint16(frame[8:]) & 0x3fff)
+		return width, height, true
+
+	case "video/h264":
+		if len(payload) == 0 {
+			return 0, 0, false
+		}
+		switch payload[0] & 0x1f {
+		case 7:
+			return parseSPSResolution(payload)
+		case 24:
+			// STAP-A, NAL units prefixed by their size
+			for b := payload[1:]; len(b) > 2; {
+				size := int(binary.BigEndian.Uint16(b))
+				b = b[2:]
+				if size == 0 || size > len(b) {
+					break
+				}
+				if b[0]&0x1f == 7 {
+					return parseSPSResolution(b[:size])
+				}
+				b = b[size:]
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// parseSPSResolution reads the cropped frame size from an H.264 sequence parameter set NAL unit
+func parseSPSResolution(nal []byte) (uint32, uint32, bool) {
+	// remove emulation prevention bytes
+	rbsp := make([]byte, 0, len(nal))
+	for i := 1; i < len(nal); i++ {
+		if i >= 3 && nal[i] == 3 && nal[i-1] == 0 && nal[i-2] == 0 {
+			continue
+		}
+		rbsp = append(rbsp, nal[i])
+	}
+	r := &bitReader{buf: rbsp}
+
+	profile := r.bits(8)
+	r.bits(16) // constraint flags and level
+	r.ue()     // seq_parameter_set_id
+	chromaFormat := uint32(1)
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		chromaFormat = r.ue()
+		if chromaFormat == 3 {
+			r.bits(1) // separate_colour_plane_flag
+		}
+		r.ue()    // bit_depth_luma_minus8
+		r.ue()    // bit_depth_chroma_minus8
+		r.bits(1) // qpprime_y_zero_transform_bypass_flag
+		if r.bits(1) == 1 {
+			lists := 8
+			if chromaFormat == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				if r.bits(1) == 0 {
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				last, next := int32(8), int32(8)
+				for j := 0; j < size && next != 0; j++ {
+					next = (last + r.se() + 256) % 256
+					if next != 0 {
+						last = next
+					}
+				}
+			}
+		}
+	}
+	r.ue() // log2_max_frame_num_minus4
+	switch r.ue() {
+	case 0:
+		r.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.bits(1) // delta_pic_order_always_zero_flag
+		r.se()    // offset_for_non_ref_pic
+		r.se()    // offset_for_top_to_bottom_field
+		for n := r.ue(); n > 0 && !r.failed; n-- {
+			r.se()
+		}
+	}
+	r.ue()    // max_num_ref_frames
+	r.bits(1) // gaps_in_frame_num_value_allowed_flag
+	widthMbs := r.ue() + 1
+	heightMapUnits := r.ue() + 1
+	frameMbsOnly := r.bits(1)
+	if frameMbsOnly == 0 {
+		r.bits(1) // mb_adaptive_frame_field_flag
+	}
+	r.bits(1) // direct_8x8_inference_flag
+	var cropLeft, cropRight, cropTop, cropBottom uint32
+	if r.bits(1) == 1 {
+		cropLeft, cropRight, cropTop, cropBottom = r.ue(), r.ue(), r.ue(), r.ue()
+	}
+	if r.failed {
+		return 0, 0, false
+	}
+
+	cropX, cropY := uint32(1), 2-frameMbsOnly
+	if chromaFormat == 1 || chromaFormat == 2 {
+		cropX = 2
+	}
+	if chromaFormat == 1 {
+		cropY *= 2
+	}
+	width := widthMbs*16 - (cropLeft+cropRight)*cropX
+	height := (2-frameMbsOnly)*heightMapUnits*16 - (cropTop+cropBottom)*cropY
+	return width, height, true
+}
+
+// bitReader reads bits and Exp-Golomb codes, failing when the buffer runs out
+type bitReader struct {
+	buf    []byte
+	pos    int
+	failed bool
+}
+
+func (r *bitReader) bits(n int) uint32 {
+	var v uint32
+	for i := 0; i < n; i++ {
+		if r.pos >= len(r.buf)*8 {
+			r.failed = true
+			return 0
+		}
+		v = v<<1 | uint32(r.buf[r.pos/8]>>(7-r.pos%8))&1
+		r.pos++
+	}
+	return v
+}
+
+func (r *bitReader) ue() uint32 {
+	zeros := 0
+	for r.bits(1) == 0 {
+		if r.failed || zeros > 31 {
+			r.failed = true
+			return 0
+		}
+		zeros++
+	}
+	return 1<<zeros - 1 + r.bits(zeros)
+}
+
+func (r *bitReader) se() int32 {
+	v := r.ue()
+	if v%2 == 1 {
+		return int32(v/2 + 1)
+	}
+	return -int32(v / 2)
+}
+
+// switchLayouts changes the layout of a subscriber every interval on average, until the tester is stopped
+func (t *LoadTester) switchLayouts(interval time.Duration, done chan struct{}) {
+	for {
+		// 0.5x to 1.5x the interval, so that subscribers do not switch in lockstep
+		wait := interval/2 + time.Duration(rand.Int63n(int64(interval)))
+		select {
+		case <-done:
+			return
+		case <-time.After(wait):
+		}
+		// waits for a rejoin to finish, the layout is kept while disconnected
+		t.withRoom(func(*lksdk.Room) {
+			t.switchLayout()
+		})
+	}
+}
+
+// switchLayout moves the focus to another participant. In the speaker layout, the new speaker swaps qualities with the
+// previous one. In grid layouts, another tile is pinned at high quality and the previously pinned tile goes back to the grid
+func (t *LoadTester) switchLayout() {
+	t.lock.Lock()
+	var candidates []string
+	for sid := range t.videoPubs {
+		if sid != t.focused {
+			candidates = append(candidates, sid)
+		}
+	}
+	if len(candidates) == 0 {
+		t.lock.Unlock()
+		return
+	}
+	sort.Strings(candidates)
+	next := candidates[rand.Intn(len(candidates))]
+
+	changes := map[string]livekit.VideoQuality{next: livekit.VideoQuality_HIGH}
+	if t.focused != "" {
+		if t.params.Layout == LayoutSpeaker {
+			changes[t.focused] = t.trackQualities[next]
+		} else {
+			changes[t.focused] = t.gridQuality()
+		}
+	} else if t.params.Layout == LayoutSpeaker {
+		// the speaker is not focused by a switch yet
+		for sid, q := range t.trackQualities {
+			if q == livekit.VideoQuality_HIGH && sid != next {
+				changes[sid] = t.trackQualities[next]
+			}
+		}
+	}
+	t.focused = next
+
+	type switchRequest struct {
+		pub     *lksdk.RemoteTrackPublication
+		from    livekit.VideoQuality
+		quality livekit.VideoQuality
+	}
+	var requests []switchRequest
+	for sid, q := range changes {
+		pub := t.videoPubs[sid]
+		if pub == nil || t.trackQualities[sid] == q {
+			continue
+		}
+		requests = append(requests, switchRequest{pub: pub, from: t.trackQualities[sid], quality: q})
+		t.trackQualities[sid] = q
+	}
+	t.lock.Unlock()
+
+	now := time.Now()
+	for _, r := range requests {
+		if r.from == livekit.VideoQuality_OFF {
+			r.pub.SetEnabled(true)
+		}
+		setVideoQuality(r.pub, r.quality)
+		track := r.pub.TrackRemote()
+		if target := expectedLayer(r.pub.TrackInfo(), r.quality); target != nil && track != nil {
+			if value, ok := t.stats.Load(track.ID()); ok {
+				ts := value.(*trackStats)
+				ts.layerSwitches.Inc()
+				if ts.layer.request(target, now) {
+					ts.layerReplaced.Inc()
+				}
+			}
+		}
+	}
+}
+
+// gridQuality returns the quality of tiles in grid layouts
+func (t *LoadTester) gridQuality() livekit.VideoQuality {
+	if t.params.Layout == LayoutGrid3x3 {
+		return livekit.VideoQuality_MEDIUM
+	}
+	return livekit.VideoQuality_LOW
+}
+
+// setVideoQuality requests the dimensions of a quality, or disables the track when OFF
+func setVideoQuality(pub *lksdk.RemoteTrackPublication, quality livekit.VideoQuality) {
+	switch quality {
+	case livekit.VideoQuality_HIGH:
+		pub.SetVideoDimensions(highWidth, highHeight)
+	case livekit.VideoQuality_MEDIUM:
+		pub.SetVideoDimensions(mediumWidth, mediumHeight)
+	case livekit.VideoQuality_LOW:
+		pub.SetVideoDimensions(lowWidth, lowHeight)
+	case livekit.VideoQuality_OFF:
+		pub.SetEnabled(false)
+	}
+}
+
+// layerSwitchSummary adds up the layer switches of video tracks
+type layerSwitchSummary struct {
+	switches  int64
+	converged int64
+	timeouts  int64
+	// switches made before the previous one converged or timed out
+	replaced int64
+	latency  latencyStats
+}
+
+func (s *layerSwitchSummary) addTrack(ts *trackStats) {
+	s.switches += ts.layerSwitches.Load()
+	s.timeouts += ts.layerTimeouts.Load()
+	s.replaced += ts.layerReplaced.Load()
+	s.converged += ts.layerLatency.samples()
+	s.latency.merge(&ts.layerLatency)
+}
+
+func getLayerSwitchSummary(testerStats *testerStats) *layerSwitchSummary {
+	s := &layerSwitchSummary{}
+	for _, ts := range testerStats.trackStats {
+		s.addTrack(ts)
+	}
+	return s
+}
+
+// printLayerSwitchResults prints how long subscribers took to receive the layers they switched to, when layouts were switched
+func printLayerSwitchResults(out io.Writer, names []string, stats map[string]*testerStats) {
+	total := &layerSwitchSummary{}
+	summaries := make(map[string]*layerSwitchSummary)
+	for _, name := range names {
+		s := getLayerSwitchSummary(stats[name])
+		summaries[name] = s
+		total.switches += s.switches
+		total.converged += s.converged
+		total.timeouts += s.timeouts
+		total.replaced += s.replaced
+		total.latency.merge(&s.latency)
+	}
+	if total.switches == 0 {
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 1, 1, 1, ' ', 0)
+	_, _ = fmt.Fprint(w, "\nLayer Switches\t| Tester\t| Switches\t| Converged\t| Timed Out\t| Replaced\t| Convergence (p50/p95/p99)\n")
+	for _, name := range names {
+		s := summaries[name]
+		if s.switches == 0 {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "\t| %s\t| %d\t| %d\t| %d\t| %d\t| %s\n",
+			name, s.switches, s.converged, s.timeouts, s.replaced, formatLatency(&s.latency))
+	}
+	_, _ = fmt.Fprintf(w, "\t| %s\t| %d\t| %d\t| %d\t| %d\t| %s\n",
+		"Total", total.switches, total.converged, total.timeouts, total.replaced, formatLatency(&total.latency))
+	_ = w.Flush()
+}
+
+func newLayerSwitchReport(s *layerSwitchSummary) *LayerSwitchReport {
+	if s.switches == 0 {
+		return nil
+	}
+	r := &LayerSwitchReport{
+		Switches:  s.switches,
+		Converged: s.converged,
+		TimedOut:  s.timeouts,
+		Replaced:  s.replaced,
+	}
+	if s.latency.samples() > 0 {
+		r.ConvergenceP50Ms = s.latency.percentile(50).Milliseconds()
+		r.ConvergenceP95Ms = s.latency.percentile(95).Milliseconds()
+		r.ConvergenceP99Ms = s.latency.percentile(99).Milliseconds()
+	}
+	return r
+}
@@ -0,0 +1,96 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/livekit"
+)
+
+func TestKeyFrameResolution(t *testing.T) {
+	// high profile SPS with emulation prevention bytes
+	sps := []byte{0x67, 0x64, 0x00, 0x1f, 0xac, 0xd9, 0x40, 0x50, 0x05, 0xbb, 0x01, 0x10, 0x00, 0x00, 0x03,
+		0x00, 0x10, 0x00, 0x00, 0x03, 0x03, 0xc0, 0xf1, 0x83, 0x19, 0x60}
+	width, height, ok := keyFrameResolution(sps, "video/H264")
+	require.True(t, ok)
+	require.Equal(t, [2]uint32{1280, 720}, [2]uint32{width, height})
+
+	// in a STAP-A, after a PPS
+	pps := []byte{0x68, 0xeb, 0xe3, 0xcb, 0x22, 0xc0}
+	stap := []byte{24, 0, byte(len(pps))}
+	stap = append(stap, pps...)
+	stap = append(stap, 0, byte(len(sps)))
+	stap = append(stap, sps...)
+	width, height, ok = keyFrameResolution(stap, "video/H264")
+	require.True(t, ok)
+	require.Equal(t, [2]uint32{1280, 720}, [2]uint32{width, height})
+
+	_, _, ok = keyFrameResolution(pps, "video/H264")
+	require.False(t, ok)
+
+	// VP8 payload descriptor starting a partition, then a key frame header with a 640x360 frame
+	vp8 := []byte{0x10, 0x50, 0x42, 0x00, 0x9d, 0x01, 0x2a, 0x80, 0x02, 0x68, 0x01, 0x00, 0x00}
+	width, height, ok = keyFrameResolution(vp8, "video/VP8")
+	require.True(t, ok)
+	require.Equal(t, [2]uint32{640, 360}, [2]uint32{width, height})
+
+	// inter frame
+	vp8[1] |= 0x01
+	_, _, ok = keyFrameResolution(vp8, "video/VP8")
+	require.False(t, ok)
+}
+
+func TestLayerTracker(t *testing.T) {
+	info := &livekit.TrackInfo{Layers: []*livekit.VideoLayer{
+		{Quality: livekit.VideoQuality_HIGH, Width: 1280, Height: 720, Bitrate: 2_000_000},
+		{Quality: livekit.VideoQuality_LOW, Width: 320, Height: 180, Bitrate: 150_000},
+	}}
+	require.Equal(t, uint32(180), expectedLayer(info, livekit.VideoQuality_MEDIUM).Height)
+	require.Equal(t, uint32(720), expectedLayer(info, livekit.VideoQuality_HIGH).Height)
+	require.Nil(t, expectedLayer(info, livekit.VideoQuality_OFF))
+
+	// without a known resolution, the layer is matched by bitrate
+	l := &layerTracker{}
+	start := time.Now()
+	require.False(t, l.request(expectedLayer(info, livekit.VideoQuality_LOW), start))
+	pkt := &rtp.Packet{Payload: make([]byte, 1000)}
+	var converged bool
+	var latency time.Duration
+	// 1000 bytes every 10ms is 800kbps, too much for the low layer
+	for i := 0; i < 200 && !converged; i++ {
+		if i == 100 {
+			// 200 bytes every 10ms is 160kbps
+			pkt.Payload = make([]byte, 200)
+		}
+		latency, converged, _ = l.onPacket(pkt, "video/VP8", start.Add(time.Duration(i)*10*time.Millisecond))
+	}
+	require.True(t, converged)
+	require.Greater(t, latency, time.Second)
+
+	// timed out
+	require.False(t, l.request(expectedLayer(info, livekit.VideoQuality_HIGH), start.Add(2*time.Second)))
+	_, converged, timedOut := l.onPacket(pkt, "video/VP8", start.Add(2*time.Second+layerSwitchTimeout+time.Millisecond))
+	require.False(t, converged)
+	require.True(t, timedOut)
+
+	// a switch made before the previous one converged replaces it
+	require.False(t, l.request(expectedLayer(info, livekit.VideoQuality_HIGH), start.Add(20*time.Second)))
+	require.True(t, l.request(expectedLayer(info, livekit.VideoQuality_LOW), start.Add(21*time.Second)))
+}
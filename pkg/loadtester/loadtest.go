@@ -17,9 +17,9 @@ package loadtester
 import (
 	"context"
 	"fmt"
+	"io"
 	"math/rand"
 	"net/url"
-	"os"
 	"sort"
 	"strings"
 	"sync"
@@ -29,13 +29,35 @@ import (
 	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/sync/syncmap"
-	"golang.org/x/time/rate"
+
+	lksdk "github.com/livekit/server-sdk-go/v2"
 )
 
+// bitrate of timestamped audio tracks, in bps
+const timestampedAudioBitrate = 32000
+
+// timestampedVideoBitrate matches the top layer bitrate of the video loopers
+func timestampedVideoBitrate(resolution string) uint32 {
+	switch resolution {
+	case "medium":
+		return 600000
+	case "low":
+		return 150000
+	default:
+		return 2000000
+	}
+}
+
 type LoadTest struct {
 	Params     Params
 	trackNames map[string]string
-	lock       sync.Mutex
+	// testers of the current run
+	testers []*LoadTester
+	// churn results of the current run
+	churnCycles []*ChurnCycleReport
+	// joins of the current run
+	ramp *rampTracker
+	lock sync.Mutex
 }
 
 type Params struct {
@@ -45,10 +67,36 @@ type Params struct {
 	VideoResolution string
 	VideoCodec      string
 	Duration        time.Duration
-	// number of seconds to spin up per second
-	NumPerSecond     float64
+	// number of testers to spin up per second
+	NumPerSecond float64
+	// how testers are spread over time as they join, at NumPerSecond by default
+	Arrival          ArrivalParams
 	Simulcast        bool
 	SimulateSpeakers bool
+	// publish synthetic timestamped tracks in place of media, to measure end-to-end latency
+	MeasureLatency bool
+	// file to write machine-readable results to
+	ReportFile   string
+	ReportFormat ReportFormat
+	// also writes results here as JSON, when set
+	ReportOutput io.Writer
+	// port to serve Prometheus metrics on while the test is running, disabled when 0
+	PrometheusPort int
+	// pass/fail criteria checked once the test completes
+	Thresholds Thresholds
+	// number of rooms to spread testers across, named Room_0, Room_1... when more than one
+	Rooms            int
+	RoomDistribution RoomDistribution
+	// layouts assigned to rooms in turn, Layout is used for all rooms when empty
+	Layouts []Layout
+	// network impairments applied to a share of testers
+	Impairments []ImpairmentAssignment
+	// testers leaving and coming back during the test
+	Churn ChurnParams
+	// data messages sent by publishers
+	Data DataParams
+	// tester sequences to run, all of them when empty. Set when running as an agent
+	sequences []int
 
 	TesterParams
 }
@@ -62,32 +110,76 @@ func NewLoadTest(params Params) *LoadTest {
 		// sane default
 		l.Params.NumPerSecond = 5
 	}
-	if l.Params.NumPerSecond > 10 {
-		l.Params.NumPerSecond = 10
-	}
-	if l.Params.VideoPublishers == 0 && l.Params.AudioPublishers == 0 && l.Params.Subscribers == 0 {
+	if l.Params.numPublishers() == 0 && l.Params.Subscribers == 0 {
 		l.Params.VideoPublishers = 1
 		l.Params.Subscribers = 1
 	}
 	return l
 }
 
-func (t *LoadTest) Run(ctx context.Context) error {
-	parsedUrl, err := url.Parse(t.Params.URL)
+// numPublishers returns the number of publishers, which come first among tester sequences
+func (p *Params) numPublishers() int {
+	return max(p.VideoPublishers, p.AudioPublishers, p.Data.Publishers)
+}
+
+func checkAcceptableUse(params *Params) error {
+	parsedUrl, err := url.Parse(params.URL)
 	if err != nil {
 		return err
 	}
 	if strings.HasSuffix(parsedUrl.Hostname(), ".livekit.cloud") {
-		if t.Params.VideoPublishers > 50 || t.Params.Subscribers > 50 || t.Params.AudioPublishers > 50 {
+		if params.numPublishers() > 50 || params.Subscribers > 50 {
 			return errors.New("Unable to perform load test on LiveKit Cloud. Load testing is prohibited by our acceptable use policy: https://livekit.io/legal/acceptable-use-policy")
 		}
 	}
+	return nil
+}
+
+func (t *LoadTest) Run(ctx context.Context) error {
+	err := checkAcceptableUse(&t.Params)
+	if err != nil {
+		return err
+	}
+
+	if t.Params.PrometheusPort != 0 {
+		stop, err := t.startMetricsServer()
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
 
-	stats, err := t.run(ctx, t.Params)
+	params := t.Params
+	startedAt := time.Now()
+	stats, err := t.run(ctx, &params)
 	if err != nil {
 		return err
 	}
+	if t.Params.writesReport() {
+		report := &Report{
+			Tests: []*TestReport{t.newTestReport(params, startedAt, time.Now(), stats)},
+		}
+		if err = t.writeReport(report); err != nil {
+			return err
+		}
+	}
+
+	t.printResults(stats)
+	t.printRunResults()
+	return t.checkThresholds(stats)
+}
+
+// printRunResults prints how testers joined and churned during the current run
+func (t *LoadTest) printRunResults() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.ramp != nil {
+		printRampResults(t.Params.output(), t.ramp.toReport())
+	}
+	printChurnResults(t.Params.output(), t.churnCycles)
+}
 
+func (t *LoadTest) printResults(stats map[string]*testerStats) {
 	// tester results
 	summaries := make(map[string]*summary)
 	names := make([]string, 0, len(stats))
@@ -102,8 +194,8 @@ func (t *LoadTest) Run(ctx context.Context) error {
 		testerStats := stats[name]
 		summaries[name] = getTesterSummary(testerStats)
 
-		w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
-		_, _ = fmt.Fprintf(w, "\n%s\t| Track\t| Kind\t| Pkts\t| Bitrate\t| Dropped\n", name)
+		w := tabwriter.NewWriter(t.Params.output(), 1, 1, 1, ' ', 0)
+		_, _ = fmt.Fprintf(w, "\n%s\t| Track\t| Kind\t| Codec\t| Pkts\t| Bitrate\t| Dropped\t| Jitter\t| NACKs\t| PLIs\t| Freezes\t| Latency (p50/p95/p99)\n", name)
 		trackStatsSlice := make([]*trackStats, 0, len(testerStats.trackStats))
 		for _, ts := range testerStats.trackStats {
 			trackStatsSlice = append(trackStatsSlice, ts)
@@ -118,27 +210,33 @@ func (t *LoadTest) Run(ctx context.Context) error {
 				trackStats.packets.Load(), trackStats.dropped.Load())
 
 			trackName := t.trackNames[trackStats.trackID]
-			_, _ = fmt.Fprintf(w, "\t| %s %s\t| %s\t| %d\t| %s\t| %s\n",
-				trackName, trackStats.trackID, trackStats.kind, trackStats.packets.Load(),
-				formatBitrate(trackStats.bytes.Load(), time.Since(trackStats.startedAt.Load())), dropped)
+			_, _ = fmt.Fprintf(w, "\t| %s %s\t| %s\t| %s\t| %d\t| %s\t| %s\t| %s\t| %d\t| %d\t| %s\t| %s\n",
+				trackName, trackStats.trackID, trackStats.kind, trackStats.codec, trackStats.packets.Load(),
+				formatBitrate(trackStats.bytes.Load(), trackStats.elapsed()), dropped,
+				formatMs(trackStats.jitter.Load()), trackStats.nacks.Load(), trackStats.plis.Load(),
+				formatFreezes(trackStats.freezes.Load(), trackStats.freezeDuration.Load()),
+				formatLatency(&trackStats.latency))
 		}
 		_ = w.Flush()
 	}
 
 	if len(summaries) == 0 {
-		return nil
+		printDataResults(t.Params.output(), names, stats)
+		printSetupResults(t.Params.output(), stats)
+		return
 	}
 
 	// summary
-	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
-	_, _ = fmt.Fprint(w, "\nSummary\t| Tester\t| Tracks\t| Bitrate\t| Total Dropped\t| Error\n")
+	w := tabwriter.NewWriter(t.Params.output(), 1, 1, 1, ' ', 0)
+	_, _ = fmt.Fprint(w, "\nSummary\t| Tester\t| Tracks\t| Bitrate\t| Total Dropped\t| Jitter\t| NACKs\t| PLIs\t| Freezes\t| RTT\t| Latency (p50/p95/p99)\t| Error\n")
 
 	for _, name := range names {
 		s := summaries[name]
 		sDropped := formatStrings(s.packets, s.dropped)
 		sBitrate := formatBitrate(s.bytes, s.elapsed)
-		_, _ = fmt.Fprintf(w, "\t| %s\t| %d/%d\t| %s\t| %s\t| %s\n",
-			name, s.tracks, s.expected, sBitrate, sDropped, s.errString)
+		_, _ = fmt.Fprintf(w, "\t| %s\t| %d/%d\t| %s\t| %s\t| %s\t| %d\t| %d\t| %s\t| %s\t| %s\t| %s\n",
+			name, s.tracks, s.expected, sBitrate, sDropped, formatMs(s.jitter()), s.nacks, s.plis,
+			formatFreezes(s.freezes, s.freezeDuration), formatMs(s.rtt()), formatLatency(&s.latency), s.errString)
 	}
 
 	s := getTestSummary(summaries)
@@ -148,11 +246,44 @@ func (t *LoadTest) Run(ctx context.Context) error {
 		formatBitrate(s.bytes, s.elapsed),
 		formatBitrate(s.bytes/int64(len(summaries)), s.elapsed),
 	)
-	_, _ = fmt.Fprintf(w, "\t| %s\t| %d/%d\t| %s\t| %s\t| %d\n",
-		"Total", s.tracks, s.expected, sBitrate, sDropped, s.errCount)
+	_, _ = fmt.Fprintf(w, "\t| %s\t| %d/%d\t| %s\t| %s\t| %s\t| %d\t| %d\t| %s\t| %s\t| %s\t| %d\n",
+		"Total", s.tracks, s.expected, sBitrate, sDropped, formatMs(s.jitter()), s.nacks, s.plis,
+		formatFreezes(s.freezes, s.freezeDuration), formatMs(s.rtt()), formatLatency(&s.latency), s.errCount)
 
 	_ = w.Flush()
-	return nil
+	printClockWarning(t.Params.output(), "latency", &s.latency)
+
+	subscriberStats := make([]*testerStats, 0, len(names))
+	for _, name := range names {
+		subscriberStats = append(subscriberStats, stats[name])
+	}
+	printCodecResults(t.Params.output(), getCodecSummaries(subscriberStats))
+	printRoomResults(t.Params.output(), names, summaries, stats)
+	printImpairmentResults(t.Params.output(), names, summaries, stats)
+	printDataResults(t.Params.output(), names, stats)
+	printSpeakerResults(t.Params.output(), names, stats)
+	printLayerSwitchResults(t.Params.output(), names, stats)
+	printSetupResults(t.Params.output(), stats)
+}
+
+func printCodecResults(out io.Writer, summaries map[string]*summary) {
+	if len(summaries) == 0 {
+		return
+	}
+	codecs := make([]string, 0, len(summaries))
+	for codec := range summaries {
+		codecs = append(codecs, codec)
+	}
+	sort.Strings(codecs)
+
+	w := tabwriter.NewWriter(out, 1, 1, 1, ' ', 0)
+	_, _ = fmt.Fprint(w, "\nCodecs\t| Codec\t| Tracks\t| Bitrate\t| Total Dropped\t| Latency (p50/p95/p99)\n")
+	for _, codec := range codecs {
+		s := summaries[codec]
+		_, _ = fmt.Fprintf(w, "\t| %s\t| %d\t| %s\t| %s\t| %s\n",
+			codec, s.tracks, formatBitrate(s.bytes, s.elapsed), formatStrings(s.packets, s.dropped), formatLatency(&s.latency))
+	}
+	_ = w.Flush()
 }
 
 func (t *LoadTest) RunSuite(ctx context.Context) error {
@@ -179,8 +310,18 @@ func (t *LoadTest) RunSuite(ctx context.Context) error {
 		{publishers: 1, subscribers: 1000, video: true},
 	}
 
-	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
-	_, _ = fmt.Fprint(w, "\nPubs\t| Subs\t| Tracks\t| Audio\t| Video\t| Packet loss\t| Errors\n")
+	if t.Params.PrometheusPort != 0 {
+		stop, err := t.startMetricsServer()
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+
+	report := &Report{}
+	var breaches []string
+	w := tabwriter.NewWriter(t.Params.output(), 1, 1, 1, ' ', 0)
+	_, _ = fmt.Fprint(w, "\nPubs\t| Subs\t| Tracks\t| Audio\t| Video\t| Packet loss\t| Latency (p95)\t| Errors\n")
 
 	for _, c := range cases {
 		caseParams := t.Params
@@ -196,42 +337,96 @@ func (t *LoadTest) RunSuite(ctx context.Context) error {
 		if caseParams.Duration == 0 {
 			caseParams.Duration = 15 * time.Second
 		}
-		fmt.Printf("\nRunning test: %d pub, %d sub, video: %s\n", c.publishers, c.subscribers, videoString)
+		fmt.Fprintf(t.Params.output(), "\nRunning test: %d pub, %d sub, video: %s\n", c.publishers, c.subscribers, videoString)
 
-		stats, err := t.run(ctx, caseParams)
+		startedAt := time.Now()
+		stats, err := t.run(ctx, &caseParams)
 		if err != nil {
 			return err
 		}
 		if ctx.Err() != nil {
 			return err
 		}
+		report.Tests = append(report.Tests, t.newTestReport(caseParams, startedAt, time.Now(), stats))
+
+		// thresholds apply to each case, a breach doesn't stop the rest of the suite
+		var thresholdErr *ThresholdError
+		if err := t.checkThresholds(stats); errors.As(err, &thresholdErr) {
+			for _, b := range thresholdErr.Breaches {
+				breaches = append(breaches, fmt.Sprintf("%d pub, %d sub, video: %s: %s", c.publishers, c.subscribers, videoString, b))
+			}
+		}
 
 		var tracks, packets, dropped, errCount int64
+		var latency latencyStats
 		for _, testerStats := range stats {
 			for _, trackStats := range testerStats.trackStats {
 				tracks++
 				packets += trackStats.packets.Load()
 				dropped += trackStats.dropped.Load()
+				latency.merge(&trackStats.latency)
 			}
 			if testerStats.err != nil {
 				errCount++
 			}
 		}
-		_, _ = fmt.Fprintf(w, "%d\t| %d\t| %d\t| Yes\t| %s\t| %.3f%%| %d\t\n",
-			c.publishers, c.subscribers, tracks, videoString, 100*float64(dropped)/float64(dropped+packets), errCount)
+		c.tracks = tracks
+		c.dropped = 100 * float64(dropped) / float64(dropped+packets)
+		sLatency := " - "
+		if latency.samples() > 0 {
+			c.latency = latency.percentile(95)
+			sLatency = c.latency.String()
+		}
+		_, _ = fmt.Fprintf(w, "%d\t| %d\t| %d\t| Yes\t| %s\t| %.3f%%| %s\t| %d\t\n",
+			c.publishers, c.subscribers, tracks, videoString, c.dropped, sLatency, errCount)
 	}
 
 	_ = w.Flush()
+	if t.Params.writesReport() {
+		if err := t.writeReport(report); err != nil {
+			return err
+		}
+	}
+	if len(breaches) > 0 {
+		return &ThresholdError{Breaches: breaches}
+	}
+	return nil
+}
+
+func (p *Params) writesReport() bool {
+	return p.ReportFile != "" || p.ReportOutput != nil
+}
+
+func (t *LoadTest) writeReport(report *Report) error {
+	if t.Params.ReportOutput != nil {
+		if err := report.writeJSON(t.Params.ReportOutput); err != nil {
+			return errors.Wrap(err, "could not write report")
+		}
+	}
+	if t.Params.ReportFile == "" {
+		return nil
+	}
+	format := t.Params.ReportFormat
+	if format == "" {
+		format = ReportFormatFromPath(t.Params.ReportFile)
+	}
+	if err := report.WriteFile(t.Params.ReportFile, format); err != nil {
+		return errors.Wrap(err, "could not write report")
+	}
+	fmt.Fprintln(t.Params.output(), "Wrote report to", t.Params.ReportFile)
 	return nil
 }
 
-func (t *LoadTest) run(ctx context.Context, params Params) (map[string]*testerStats, error) {
+func (t *LoadTest) run(ctx context.Context, params *Params) (map[string]*testerStats, error) {
 	if params.Room == "" {
 		params.Room = fmt.Sprintf("testroom%d", rand.Int31n(1000))
 	}
-	params.IdentityPrefix = randStringRunes(5)
+	if params.IdentityPrefix == "" {
+		params.IdentityPrefix = randStringRunes(5)
+	}
 
-	expectedTracks := params.VideoPublishers + params.AudioPublishers
+	plan := newRoomPlan(params)
+	impairments := impairmentPlan(params)
 
 	var participantStrings []string
 	if params.VideoPublishers > 0 {
@@ -240,29 +435,62 @@ func (t *LoadTest) run(ctx context.Context, params Params) (map[string]*testerSt
 	if params.AudioPublishers > 0 {
 		participantStrings = append(participantStrings, fmt.Sprintf("%d audio publishers", params.AudioPublishers))
 	}
+	if params.Data.Publishers > 0 {
+		participantStrings = append(participantStrings, fmt.Sprintf("%d data publishers", params.Data.Publishers))
+	}
 	if params.Subscribers > 0 {
 		participantStrings = append(participantStrings, fmt.Sprintf("%d subscribers", params.Subscribers))
 	}
-	fmt.Printf("Starting load test with %s, room: %s\n",
-		strings.Join(participantStrings, ", "), params.Room)
+	if len(plan.names) > 1 {
+		fmt.Fprintf(t.Params.output(), "Starting load test with %s, %d rooms: %s_*\n",
+			strings.Join(participantStrings, ", "), len(plan.names), params.Room)
+	} else {
+		fmt.Fprintf(t.Params.output(), "Starting load test with %s, room: %s\n",
+			strings.Join(participantStrings, ", "), params.Room)
+	}
+	if len(params.Impairments) > 0 {
+		fmt.Fprintf(t.Params.output(), "Impairing networks: %s\n", impairmentNames(params.Impairments))
+	}
 
-	var publishers, testers []*LoadTester
+	var testers []*LoadTester
+	// room => publishers
+	publishers := make(map[int][]*LoadTester)
+	subscribers := make(map[int][]*LoadTester)
 	group, _ := errgroup.WithContext(ctx)
 	errs := syncmap.Map{}
-	maxPublishers := params.VideoPublishers
-	if params.AudioPublishers > maxPublishers {
-		maxPublishers = params.AudioPublishers
+	maxPublishers := params.numPublishers()
+
+	sequences := params.sequences
+	if len(sequences) == 0 {
+		for i := 0; i < maxPublishers+params.Subscribers; i++ {
+			sequences = append(sequences, i)
+		}
 	}
 
-	// throttle pace of join events
-	limiter := rate.NewLimiter(rate.Limit(params.NumPerSecond), 1)
-	for i := 0; i < maxPublishers+params.Subscribers; i++ {
+	t.lock.Lock()
+	t.ramp = newRampTracker()
+	t.lock.Unlock()
+	// pace join events
+	pacer := newArrivalPacer(params.Arrival, params.NumPerSecond)
+	for _, i := range sequences {
+		if err := pacer.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		room := plan.rooms[i]
 		testerParams := params.TesterParams
 		testerParams.Sequence = i
-		testerParams.expectedTracks = expectedTracks
+		testerParams.Room = plan.names[room]
+		testerParams.Layout = plan.layout(params, room)
+		testerParams.expectedTracks = plan.tracks[room]
+		testerParams.Impairment = impairments[i]
 		isVideoPublisher := i < params.VideoPublishers
 		isAudioPublisher := i < params.AudioPublishers
-		if isVideoPublisher || isAudioPublisher {
+		isDataPublisher := i < params.Data.Publishers
+		if isDataPublisher {
+			testerParams.Data = &params.Data
+		}
+		if isVideoPublisher || isAudioPublisher || isDataPublisher {
 			// publishers would not get their own tracks
 			testerParams.expectedTracks = 0
 			testerParams.IdentityPrefix += "_pub"
@@ -274,61 +502,34 @@ func (t *LoadTest) run(ctx context.Context, params Params) (map[string]*testerSt
 
 		tester := NewLoadTester(testerParams)
 		testers = append(testers, tester)
-		if isVideoPublisher || isAudioPublisher {
-			publishers = append(publishers, tester)
+		t.lock.Lock()
+		t.testers = testers
+		t.lock.Unlock()
+		if isVideoPublisher || isAudioPublisher || isDataPublisher {
+			publishers[room] = append(publishers[room], tester)
+		} else {
+			subscribers[room] = append(subscribers[room], tester)
 		}
 
 		group.Go(func() error {
-			if err := tester.Start(); err != nil {
-				fmt.Println(errors.Wrapf(err, "could not connect %s", testerParams.name))
+			if err := t.startTester(tester, params, isAudioPublisher, isVideoPublisher); err != nil {
 				errs.Store(testerParams.name, err)
-				return nil
-			}
-
-			if isAudioPublisher {
-				audio, err := tester.PublishAudioTrack("audio")
-				if err != nil {
-					errs.Store(testerParams.name, err)
-					return nil
-				}
-				t.lock.Lock()
-				t.trackNames[audio] = fmt.Sprintf("%dA", testerParams.Sequence)
-				t.lock.Unlock()
-			}
-			if isVideoPublisher {
-				var video string
-				var err error
-				if params.Simulcast {
-					video, err = tester.PublishSimulcastTrack("video-simulcast", params.VideoResolution, params.VideoCodec)
-				} else {
-					video, err = tester.PublishVideoTrack("video", params.VideoResolution, params.VideoCodec)
-				}
-				if err != nil {
-					errs.Store(testerParams.name, err)
-					return nil
-				}
-				t.lock.Lock()
-				t.trackNames[video] = fmt.Sprintf("%dV", testerParams.Sequence)
-				t.lock.Unlock()
 			}
 			return nil
 		})
-
-    if err := ctx.Err(); err != nil {
-      return nil, err
-    }
-    
-    if err := limiter.Wait(ctx); err != nil {
-      return nil, err
-    }
 	}
 
-	var speakerSim *SpeakerSimulator
-	if len(publishers) > 0 && t.Params.SimulateSpeakers {
-		speakerSim = NewSpeakerSimulator(SpeakerSimulatorParams{
-			Testers: publishers,
-		})
-		speakerSim.Start()
+	// speakers are simulated independently in each room
+	var speakerSims []*SpeakerSimulator
+	if params.SimulateSpeakers {
+		for room, roomPublishers := range publishers {
+			speakerSim := NewSpeakerSimulator(SpeakerSimulatorParams{
+				Testers:     roomPublishers,
+				Subscribers: subscribers[room],
+			})
+			speakerSim.Start()
+			speakerSims = append(speakerSims, speakerSim)
+		}
 	}
 	if err := group.Wait(); err != nil {
 		return nil, err
@@ -339,7 +540,15 @@ func (t *LoadTest) run(ctx context.Context, params Params) (map[string]*testerSt
 		// a really long time
 		duration = 1000 * time.Hour
 	}
-	fmt.Printf("Finished connecting to room, waiting %s\n", duration.String())
+	fmt.Fprintf(t.Params.output(), "Finished connecting to room, waiting %s\n", duration.String())
+
+	t.lock.Lock()
+	t.churnCycles = nil
+	t.lock.Unlock()
+	var churn *churner
+	if params.Churn.enabled() && len(testers) > 0 {
+		churn = t.startChurn(ctx, params.Churn, testers)
+	}
 
 	select {
 	case <-ctx.Done():
@@ -348,7 +557,14 @@ func (t *LoadTest) run(ctx context.Context, params Params) (map[string]*testerSt
 		// finished
 	}
 
-	if speakerSim != nil {
+	if churn != nil {
+		churn.stop()
+		cycles := churn.toReport()
+		t.lock.Lock()
+		t.churnCycles = cycles
+		t.lock.Unlock()
+	}
+	for _, speakerSim := range speakerSims {
 		speakerSim.Stop()
 	}
 
@@ -363,3 +579,57 @@ func (t *LoadTest) run(ctx context.Context, params Params) (map[string]*testerSt
 
 	return stats, nil
 }
+
+// startTester connects the tester and publishes the requested tracks
+func (t *LoadTest) startTester(tester *LoadTester, params *Params, audio, video bool) error {
+	t.lock.Lock()
+	ramp := t.ramp
+	t.lock.Unlock()
+	if ramp != nil {
+		ramp.joinStarted()
+	}
+	err := tester.Start()
+	if ramp != nil {
+		ramp.joinEnded(err)
+	}
+	if err != nil {
+		fmt.Fprintln(t.Params.output(), errors.Wrapf(err, "could not connect %s", tester.params.name))
+		return err
+	}
+
+	if audio {
+		var sid string
+		var err error
+		if params.MeasureLatency {
+			sid, err = tester.PublishTimestampedTrack(lksdk.TrackKindAudio, timestampedAudioBitrate)
+		} else {
+			sid, err = tester.PublishAudioTrack("audio")
+		}
+		if err != nil {
+			return err
+		}
+		t.lock.Lock()
+		t.trackNames[sid] = fmt.Sprintf("%dA", tester.params.Sequence)
+		t.lock.Unlock()
+	}
+	if video {
+		var sid string
+		var err error
+		if params.MeasureLatency {
+			sid, err = tester.PublishTimestampedTrack(lksdk.TrackKindVideo, timestampedVideoBitrate(params.VideoResolution))
+		} else if params.Simulcast {
+			sid, err = tester.PublishSimulcastTrack("video-simulcast", params.VideoResolution, params.VideoCodec)
+		} else {
+			sid, err = tester.PublishVideoTrack("video", params.VideoResolution, params.VideoCodec)
+		}
+		if err != nil {
+			return err
+		}
+		t.lock.Lock()
+		t.trackNames[sid] = fmt.Sprintf("%dV", tester.params.Sequence)
+		t.lock.Unlock()
+	}
+	tester.PublishData()
+	tester.SwitchLayouts()
+	return nil
+}
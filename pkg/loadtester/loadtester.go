@@ -16,11 +16,12 @@ package loadtester
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"sync"
 	"time"
 
 	"github.com/pion/rtp"
-	"github.com/pion/rtp/codecs"
 	"github.com/pion/webrtc/v3"
 	"go.uber.org/atomic"
 
@@ -35,12 +36,40 @@ type LoadTester struct {
 
 	subscribedParticipants map[string]*lksdk.RemoteParticipant
 	lock                   sync.Mutex
-	room                   *lksdk.Room
-	running                atomic.Bool
+	// replaced when rejoining, guarded by lock
+	room    *lksdk.Room
+	running atomic.Bool
+	// held for writing while rejoining, pausing background work that uses the room
+	rejoinLock sync.RWMutex
 	// participant ID => quality
 	trackQualities map[string]livekit.VideoQuality
+	// participant ID => subscribed video track
+	videoPubs map[string]*lksdk.RemoteTrackPublication
+	// participant ID in focus after the latest layout switch
+	focused string
+
+	stats                *sync.Map
+	subscriptionFailures atomic.Int64
+	reconnects           atomic.Int64
+	// round trip time to the server, in ms
+	rtt atomic.Uint32
+	// SSRC => track ID, for counting RTCP feedback
+	ssrcTracks sync.Map
+	// track SID => published track
+	published map[string]*publishedTrack
+	times     connectTimes
+	data      *dataTracker
+	speakers  *speakerObserver
+	// closed when the tester is stopped, ending background work such as publishing data
+	done           chan struct{}
+	publishingData atomic.Bool
+	switching      atomic.Bool
+}
 
-	stats *sync.Map
+// publishedTrack is a track published by the tester, along with the function that published it
+type publishedTrack struct {
+	pub     *lksdk.LocalTrackPublication
+	publish func() (*lksdk.LocalTrackPublication, error)
 }
 
 type Layout string
@@ -61,6 +90,9 @@ const (
 	mediumHeight = 360
 	lowWidth     = 320
 	lowHeight    = 180
+
+	// tracks published with this name carry LoadTestProvider samples
+	timestampedTrackName = "timestamped"
 )
 
 func LayoutFromString(str string) Layout {
@@ -83,18 +115,37 @@ type TesterParams struct {
 	Layout         Layout
 	// true to subscribe to all published tracks
 	Subscribe bool
+	// network conditions to simulate, none when nil
+	Impairment *Impairment
+	// data messages to publish, none when nil
+	Data *DataParams
+	// average time between layout switches of subscribers, 0 to keep the layout
+	LayoutSwitchInterval time.Duration
+	// where progress and results are printed, os.Stdout when nil
+	Output io.Writer
 
 	name           string
 	Sequence       int
 	expectedTracks int
 }
 
+func (p *TesterParams) output() io.Writer {
+	if p.Output == nil {
+		return os.Stdout
+	}
+	return p.Output
+}
+
 func NewLoadTester(params TesterParams) *LoadTester {
 	return &LoadTester{
 		params:                 params,
 		stats:                  &sync.Map{},
 		trackQualities:         make(map[string]livekit.VideoQuality),
+		videoPubs:              make(map[string]*lksdk.RemoteTrackPublication),
 		subscribedParticipants: make(map[string]*lksdk.RemoteParticipant),
+		published:              make(map[string]*publishedTrack),
+		data:                   newDataTracker(),
+		speakers:               newSpeakerObserver(),
 	}
 }
 
@@ -103,25 +154,57 @@ func (t *LoadTester) Start() error {
 		return nil
 	}
 
-	identity := fmt.Sprintf("%s_%d", t.params.IdentityPrefix, t.params.Sequence)
-	t.room = lksdk.NewRoom(&lksdk.RoomCallback{
+	// subscriptions are made again when rejoining
+	t.lock.Lock()
+	t.subscribedParticipants = make(map[string]*lksdk.RemoteParticipant)
+	t.trackQualities = make(map[string]livekit.VideoQuality)
+	t.videoPubs = make(map[string]*lksdk.RemoteTrackPublication)
+	t.focused = ""
+	t.lock.Unlock()
+
+	identity := t.identity()
+	var onDataPacket func(lksdk.DataPacket, lksdk.DataReceiveParams)
+	var onActiveSpeakersChanged func([]lksdk.Participant)
+	if t.params.Subscribe {
+		onDataPacket = t.onDataPacket
+		onActiveSpeakersChanged = t.speakers.onActiveSpeakersChanged
+	}
+	room := lksdk.NewRoom(&lksdk.RoomCallback{
 		ParticipantCallback: lksdk.ParticipantCallback{
+			OnDataPacket:      onDataPacket,
 			OnTrackSubscribed: t.onTrackSubscribed,
 			OnTrackSubscriptionFailed: func(sid string, rp *lksdk.RemoteParticipant) {
-				fmt.Printf("track subscription failed, lp:%v, sid:%v, rp:%v/%v\n", identity, sid, rp.Identity(), rp.SID())
+				t.subscriptionFailures.Inc()
+				fmt.Fprintf(t.params.output(), "track subscription failed, lp:%v, sid:%v, rp:%v/%v\n", identity, sid, rp.Identity(), rp.SID())
 			},
 			OnTrackPublished: t.onTrackPublished,
+			OnTrackUnsubscribed: func(_ *webrtc.TrackRemote, pub *lksdk.RemoteTrackPublication, rp *lksdk.RemoteParticipant) {
+				t.forgetVideoTrack(pub, rp)
+			},
+			OnTrackUnpublished: t.forgetVideoTrack,
+		},
+		OnParticipantDisconnected: t.onParticipantDisconnected,
+		OnActiveSpeakersChanged:   onActiveSpeakersChanged,
+		OnReconnected: func() {
+			t.reconnects.Inc()
 		},
 	})
-	var err error
+	t.lock.Lock()
+	t.room = room
+	t.lock.Unlock()
+	interceptors, err := t.interceptors()
+	if err != nil {
+		return err
+	}
 	// make up to 10 reconnect attempts
 	for i := 0; i < 10; i++ {
-		err = t.room.Join(t.params.URL, lksdk.ConnectInfo{
+		t.times.startAttempt(i)
+		err = room.Join(t.params.URL, lksdk.ConnectInfo{
 			APIKey:              t.params.APIKey,
 			APISecret:           t.params.APISecret,
 			RoomName:            t.params.Room,
 			ParticipantIdentity: identity,
-		}, lksdk.WithAutoSubscribe(false))
+		}, lksdk.WithAutoSubscribe(false), lksdk.WithInterceptors(interceptors))
 		if err == nil {
 			break
 		}
@@ -130,9 +213,10 @@ func (t *LoadTester) Start() error {
 	if err != nil {
 		return err
 	}
+	t.times.joined()
 
 	t.running.Store(true)
-	for _, p := range t.room.GetRemoteParticipants() {
+	for _, p := range room.GetRemoteParticipants() {
 		for _, pub := range p.TrackPublications() {
 			if remotePub, ok := pub.(*lksdk.RemoteTrackPublication); ok {
 				t.onTrackPublished(remotePub, p)
@@ -147,31 +231,50 @@ func (t *LoadTester) IsRunning() bool {
 	return t.running.Load()
 }
 
+func (t *LoadTester) identity() string {
+	return fmt.Sprintf("%s_%d", t.params.IdentityPrefix, t.params.Sequence)
+}
+
+func (t *LoadTester) getRoom() *lksdk.Room {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.room
+}
+
+// withRoom runs f with the room of a connected tester, waiting for a rejoin to finish. Returns false when not connected
+func (t *LoadTester) withRoom(f func(room *lksdk.Room)) bool {
+	t.rejoinLock.RLock()
+	defer t.rejoinLock.RUnlock()
+	if !t.IsRunning() {
+		return false
+	}
+	f(t.getRoom())
+	return true
+}
+
 func (t *LoadTester) PublishAudioTrack(name string) (string, error) {
 	if !t.IsRunning() {
 		return "", nil
 	}
 
-	fmt.Println("publishing audio track -", t.room.LocalParticipant.Identity())
-	audioLooper, err := provider2.CreateAudioLooper()
-	if err != nil {
-		return "", err
-	}
-	track, err := lksdk.NewLocalTrack(audioLooper.Codec())
-	if err != nil {
-		return "", err
-	}
-	if err := track.StartWrite(audioLooper, nil); err != nil {
-		return "", err
-	}
+	fmt.Fprintln(t.params.output(), "publishing audio track -", t.identity())
+	return t.publish(func() (*lksdk.LocalTrackPublication, error) {
+		audioLooper, err := provider2.CreateAudioLooper()
+		if err != nil {
+			return nil, err
+		}
+		track, err := lksdk.NewLocalTrack(audioLooper.Codec())
+		if err != nil {
+			return nil, err
+		}
+		if err := track.StartWrite(audioLooper, nil); err != nil {
+			return nil, err
+		}
 
-	p, err := t.room.LocalParticipant.PublishTrack(track, &lksdk.TrackPublicationOptions{
-		Name: name,
+		return t.getRoom().LocalParticipant.PublishTrack(track, &lksdk.TrackPublicationOptions{
+			Name: name,
+		})
 	})
-	if err != nil {
-		return "", err
-	}
-	return p.SID(), nil
 }
 
 func (t *LoadTester) PublishVideoTrack(name, resolution, codec string) (string, error) {
@@ -179,66 +282,109 @@ func (t *LoadTester) PublishVideoTrack(name, resolution, codec string) (string,
 		return "", nil
 	}
 
-	fmt.Println("publishing video track -", t.room.LocalParticipant.Identity())
-	loopers, err := provider2.CreateVideoLoopers(resolution, codec, false)
-	if err != nil {
-		return "", err
-	}
-	track, err := lksdk.NewLocalTrack(loopers[0].Codec())
-	if err != nil {
-		return "", err
-	}
-	if err := track.StartWrite(loopers[0], nil); err != nil {
-		return "", err
-	}
+	fmt.Fprintln(t.params.output(), "publishing video track -", t.identity())
+	return t.publish(func() (*lksdk.LocalTrackPublication, error) {
+		loopers, err := provider2.CreateVideoLoopers(resolution, codec, false)
+		if err != nil {
+			return nil, err
+		}
+		track, err := lksdk.NewLocalTrack(loopers[0].Codec())
+		if err != nil {
+			return nil, err
+		}
+		if err := track.StartWrite(loopers[0], nil); err != nil {
+			return nil, err
+		}
 
-	p, err := t.room.LocalParticipant.PublishTrack(track, &lksdk.TrackPublicationOptions{
-		Name: name,
+		return t.getRoom().LocalParticipant.PublishTrack(track, &lksdk.TrackPublicationOptions{
+			Name: name,
+		})
 	})
-	if err != nil {
-		return "", err
-	}
-	return p.SID(), nil
 }
 
 func (t *LoadTester) PublishSimulcastTrack(name, resolution, codec string) (string, error) {
-	var tracks []*lksdk.LocalTrack
+	fmt.Fprintln(t.params.output(), "publishing simulcast video track -", t.identity())
+	return t.publish(func() (*lksdk.LocalTrackPublication, error) {
+		var tracks []*lksdk.LocalTrack
+		loopers, err := provider2.CreateVideoLoopers(resolution, codec, true)
+		if err != nil {
+			return nil, err
+		}
+		// for video, publish three simulcast layers
+		for i, looper := range loopers {
+			layer := looper.ToLayer(livekit.VideoQuality(i))
+
+			track, err := lksdk.NewLocalTrack(looper.Codec(),
+				lksdk.WithSimulcast("loadtest-video", layer))
+			if err != nil {
+				return nil, err
+			}
+			if err := track.StartWrite(looper, nil); err != nil {
+				return nil, err
+			}
+			tracks = append(tracks, track)
+		}
 
-	fmt.Println("publishing simulcast video track -", t.room.LocalParticipant.Identity())
-	loopers, err := provider2.CreateVideoLoopers(resolution, codec, true)
-	if err != nil {
-		return "", err
+		return t.getRoom().LocalParticipant.PublishSimulcastTrack(tracks, &lksdk.TrackPublicationOptions{
+			Name:   name,
+			Source: livekit.TrackSource_CAMERA,
+		})
+	})
+}
+
+// PublishTimestampedTrack publishes a synthetic track with send times embedded in every sample,
+// allowing subscribers to measure end-to-end latency
+func (t *LoadTester) PublishTimestampedTrack(kind lksdk.TrackKind, bitrate uint32) (string, error) {
+	if !t.IsRunning() {
+		return "", nil
 	}
-	// for video, publish three simulcast layers
-	for i, looper := range loopers {
-		layer := looper.ToLayer(livekit.VideoQuality(i))
 
-		track, err := lksdk.NewLocalTrack(looper.Codec(),
-			lksdk.WithSimulcast("loadtest-video", layer))
+	fmt.Fprintln(t.params.output(), "publishing timestamped", kind, "track -", t.identity())
+	return t.publish(func() (*lksdk.LocalTrackPublication, error) {
+		sampleProvider, err := NewLoadTestProvider(bitrate)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		if err := track.StartWrite(looper, nil); err != nil {
-			return "", err
+		track, err := lksdk.NewLocalTrack(sampleProvider.Codec(kind))
+		if err != nil {
+			return nil, err
+		}
+		if err := track.StartWrite(sampleProvider, nil); err != nil {
+			return nil, err
 		}
-		tracks = append(tracks, track)
-	}
 
-	p, err := t.room.LocalParticipant.PublishSimulcastTrack(tracks, &lksdk.TrackPublicationOptions{
-		Name:   name,
-		Source: livekit.TrackSource_CAMERA,
+		return t.getRoom().LocalParticipant.PublishTrack(track, &lksdk.TrackPublicationOptions{
+			Name: timestampedTrackName,
+		})
 	})
+}
+
+// publish runs a publish function and keeps it, so that the track can be published again after churn
+func (t *LoadTester) publish(publish func() (*lksdk.LocalTrackPublication, error)) (string, error) {
+	p, err := publish()
 	if err != nil {
 		return "", err
 	}
-
+	t.lock.Lock()
+	t.published[p.SID()] = &publishedTrack{pub: p, publish: publish}
+	t.lock.Unlock()
 	return p.SID(), nil
 }
 
 func (t *LoadTester) getStats() *testerStats {
 	stats := &testerStats{
-		expectedTracks: t.params.expectedTracks,
-		trackStats:     make(map[string]*trackStats),
+		room:                 t.params.Room,
+		impairment:           t.impairmentName(),
+		expectedTracks:       t.params.expectedTracks,
+		trackStats:           make(map[string]*trackStats),
+		subscriptionFailures: t.subscriptionFailures.Load(),
+		reconnects:           t.reconnects.Load(),
+		rtt:                  t.rtt.Load(),
+		setup:                t.times.timing(),
+		data:                 t.data.stats(),
+	}
+	if t.params.Subscribe {
+		stats.speakers = t.speakers.getStats()
 	}
 	t.stats.Range(func(key, value interface{}) bool {
 		stats.trackStats[key.(string)] = value.(*trackStats)
@@ -247,24 +393,97 @@ func (t *LoadTester) getStats() *testerStats {
 	return stats
 }
 
+// isActiveSpeaker returns true if the subscriber sees the participant as speaking
+func (t *LoadTester) isActiveSpeaker(identity string) bool {
+	active := false
+	t.withRoom(func(room *lksdk.Room) {
+		for _, p := range room.ActiveSpeakers() {
+			if p.Identity() == identity {
+				active = true
+				return
+			}
+		}
+	})
+	return active
+}
+
+func (t *LoadTester) impairmentName() string {
+	if t.params.Impairment == nil {
+		return ""
+	}
+	return t.params.Impairment.Name
+}
+
+// onFeedback records NACKs and PLIs sent for a subscribed track
+func (t *LoadTester) onFeedback(mediaSSRC uint32, nacks, plis int64) {
+	trackID, ok := t.ssrcTracks.Load(mediaSSRC)
+	if !ok {
+		return
+	}
+	value, ok := t.stats.Load(trackID)
+	if !ok {
+		return
+	}
+	ts := value.(*trackStats)
+	ts.nacks.Add(nacks)
+	ts.plis.Add(plis)
+}
+
 func (t *LoadTester) Reset() {
 	stats := sync.Map{}
 	t.stats.Range(func(key, value interface{}) bool {
 		old := value.(*trackStats)
 		stats.Store(key, &trackStats{
 			trackID: old.trackID,
+			kind:    old.kind,
+			codec:   old.codec,
 		})
 		return true
 	})
 	t.stats = &stats
+	t.data = newDataTracker()
 }
 
 func (t *LoadTester) Stop() {
+	t.lock.Lock()
+	if t.done != nil {
+		close(t.done)
+		t.done = nil
+	}
+	t.lock.Unlock()
+	t.publishingData.Store(false)
+	t.switching.Store(false)
+	t.disconnect()
+}
+
+// stopped returns a channel that is closed when the tester is stopped
+func (t *LoadTester) stopped() chan struct{} {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.done == nil {
+		t.done = make(chan struct{})
+	}
+	return t.done
+}
+
+// SwitchLayouts makes a subscriber change its layout over time, until the tester is stopped
+func (t *LoadTester) SwitchLayouts() {
+	if !t.params.Subscribe || t.params.LayoutSwitchInterval <= 0 || !t.IsRunning() {
+		return
+	}
+	if !t.switching.CompareAndSwap(false, true) {
+		return
+	}
+	go t.switchLayouts(t.params.LayoutSwitchInterval, t.stopped())
+}
+
+// disconnect leaves the room, without stopping background work
+func (t *LoadTester) disconnect() {
 	if !t.IsRunning() {
 		return
 	}
 	t.running.Store(false)
-	t.room.Disconnect()
+	t.getRoom().Disconnect()
 }
 
 func (t *LoadTester) numToSubscribe() int {
@@ -297,6 +516,32 @@ func (t *LoadTester) onTrackPublished(publication *lksdk.RemoteTrackPublication,
 	publication.SetSubscribed(true)
 }
 
+// forgetVideoTrack stops switching layouts to a video track that is gone
+func (t *LoadTester) forgetVideoTrack(pub *lksdk.RemoteTrackPublication, rp *lksdk.RemoteParticipant) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	// the participant may have published a newer track already
+	if t.videoPubs[rp.SID()] != pub {
+		return
+	}
+	t.forgetParticipant(rp.SID())
+}
+
+func (t *LoadTester) onParticipantDisconnected(rp *lksdk.RemoteParticipant) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.forgetParticipant(rp.SID())
+}
+
+// forgetParticipant removes the video track and layout state of a participant, t.lock must be held
+func (t *LoadTester) forgetParticipant(sid string) {
+	delete(t.videoPubs, sid)
+	delete(t.trackQualities, sid)
+	if t.focused == sid {
+		t.focused = ""
+	}
+}
+
 func (t *LoadTester) onTrackSubscribed(track *webrtc.TrackRemote, pub *lksdk.RemoteTrackPublication, rp *lksdk.RemoteParticipant) {
 	numSubscribed := 0
 	numTotal := 0
@@ -312,12 +557,14 @@ func (t *LoadTester) onTrackSubscribed(track *webrtc.TrackRemote, pub *lksdk.Rem
 	}
 	t.lock.Unlock()
 
-	s := &trackStats{
+	t.times.trackSubscribed()
+	// stats are kept when resubscribing after a rejoin
+	t.stats.LoadOrStore(track.ID(), &trackStats{
 		trackID: track.ID(),
 		kind:    pub.Kind(),
-	}
-	t.stats.Store(track.ID(), s)
-	fmt.Println("subscribed to track", t.room.LocalParticipant.Identity(), pub.SID(), pub.Kind(), fmt.Sprintf("%d/%d", numSubscribed, numTotal))
+		codec:   codecName(track.Codec().MimeType),
+	})
+	fmt.Fprintln(t.params.output(), "subscribed to track", t.identity(), pub.SID(), pub.Kind(), fmt.Sprintf("%d/%d", numSubscribed, numTotal))
 
 	// consume track
 	go t.consumeTrack(track, pub, rp)
@@ -359,19 +606,11 @@ func (t *LoadTester) onTrackSubscribed(track *webrtc.TrackRemote, pub *lksdk.Rem
 		}
 	}
 	t.trackQualities[rp.SID()] = targetQuality
+	t.videoPubs[rp.SID()] = pub
 	t.lock.Unlock()
 
 	// switch quality and/or enable/disable
-	switch targetQuality {
-	case livekit.VideoQuality_HIGH:
-		pub.SetVideoDimensions(highWidth, highHeight)
-	case livekit.VideoQuality_MEDIUM:
-		pub.SetVideoDimensions(mediumWidth, mediumHeight)
-	case livekit.VideoQuality_LOW:
-		pub.SetVideoDimensions(lowWidth, lowHeight)
-	case livekit.VideoQuality_OFF:
-		pub.SetEnabled(false)
-	}
+	setVideoQuality(pub, targetQuality)
 }
 
 func (t *LoadTester) consumeTrack(track *webrtc.TrackRemote, pub *lksdk.RemoteTrackPublication, rp *lksdk.RemoteParticipant) {
@@ -379,44 +618,92 @@ func (t *LoadTester) consumeTrack(track *webrtc.TrackRemote, pub *lksdk.RemoteTr
 
 	defer func() {
 		if e := recover(); e != nil {
-			fmt.Println("caught panic in consumeTrack", e)
+			fmt.Fprintln(t.params.output(), "caught panic in consumeTrack", e)
 		}
 	}()
 
-	var dpkt rtp.Depacketizer
-	isVideo := false
-	if pub.Kind() == lksdk.TrackKindVideo {
-		dpkt = &codecs.H264Packet{}
-		isVideo = true
-	} else {
-		dpkt = &codecs.OpusPacket{}
-	}
-	sb := samplebuilder.New(100, dpkt, track.Codec().ClockRate, samplebuilder.WithPacketDroppedHandler(func() {
-		value, _ := t.stats.Load(track.ID())
-		ts := value.(*trackStats)
-		ts.dropped.Inc()
-		if isVideo {
-			rp.WritePLI(track.SSRC())
-		}
-	}))
+	isVideo := pub.Kind() == lksdk.TrackKindVideo
+	isTimestamped := pub.Name() == timestampedTrackName
+	mimeType := track.Codec().MimeType
 	value, _ := t.stats.Load(track.ID())
 	ts := value.(*trackStats)
-	ts.startedAt.Store(time.Now())
+	t.ssrcTracks.Store(uint32(track.SSRC()), track.ID())
+	jitter := &jitterCalculator{clockRate: float64(track.Codec().ClockRate)}
+	freezes := &freezeDetector{}
+
+	// packets of unsupported codecs are counted without reassembling samples
+	var sb *samplebuilder.SampleBuilder
+	if dpkt := depacketizerForCodec(mimeType); dpkt != nil {
+		sb = samplebuilder.New(100, dpkt, track.Codec().ClockRate, samplebuilder.WithPacketDroppedHandler(func() {
+			value, ok := t.stats.Load(track.ID())
+			if !ok {
+				return
+			}
+			ts := value.(*trackStats)
+			ts.dropped.Inc()
+			if isVideo {
+				rp.WritePLI(track.SSRC())
+			}
+		}))
+	} else {
+		fmt.Fprintln(t.params.output(), "no depacketizer for", mimeType, "track", track.ID())
+	}
+	if ts.startedAt.Load().IsZero() {
+		ts.startedAt.Store(time.Now())
+	}
+	ts.endedAt.Store(time.Time{})
+	received := false
 	for {
 		pkt, _, err := track.ReadRTP()
 		if err != nil {
+			if value, ok := t.stats.Load(track.ID()); ok {
+				value.(*trackStats).endedAt.Store(time.Now())
+			}
 			return
 		}
 		if pkt == nil {
 			continue
 		}
-		sb.Push(pkt)
+		if !received {
+			t.times.packetReceived()
+			received = true
+		}
+		now := time.Now()
+		value, ok := t.stats.Load(track.ID())
+		if !ok {
+			// merged into the track that replaced it, see renameTrack
+			return
+		}
+		value.(*trackStats).jitter.Store(jitter.add(pkt.Timestamp, now))
+		if isVideo {
+			ts := value.(*trackStats)
+			if latency, converged, timedOut := ts.layer.onPacket(pkt, mimeType, now); converged {
+				ts.layerLatency.add(latency)
+			} else if timedOut {
+				ts.layerTimeouts.Inc()
+			}
+		}
+		packets := []*rtp.Packet{pkt}
+		if sb != nil {
+			sb.Push(pkt)
+			packets = sb.PopPackets()
+		}
 
-		for _, pkt := range sb.PopPackets() {
-			value, _ := t.stats.Load(track.ID())
+		for _, pkt := range packets {
 			ts := value.(*trackStats)
 			ts.bytes.Add(int64(len(pkt.Payload)))
 			ts.packets.Inc()
+			if isVideo && pkt.Marker {
+				if gap, frozen := freezes.addFrame(now); frozen {
+					ts.freezes.Inc()
+					ts.freezeDuration.Add(gap)
+				}
+			}
+			if isTimestamped {
+				if sentAt, ok := parseSampleTimestamp(pkt.Payload); ok {
+					ts.latency.add(syncedNow().Sub(sentAt))
+				}
+			}
 		}
 	}
 }
@@ -16,16 +16,21 @@ package loadtester
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"time"
 
+	"github.com/pion/webrtc/v3"
 	"github.com/pion/webrtc/v3/pkg/media"
+
+	lksdk "github.com/livekit/server-sdk-go/v2"
 )
 
 // LoadTestProvider is designed to be used with the load tester.
 // It provides packets that are encoded with Sequence and timing information, in order determine RTT and loss
 type LoadTestProvider struct {
+	lksdk.BaseSampleProvider
 	BytesPerSample uint32
 	SampleDuration time.Duration
 }
@@ -42,7 +47,7 @@ func NewLoadTestProvider(bitrate uint32) (*LoadTestProvider, error) {
 	}, nil
 }
 
-func (p *LoadTestProvider) NextSample() (media.Sample, error) {
+func (p *LoadTestProvider) NextSample(_ context.Context) (media.Sample, error) {
 	// sample format:
 	// 0xfafafa + 0000... + 8 bytes for ts
 	buf := bytes.NewBuffer(nil)
@@ -51,7 +56,7 @@ func (p *LoadTestProvider) NextSample() (media.Sample, error) {
 	})
 	buf.Write(make([]byte, p.BytesPerSample-12))
 	ts := make([]byte, 8)
-	binary.LittleEndian.PutUint64(ts, uint64(time.Now().UnixNano()))
+	binary.LittleEndian.PutUint64(ts, uint64(syncedNow().UnixNano()))
 	buf.Write(ts)
 
 	return media.Sample{
@@ -60,12 +65,24 @@ func (p *LoadTestProvider) NextSample() (media.Sample, error) {
 	}, nil
 }
 
-func (p *LoadTestProvider) OnBind() error {
-	return nil
-}
-
-func (p *LoadTestProvider) OnUnbind() error {
-	return nil
+// Codec returns a codec capability that the SFU will forward without needing to decode the payload.
+// Video samples begin with 0xfa, which VP8 receivers treat as a keyframe.
+func (p *LoadTestProvider) Codec(kind lksdk.TrackKind) webrtc.RTPCodecCapability {
+	if kind == lksdk.TrackKindAudio {
+		return webrtc.RTPCodecCapability{
+			MimeType:  webrtc.MimeTypeOpus,
+			ClockRate: 48000,
+			Channels:  2,
+		}
+	}
+	return webrtc.RTPCodecCapability{
+		MimeType:  webrtc.MimeTypeVP8,
+		ClockRate: 90000,
+		RTCPFeedback: []webrtc.RTCPFeedback{
+			{Type: webrtc.TypeRTCPFBNACK},
+			{Type: webrtc.TypeRTCPFBNACK, Parameter: "pli"},
+		},
+	}
 }
 
 type LoadTestDepacketizer struct {
@@ -88,16 +105,28 @@ func (d *LoadTestDepacketizer) IsPartitionHead(payload []byte) bool {
 }
 
 func (d *LoadTestDepacketizer) IsPartitionTail(marker bool, payload []byte) bool {
+	_, ok := parseSampleTimestamp(payload)
+	return ok
+}
+
+// parseSampleTimestamp returns the time at which a LoadTestProvider sample was created,
+// if payload contains the tail of one. Timestamps are accepted up to a minute either side of now, as the publisher's
+// clock may be ahead of this one even after correcting for the offset measured by distributed tests
+func parseSampleTimestamp(payload []byte) (time.Time, bool) {
 	size := len(payload)
 	if size < 10 {
-		return false
+		return time.Time{}, false
 	}
 
 	// two 0 bytes followed by 8 bytes of ts
 	if payload[size-10] != 0 || payload[size-9] != 0 {
-		return false
+		return time.Time{}, false
 	}
 	// parse timestamp
 	ts := binary.LittleEndian.Uint64(payload[size-8:])
-	return ts > uint64(time.Now().Add(-time.Minute).UnixNano()) && ts < uint64(time.Now().UnixNano())
+	now := syncedNow()
+	if ts <= uint64(now.Add(-time.Minute).UnixNano()) || ts > uint64(now.Add(time.Minute).UnixNano()) {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(ts)), true
 }
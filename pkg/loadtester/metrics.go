@@ -0,0 +1,174 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "livekit_loadtest"
+
+// metricsCollector reads the counters of running testers whenever metrics are scraped
+type metricsCollector struct {
+	test *LoadTest
+
+	packets              *prometheus.Desc
+	bytes                *prometheus.Desc
+	dropped              *prometheus.Desc
+	nacks                *prometheus.Desc
+	plis                 *prometheus.Desc
+	freezes              *prometheus.Desc
+	subscribedTracks     *prometheus.Desc
+	connectedTesters     *prometheus.Desc
+	joinsInFlight        *prometheus.Desc
+	subscriptionFailures *prometheus.Desc
+	reconnects           *prometheus.Desc
+}
+
+type kindCounters struct {
+	tracks  int64
+	packets int64
+	bytes   int64
+	dropped int64
+	nacks   int64
+	plis    int64
+	freezes int64
+}
+
+func newMetricsCollector(test *LoadTest) *metricsCollector {
+	trackLabels := []string{"tester", "kind"}
+	testerLabels := []string{"tester"}
+	return &metricsCollector{
+		test: test,
+		packets: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "packets_total"),
+			"RTP packets received by subscribers", trackLabels, nil),
+		bytes: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "bytes_total"),
+			"payload bytes received by subscribers", trackLabels, nil),
+		dropped: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "dropped_packets_total"),
+			"packets dropped by the subscriber sample builder", trackLabels, nil),
+		nacks: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "nacked_packets_total"),
+			"packets requested again with NACKs by subscribers", trackLabels, nil),
+		plis: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "plis_total"),
+			"picture loss indications sent by subscribers", trackLabels, nil),
+		freezes: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "freezes_total"),
+			"video freezes seen by subscribers", trackLabels, nil),
+		subscribedTracks: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "subscribed_tracks"),
+			"tracks subscribed to by each tester", trackLabels, nil),
+		connectedTesters: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "connected_testers"),
+			"number of testers currently connected", nil, nil),
+		joinsInFlight: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "joins_in_flight"),
+			"number of testers currently joining", nil, nil),
+		subscriptionFailures: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "subscription_failures_total"),
+			"track subscriptions that failed", testerLabels, nil),
+		reconnects: prometheus.NewDesc(prometheus.BuildFQName(metricsNamespace, "", "reconnects_total"),
+			"times a tester has reconnected to the room", testerLabels, nil),
+	}
+}
+
+func (c *metricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.packets
+	ch <- c.bytes
+	ch <- c.dropped
+	ch <- c.nacks
+	ch <- c.plis
+	ch <- c.freezes
+	ch <- c.subscribedTracks
+	ch <- c.connectedTesters
+	ch <- c.joinsInFlight
+	ch <- c.subscriptionFailures
+	ch <- c.reconnects
+}
+
+func (c *metricsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.test.lock.Lock()
+	testers := c.test.testers
+	ramp := c.test.ramp
+	c.test.lock.Unlock()
+
+	connected := 0
+	for _, tester := range testers {
+		name := tester.params.name
+		if tester.IsRunning() {
+			connected++
+		}
+		ch <- prometheus.MustNewConstMetric(c.subscriptionFailures, prometheus.CounterValue,
+			float64(tester.subscriptionFailures.Load()), name)
+		ch <- prometheus.MustNewConstMetric(c.reconnects, prometheus.CounterValue,
+			float64(tester.reconnects.Load()), name)
+
+		byKind := make(map[string]*kindCounters)
+		for _, ts := range tester.getStats().trackStats {
+			kind := string(ts.kind)
+			k := byKind[kind]
+			if k == nil {
+				k = &kindCounters{}
+				byKind[kind] = k
+			}
+			k.tracks++
+			k.packets += ts.packets.Load()
+			k.bytes += ts.bytes.Load()
+			k.dropped += ts.dropped.Load()
+			k.nacks += ts.nacks.Load()
+			k.plis += ts.plis.Load()
+			k.freezes += ts.freezes.Load()
+		}
+		for kind, k := range byKind {
+			ch <- prometheus.MustNewConstMetric(c.packets, prometheus.CounterValue, float64(k.packets), name, kind)
+			ch <- prometheus.MustNewConstMetric(c.bytes, prometheus.CounterValue, float64(k.bytes), name, kind)
+			ch <- prometheus.MustNewConstMetric(c.dropped, prometheus.CounterValue, float64(k.dropped), name, kind)
+			ch <- prometheus.MustNewConstMetric(c.nacks, prometheus.CounterValue, float64(k.nacks), name, kind)
+			ch <- prometheus.MustNewConstMetric(c.plis, prometheus.CounterValue, float64(k.plis), name, kind)
+			ch <- prometheus.MustNewConstMetric(c.freezes, prometheus.CounterValue, float64(k.freezes), name, kind)
+			ch <- prometheus.MustNewConstMetric(c.subscribedTracks, prometheus.GaugeValue, float64(k.tracks), name, kind)
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(c.connectedTesters, prometheus.GaugeValue, float64(connected))
+	if ramp != nil {
+		ch <- prometheus.MustNewConstMetric(c.joinsInFlight, prometheus.GaugeValue, float64(ramp.joinsInFlight()))
+	}
+}
+
+// startMetricsServer serves Prometheus metrics on /metrics until the returned function is called
+func (t *LoadTest) startMetricsServer() (func(), error) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		newMetricsCollector(t),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	srv := &http.Server{Handler: mux}
+
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", t.Params.PrometheusPort))
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(t.Params.output(), "Serving metrics on http://%s/metrics\n", ln.Addr().String())
+	go func() {
+		_ = srv.Serve(ln)
+	}()
+
+	return func() {
+		_ = srv.Close()
+	}, nil
+}
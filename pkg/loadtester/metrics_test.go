@@ -0,0 +1,76 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	lksdk "github.com/livekit/server-sdk-go/v2"
+)
+
+func TestMetricsCollector(t *testing.T) {
+	test := NewLoadTest(Params{})
+	sub := NewLoadTester(TesterParams{name: "Sub 0", Subscribe: true})
+	video := &trackStats{trackID: "TR_video", kind: lksdk.TrackKindVideo}
+	video.packets.Add(100)
+	video.bytes.Add(1000)
+	video.freezes.Inc()
+	sub.stats.Store(video.trackID, video)
+	audio := &trackStats{trackID: "TR_audio", kind: lksdk.TrackKindAudio}
+	audio.packets.Add(50)
+	sub.stats.Store(audio.trackID, audio)
+	sub.reconnects.Inc()
+	pub := NewLoadTester(TesterParams{name: "Pub 0"})
+
+	// as while the test is running, with one tester still joining
+	test.testers = []*LoadTester{pub, sub}
+	test.ramp = newRampTracker()
+	test.ramp.joinStarted()
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(newMetricsCollector(test)))
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	// metric name => label values => value
+	values := make(map[string]map[string]float64)
+	for _, family := range families {
+		values[family.GetName()] = make(map[string]float64)
+		for _, m := range family.GetMetric() {
+			var labels string
+			for _, label := range m.GetLabel() {
+				labels += label.GetName() + "=" + label.GetValue() + ","
+			}
+			value := m.GetGauge().GetValue()
+			if m.GetCounter() != nil {
+				value = m.GetCounter().GetValue()
+			}
+			values[family.GetName()][labels] = value
+		}
+	}
+
+	require.Equal(t, map[string]float64{"kind=audio,tester=Sub 0,": 50, "kind=video,tester=Sub 0,": 100},
+		values["livekit_loadtest_packets_total"])
+	require.Equal(t, map[string]float64{"kind=audio,tester=Sub 0,": 0, "kind=video,tester=Sub 0,": 1000},
+		values["livekit_loadtest_bytes_total"])
+	require.Equal(t, 1.0, values["livekit_loadtest_freezes_total"]["kind=video,tester=Sub 0,"])
+	require.Equal(t, 1.0, values["livekit_loadtest_subscribed_tracks"]["kind=video,tester=Sub 0,"])
+	require.Equal(t, map[string]float64{"tester=Pub 0,": 0, "tester=Sub 0,": 1}, values["livekit_loadtest_reconnects_total"])
+	require.Equal(t, map[string]float64{"": 0}, values["livekit_loadtest_connected_testers"])
+	require.Equal(t, map[string]float64{"": 1}, values["livekit_loadtest_joins_in_flight"])
+}
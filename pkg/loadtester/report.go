@@ -0,0 +1,605 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ReportFormat string
+
+const (
+	ReportFormatJSON ReportFormat = "json"
+	ReportFormatCSV  ReportFormat = "csv"
+)
+
+// ReportFormatFromPath picks a format based on the file extension, defaulting to JSON
+func ReportFormatFromPath(path string) ReportFormat {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return ReportFormatCSV
+	}
+	return ReportFormatJSON
+}
+
+// Report contains the results of one or more load tests in a machine-readable form
+type Report struct {
+	Tests []*TestReport `json:"tests"`
+}
+
+type TestReport struct {
+	StartedAt    time.Time           `json:"started_at"`
+	EndedAt      time.Time           `json:"ended_at"`
+	Params       ReportParams        `json:"params"`
+	Testers      []*TesterReport     `json:"testers"`
+	Total        *SummaryReport      `json:"total"`
+	Codecs       []*CodecReport      `json:"codecs,omitempty"`
+	Rooms        []*RoomReport       `json:"rooms,omitempty"`
+	Impairments  []*ImpairmentReport `json:"impairments,omitempty"`
+	Phases       []*PhaseReport      `json:"phases,omitempty"`
+	Churn        []*ChurnCycleReport `json:"churn,omitempty"`
+	Ramp         *RampReport         `json:"ramp,omitempty"`
+	Setup        []*SetupPhaseReport `json:"setup,omitempty"`
+	SetupRetries int                 `json:"setup_retries"`
+	Data         []*DataReport       `json:"data,omitempty"`
+	Speakers     *SpeakerReport      `json:"speakers,omitempty"`
+	Layers       *LayerSwitchReport  `json:"layer_switches,omitempty"`
+}
+
+// LayerSwitchReport counts the layers requested by layout switches, and how long the received layer took to match
+type LayerSwitchReport struct {
+	Switches  int64 `json:"switches"`
+	Converged int64 `json:"converged"`
+	TimedOut  int64 `json:"timed_out"`
+	// switches made before the previous one converged or timed out. Switches still pending at the end are not counted
+	Replaced         int64 `json:"replaced"`
+	ConvergenceP50Ms int64 `json:"convergence_p50_ms,omitempty"`
+	ConvergenceP95Ms int64 `json:"convergence_p95_ms,omitempty"`
+	ConvergenceP99Ms int64 `json:"convergence_p99_ms,omitempty"`
+}
+
+// SpeakerReport counts simulated speaker changes that subscribers observed, or missed
+type SpeakerReport struct {
+	Updates      int64 `json:"updates"`
+	Expected     int64 `json:"expected"`
+	Observed     int64 `json:"observed"`
+	Missed       int64 `json:"missed"`
+	Unexpected   int64 `json:"unexpected"`
+	LatencyP50Ms int64 `json:"latency_p50_ms,omitempty"`
+	LatencyP95Ms int64 `json:"latency_p95_ms,omitempty"`
+	LatencyP99Ms int64 `json:"latency_p99_ms,omitempty"`
+}
+
+// DataReport counts data messages of one mode. Loss is a ratio of the messages expected from sequence numbers,
+// only messages missing between the first and last one received from each sender are known to be lost
+type DataReport struct {
+	Mode         string  `json:"mode"`
+	Sent         int64   `json:"sent"`
+	Received     int64   `json:"received"`
+	Lost         int64   `json:"lost"`
+	Loss         float64 `json:"loss"`
+	OutOfOrder   int64   `json:"out_of_order"`
+	DelaySamples int64   `json:"delay_samples,omitempty"`
+	DelayP50Ms   int64   `json:"delay_p50_ms,omitempty"`
+	DelayP95Ms   int64   `json:"delay_p95_ms,omitempty"`
+	DelayP99Ms   int64   `json:"delay_p99_ms,omitempty"`
+}
+
+// RampReport follows joins while testers were starting
+type RampReport struct {
+	Joined       int                 `json:"joined"`
+	Failed       int                 `json:"failed"`
+	DurationMs   int64               `json:"duration_ms"`
+	PeakInFlight int                 `json:"peak_in_flight"`
+	PeakAtMs     int64               `json:"peak_at_ms"`
+	Timeline     []*RampSampleReport `json:"timeline"`
+}
+
+// RampSampleReport has the most joins in flight during an interval, and the joins completed by its end
+type RampSampleReport struct {
+	ElapsedMs int64 `json:"elapsed_ms"`
+	InFlight  int   `json:"in_flight"`
+	Joined    int   `json:"joined"`
+}
+
+// SetupPhaseReport holds percentiles of one phase of joining across testers
+type SetupPhaseReport struct {
+	Phase   string `json:"phase"`
+	Testers int    `json:"testers"`
+	P50Ms   int64  `json:"p50_ms"`
+	P95Ms   int64  `json:"p95_ms"`
+	P99Ms   int64  `json:"p99_ms"`
+	MaxMs   int64  `json:"max_ms"`
+}
+
+// SetupReport is how long each phase of joining took for one tester, 0 when it did not happen
+type SetupReport struct {
+	SignalMs      int64 `json:"signal_ms"`
+	IceMs         int64 `json:"ice_ms"`
+	JoinMs        int64 `json:"join_ms"`
+	FirstTrackMs  int64 `json:"first_track_ms"`
+	FirstPacketMs int64 `json:"first_packet_ms"`
+	Retries       int   `json:"retries"`
+}
+
+// CodecReport summarizes the tracks received with one codec
+type CodecReport struct {
+	Codec  string `json:"codec"`
+	Tracks int    `json:"tracks"`
+	StatsReport
+}
+
+// RoomReport summarizes the testers of one room, when a test spans more than one
+type RoomReport struct {
+	Room    string `json:"room"`
+	Testers int    `json:"testers"`
+	*SummaryReport
+}
+
+// ImpairmentReport summarizes the testers with one network impairment, or none
+type ImpairmentReport struct {
+	Profile string `json:"profile"`
+	Testers int    `json:"testers"`
+	*SummaryReport
+}
+
+// PhaseReport describes a phase of a scenario, and the testers connected at its end
+type PhaseReport struct {
+	Name            string `json:"name"`
+	Duration        string `json:"duration"`
+	VideoPublishers int    `json:"video_publishers"`
+	AudioPublishers int    `json:"audio_publishers"`
+	Subscribers     int    `json:"subscribers"`
+	Connected       int    `json:"connected"`
+	Errors          int    `json:"errors"`
+}
+
+// ChurnCycleReport counts the churn events that started during one churn interval
+type ChurnCycleReport struct {
+	Cycle        int     `json:"cycle"`
+	Joins        int     `json:"joins"`
+	JoinFailures int     `json:"join_failures"`
+	SuccessRate  float64 `json:"success_rate"`
+	JoinP50Ms    int64   `json:"join_p50_ms"`
+	JoinP95Ms    int64   `json:"join_p95_ms"`
+	Republishes  int     `json:"republishes"`
+	Mutes        int     `json:"mutes"`
+	Failures     int     `json:"failures"`
+	Reconnects   int64   `json:"reconnects"`
+}
+
+// ReportParams are the test parameters, without credentials
+type ReportParams struct {
+	URL              string                 `json:"url"`
+	Room             string                 `json:"room"`
+	Rooms            int                    `json:"rooms,omitempty"`
+	RoomDistribution RoomDistribution       `json:"room_distribution,omitempty"`
+	VideoPublishers  int                    `json:"video_publishers"`
+	AudioPublishers  int                    `json:"audio_publishers"`
+	Subscribers      int                    `json:"subscribers"`
+	VideoResolution  string                 `json:"video_resolution"`
+	VideoCodec       string                 `json:"video_codec"`
+	Duration         string                 `json:"duration"`
+	NumPerSecond     float64                `json:"num_per_second"`
+	Arrival          ArrivalParams          `json:"arrival"`
+	Simulcast        bool                   `json:"simulcast"`
+	Layout           Layout                 `json:"layout"`
+	MeasureLatency   bool                   `json:"measure_latency"`
+	Impairments      []ImpairmentAssignment `json:"impairments,omitempty"`
+	ChurnFraction    float64                `json:"churn_fraction,omitempty"`
+	ChurnInterval    string                 `json:"churn_interval,omitempty"`
+	Data             *DataParams            `json:"data,omitempty"`
+	LayoutSwitch     string                 `json:"layout_switch,omitempty"`
+}
+
+type TesterReport struct {
+	Name       string             `json:"name"`
+	Room       string             `json:"room,omitempty"`
+	Impairment string             `json:"impairment,omitempty"`
+	Tracks     []*TrackReport     `json:"tracks"`
+	Setup      *SetupReport       `json:"setup"`
+	Data       []*DataReport      `json:"data,omitempty"`
+	Speakers   *SpeakerReport     `json:"speakers,omitempty"`
+	Layers     *LayerSwitchReport `json:"layer_switches,omitempty"`
+	Summary    *SummaryReport     `json:"summary"`
+}
+
+type TrackReport struct {
+	TrackID string `json:"track_id"`
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`
+	Codec   string `json:"codec"`
+	StatsReport
+}
+
+type SummaryReport struct {
+	Tracks               int    `json:"tracks"`
+	ExpectedTracks       int    `json:"expected_tracks"`
+	SubscriptionFailures int64  `json:"subscription_failures"`
+	Reconnects           int64  `json:"reconnects"`
+	RTTMs                int64  `json:"rtt_ms,omitempty"`
+	Errors               int64  `json:"errors"`
+	Error                string `json:"error,omitempty"`
+	StatsReport
+}
+
+// StatsReport holds counters shared by tracks and summaries. Bitrate is in bps, packet loss is a ratio
+type StatsReport struct {
+	Packets        int64   `json:"packets"`
+	Bytes          int64   `json:"bytes"`
+	Dropped        int64   `json:"dropped"`
+	PacketLoss     float64 `json:"packet_loss"`
+	Bitrate        float64 `json:"bitrate"`
+	ElapsedMs      int64   `json:"elapsed_ms"`
+	LatencySamples int64   `json:"latency_samples,omitempty"`
+	LatencyP50Ms   int64   `json:"latency_p50_ms,omitempty"`
+	LatencyP95Ms   int64   `json:"latency_p95_ms,omitempty"`
+	LatencyP99Ms   int64   `json:"latency_p99_ms,omitempty"`
+	JitterMs       float64 `json:"jitter_ms"`
+	Nacks          int64   `json:"nacks"`
+	Plis           int64   `json:"plis"`
+	Freezes        int64   `json:"freezes"`
+	FreezeMs       int64   `json:"freeze_ms"`
+}
+
+func newStatsReport(s *summary) StatsReport {
+	r := StatsReport{
+		Packets:   s.packets,
+		Bytes:     s.bytes,
+		Dropped:   s.dropped,
+		ElapsedMs: s.elapsed.Milliseconds(),
+		JitterMs:  float64(s.jitter()) / float64(time.Millisecond),
+		Nacks:     s.nacks,
+		Plis:      s.plis,
+		Freezes:   s.freezes,
+		FreezeMs:  s.freezeDuration.Milliseconds(),
+	}
+	if s.packets+s.dropped > 0 {
+		r.PacketLoss = float64(s.dropped) / float64(s.packets+s.dropped)
+	}
+	if s.elapsed > 0 {
+		r.Bitrate = float64(s.bytes*8) / s.elapsed.Seconds()
+	}
+	if n := s.latency.samples(); n > 0 {
+		r.LatencySamples = n
+		r.LatencyP50Ms = s.latency.percentile(50).Milliseconds()
+		r.LatencyP95Ms = s.latency.percentile(95).Milliseconds()
+		r.LatencyP99Ms = s.latency.percentile(99).Milliseconds()
+	}
+	return r
+}
+
+func newTrackStatsReport(ts *trackStats) StatsReport {
+	s := &summary{}
+	s.addTrack(ts)
+	return newStatsReport(s)
+}
+
+func newSummaryReport(s *summary) *SummaryReport {
+	r := &SummaryReport{
+		Tracks:               s.tracks,
+		ExpectedTracks:       s.expected,
+		SubscriptionFailures: s.subscriptionFailures,
+		Reconnects:           s.reconnects,
+		Errors:               s.errCount,
+		RTTMs:                s.rtt().Milliseconds(),
+		StatsReport:          newStatsReport(s),
+	}
+	if s.errCount > 0 {
+		r.Error = s.errString
+	}
+	return r
+}
+
+func (t *LoadTest) newTestReport(params Params, startedAt, endedAt time.Time, stats map[string]*testerStats) *TestReport {
+	r := &TestReport{
+		StartedAt: startedAt,
+		EndedAt:   endedAt,
+		Params: ReportParams{
+			URL:             params.URL,
+			Room:            params.Room,
+			Rooms:           params.Rooms,
+			VideoPublishers: params.VideoPublishers,
+			AudioPublishers: params.AudioPublishers,
+			Subscribers:     params.Subscribers,
+			VideoResolution: params.VideoResolution,
+			VideoCodec:      params.VideoCodec,
+			Duration:        params.Duration.String(),
+			NumPerSecond:    params.NumPerSecond,
+			Arrival:         params.Arrival,
+			Simulcast:       params.Simulcast,
+			Layout:          params.Layout,
+			MeasureLatency:  params.MeasureLatency,
+		},
+	}
+	if params.Rooms > 1 {
+		r.Params.RoomDistribution = params.RoomDistribution
+	}
+	if params.LayoutSwitchInterval > 0 {
+		r.Params.LayoutSwitch = params.LayoutSwitchInterval.String()
+	}
+	if params.Data.enabled() {
+		r.Params.Data = &params.Data
+	}
+	if params.Churn.enabled() {
+		r.Params.ChurnFraction = params.Churn.Fraction
+		r.Params.ChurnInterval = params.Churn.Interval.String()
+	}
+
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	r.Churn = t.churnCycles
+	if t.ramp != nil {
+		r.Ramp = t.ramp.toReport()
+	}
+	summaries := make(map[string]*summary)
+	byRoom := make(map[string]map[string]*summary)
+	byImpairment := make(map[string]map[string]*summary)
+	for _, name := range names {
+		testerStats := stats[name]
+		s := getTesterSummary(testerStats)
+		summaries[name] = s
+		if byRoom[testerStats.room] == nil {
+			byRoom[testerStats.room] = make(map[string]*summary)
+		}
+		byRoom[testerStats.room][name] = s
+		impairment := impairmentGroup(testerStats)
+		if byImpairment[impairment] == nil {
+			byImpairment[impairment] = make(map[string]*summary)
+		}
+		byImpairment[impairment][name] = s
+
+		tr := &TesterReport{
+			Name:       name,
+			Impairment: testerStats.impairment,
+			Setup:      testerStats.setup.toReport(),
+			Data:       newDataReports(testerStats.data),
+			Speakers:   newSpeakerReport(testerStats.speakers),
+			Layers:     newLayerSwitchReport(getLayerSwitchSummary(testerStats)),
+			Summary:    newSummaryReport(s),
+		}
+		if params.Rooms > 1 {
+			tr.Room = testerStats.room
+		}
+		for _, ts := range testerStats.trackStats {
+			tr.Tracks = append(tr.Tracks, &TrackReport{
+				TrackID:     ts.trackID,
+				Name:        t.trackNames[ts.trackID],
+				Kind:        string(ts.kind),
+				Codec:       ts.codec,
+				StatsReport: newTrackStatsReport(ts),
+			})
+		}
+		sort.Slice(tr.Tracks, func(i, j int) bool {
+			return tr.Tracks[i].Name < tr.Tracks[j].Name
+		})
+		r.Testers = append(r.Testers, tr)
+	}
+	r.Total = newSummaryReport(getTestSummary(summaries))
+	setup := getSetupSummary(stats)
+	r.Setup = setup.toReport()
+	r.SetupRetries = setup.retries
+
+	allStats := make([]*testerStats, 0, len(stats))
+	for _, name := range names {
+		allStats = append(allStats, stats[name])
+	}
+	r.Data = newDataReports(getDataSummaries(allStats))
+	speakers := &speakerStats{}
+	for _, s := range allStats {
+		if s.speakers != nil {
+			speakers.merge(s.speakers)
+		}
+	}
+	r.Speakers = newSpeakerReport(speakers)
+	layers := &layerSwitchSummary{}
+	for _, s := range allStats {
+		for _, ts := range s.trackStats {
+			layers.addTrack(ts)
+		}
+	}
+	r.Layers = newLayerSwitchReport(layers)
+	for codec, s := range getCodecSummaries(allStats) {
+		r.Codecs = append(r.Codecs, &CodecReport{
+			Codec:       codec,
+			Tracks:      s.tracks,
+			StatsReport: newStatsReport(s),
+		})
+	}
+	sort.Slice(r.Codecs, func(i, j int) bool {
+		return r.Codecs[i].Codec < r.Codecs[j].Codec
+	})
+
+	if len(byRoom) > 1 {
+		for room, roomSummaries := range byRoom {
+			r.Rooms = append(r.Rooms, &RoomReport{
+				Room:          room,
+				Testers:       len(roomSummaries),
+				SummaryReport: newSummaryReport(getTestSummary(roomSummaries)),
+			})
+		}
+		sort.Slice(r.Rooms, func(i, j int) bool {
+			return r.Rooms[i].Room < r.Rooms[j].Room
+		})
+	}
+	if len(params.Impairments) > 0 {
+		for profile, impairmentSummaries := range byImpairment {
+			r.Impairments = append(r.Impairments, &ImpairmentReport{
+				Profile:       profile,
+				Testers:       len(impairmentSummaries),
+				SummaryReport: newSummaryReport(getTestSummary(impairmentSummaries)),
+			})
+		}
+		sort.Slice(r.Impairments, func(i, j int) bool {
+			return r.Impairments[i].Profile < r.Impairments[j].Profile
+		})
+	}
+
+	return r
+}
+
+// WriteFile writes the report to path in the given format
+func (r *Report) WriteFile(path string, format ReportFormat) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch format {
+	case ReportFormatCSV:
+		err = r.writeCSV(f)
+	case ReportFormatJSON:
+		err = r.writeJSON(f)
+	default:
+		err = fmt.Errorf("unsupported report format: %s", format)
+	}
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (r *Report) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+var reportCSVHeader = []string{
+	"started_at", "ended_at", "room", "video_publishers", "audio_publishers", "subscribers",
+	"row", "tester", "track_id", "track_name", "kind", "codec",
+	"tracks", "expected_tracks", "packets", "bytes", "dropped", "packet_loss", "bitrate", "elapsed_ms",
+	"latency_p50_ms", "latency_p95_ms", "latency_p99_ms",
+	"jitter_ms", "nacks", "plis", "freezes", "freeze_ms", "rtt_ms",
+	"subscription_failures", "reconnects", "errors", "error",
+}
+
+func (r *Report) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(reportCSVHeader); err != nil {
+		return err
+	}
+
+	for _, test := range r.Tests {
+		prefix := []string{
+			test.StartedAt.Format(time.RFC3339),
+			test.EndedAt.Format(time.RFC3339),
+			test.Params.Room,
+			strconv.Itoa(test.Params.VideoPublishers),
+			strconv.Itoa(test.Params.AudioPublishers),
+			strconv.Itoa(test.Params.Subscribers),
+		}
+		for _, tester := range test.Testers {
+			prefix := prefix
+			if tester.Room != "" {
+				prefix = withRoom(prefix, tester.Room)
+			}
+			for _, track := range tester.Tracks {
+				row := append(append([]string{}, prefix...), "track", tester.Name, track.TrackID, track.Name, track.Kind, track.Codec, "", "")
+				row = append(row, track.StatsReport.csvFields()...)
+				row = append(row, "", "", "", "", "")
+				if err := cw.Write(row); err != nil {
+					return err
+				}
+			}
+			if err := cw.Write(tester.Summary.csvRow(prefix, "tester", tester.Name)); err != nil {
+				return err
+			}
+		}
+		for _, codec := range test.Codecs {
+			row := append(append([]string{}, prefix...), "codec", "", "", "", "", codec.Codec, strconv.Itoa(codec.Tracks), "")
+			row = append(row, codec.StatsReport.csvFields()...)
+			row = append(row, "", "", "", "", "")
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+		for _, room := range test.Rooms {
+			if err := cw.Write(room.csvRow(withRoom(prefix, room.Room), "room", "")); err != nil {
+				return err
+			}
+		}
+		if err := cw.Write(test.Total.csvRow(prefix, "total", "")); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// withRoom replaces the room column of a CSV row prefix
+func withRoom(prefix []string, room string) []string {
+	p := append([]string{}, prefix...)
+	p[2] = room
+	return p
+}
+
+func (s *SummaryReport) csvRow(prefix []string, rowType, tester string) []string {
+	row := append(append([]string{}, prefix...), rowType, tester, "", "", "", "",
+		strconv.Itoa(s.Tracks), strconv.Itoa(s.ExpectedTracks))
+	row = append(row, s.StatsReport.csvFields()...)
+	rtt := ""
+	if s.RTTMs > 0 {
+		rtt = strconv.FormatInt(s.RTTMs, 10)
+	}
+	return append(row,
+		rtt,
+		strconv.FormatInt(s.SubscriptionFailures, 10),
+		strconv.FormatInt(s.Reconnects, 10),
+		strconv.FormatInt(s.Errors, 10),
+		s.Error,
+	)
+}
+
+func (s *StatsReport) csvFields() []string {
+	formatMs := func(ms int64) string {
+		if s.LatencySamples == 0 {
+			return ""
+		}
+		return strconv.FormatInt(ms, 10)
+	}
+	return []string{
+		strconv.FormatInt(s.Packets, 10),
+		strconv.FormatInt(s.Bytes, 10),
+		strconv.FormatInt(s.Dropped, 10),
+		strconv.FormatFloat(s.PacketLoss, 'f', 5, 64),
+		strconv.FormatFloat(s.Bitrate, 'f', 0, 64),
+		strconv.FormatInt(s.ElapsedMs, 10),
+		formatMs(s.LatencyP50Ms),
+		formatMs(s.LatencyP95Ms),
+		formatMs(s.LatencyP99Ms),
+		strconv.FormatFloat(s.JitterMs, 'f', 2, 64),
+		strconv.FormatInt(s.Nacks, 10),
+		strconv.FormatInt(s.Plis, 10),
+		strconv.FormatInt(s.Freezes, 10),
+		strconv.FormatInt(s.FreezeMs, 10),
+	}
+}
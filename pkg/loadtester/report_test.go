@@ -0,0 +1,66 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	lksdk "github.com/livekit/server-sdk-go/v2"
+)
+
+func TestReportFormatFromPath(t *testing.T) {
+	require.Equal(t, ReportFormatCSV, ReportFormatFromPath("results.CSV"))
+	require.Equal(t, ReportFormatJSON, ReportFormatFromPath("results.json"))
+	require.Equal(t, ReportFormatJSON, ReportFormatFromPath("results"))
+}
+
+func TestReportCSV(t *testing.T) {
+	ts := &trackStats{
+		trackID: "TR_1",
+		kind:    lksdk.TrackKindVideo,
+		codec:   "vp8",
+	}
+	ts.startedAt.Store(time.Now().Add(-time.Second))
+	ts.packets.Store(90)
+	ts.bytes.Store(1000)
+	ts.dropped.Store(10)
+
+	lt := NewLoadTest(Params{})
+	lt.trackNames["TR_1"] = "0V"
+	report := &Report{
+		Tests: []*TestReport{lt.newTestReport(lt.Params, time.Now(), time.Now(), map[string]*testerStats{
+			"Sub 0": {expectedTracks: 1, trackStats: map[string]*trackStats{"TR_1": ts}},
+		})},
+	}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, report.writeCSV(buf))
+	rows, err := csv.NewReader(buf).ReadAll()
+	require.NoError(t, err)
+
+	// header, track, tester, codec and total rows
+	require.Len(t, rows, 5)
+	for _, row := range rows {
+		require.Len(t, row, len(reportCSVHeader))
+	}
+	require.Equal(t, []string{"track", "tester", "codec", "total"}, []string{rows[1][6], rows[2][6], rows[3][6], rows[4][6]})
+	require.Equal(t, "vp8", rows[3][11])
+	require.Equal(t, "0.10000", rows[4][17])
+}
@@ -0,0 +1,186 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"sort"
+	"text/tabwriter"
+)
+
+// RoomDistribution decides how publishers and subscribers are spread across rooms
+type RoomDistribution string
+
+const (
+	// RoomDistributionEven - rooms get the same number of participants, give or take one
+	RoomDistributionEven RoomDistribution = "even"
+	// RoomDistributionRandom - each participant joins a random room
+	RoomDistributionRandom RoomDistribution = "random"
+	// RoomDistributionSkewed - room sizes follow a Zipf distribution, a few large rooms and many small ones
+	RoomDistributionSkewed RoomDistribution = "skewed"
+)
+
+func RoomDistributionFromString(str string) (RoomDistribution, error) {
+	switch d := RoomDistribution(str); d {
+	case "", RoomDistributionEven:
+		return RoomDistributionEven, nil
+	case RoomDistributionRandom, RoomDistributionSkewed:
+		return d, nil
+	default:
+		return "", fmt.Errorf("unknown room distribution: %s", str)
+	}
+}
+
+// roomCounts splits n participants between rooms
+func roomCounts(n, rooms int, dist RoomDistribution, rnd *rand.Rand) []int {
+	counts := make([]int, rooms)
+	switch dist {
+	case RoomDistributionRandom:
+		for i := 0; i < n; i++ {
+			counts[rnd.Intn(rooms)]++
+		}
+
+	case RoomDistributionSkewed:
+		// largest remainder, with room r weighted 1/(r+1)
+		var total float64
+		for r := 0; r < rooms; r++ {
+			total += 1 / float64(r+1)
+		}
+		remainders := make([]float64, rooms)
+		assigned := 0
+		for r := 0; r < rooms; r++ {
+			share := float64(n) / float64(r+1) / total
+			counts[r] = int(share)
+			remainders[r] = share - float64(counts[r])
+			assigned += counts[r]
+		}
+		order := make([]int, rooms)
+		for r := range order {
+			order[r] = r
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			return remainders[order[i]] > remainders[order[j]]
+		})
+		for i := 0; assigned < n; i++ {
+			counts[order[i%rooms]]++
+			assigned++
+		}
+
+	default:
+		for r := 0; r < rooms; r++ {
+			counts[r] = n / rooms
+			if r < n%rooms {
+				counts[r]++
+			}
+		}
+	}
+	return counts
+}
+
+// roomPlan assigns a room to each tester sequence. Publishers come first, same as in LoadTest.run
+type roomPlan struct {
+	names []string
+	// tester sequence => room index
+	rooms []int
+	// room index => number of tracks published in it
+	tracks []int
+}
+
+func newRoomPlan(params *Params) *roomPlan {
+	rooms := max(params.Rooms, 1)
+	maxPublishers := params.numPublishers()
+	plan := &roomPlan{
+		tracks: make([]int, rooms),
+	}
+	if rooms == 1 {
+		plan.names = []string{params.Room}
+	} else {
+		for r := 0; r < rooms; r++ {
+			plan.names = append(plan.names, fmt.Sprintf("%s_%d", params.Room, r))
+		}
+	}
+
+	// seeded by the identity prefix, so that agents of a distributed test agree on the plan
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(params.IdentityPrefix))
+	rnd := rand.New(rand.NewSource(int64(h.Sum64())))
+
+	for _, n := range []int{maxPublishers, params.Subscribers} {
+		for r, count := range roomCounts(n, rooms, params.RoomDistribution, rnd) {
+			for i := 0; i < count; i++ {
+				plan.rooms = append(plan.rooms, r)
+			}
+		}
+	}
+	for i := 0; i < maxPublishers; i++ {
+		r := plan.rooms[i]
+		if i < params.VideoPublishers {
+			plan.tracks[r]++
+		}
+		if i < params.AudioPublishers {
+			plan.tracks[r]++
+		}
+	}
+	return plan
+}
+
+// layout returns the layout used by subscribers of a room
+func (p *roomPlan) layout(params *Params, room int) Layout {
+	if len(params.Layouts) == 0 {
+		return params.Layout
+	}
+	return params.Layouts[room%len(params.Layouts)]
+}
+
+// printRoomResults prints a summary of each room, when testers were spread across more than one
+func printRoomResults(out io.Writer, names []string, summaries map[string]*summary, stats map[string]*testerStats) {
+	printGroupResults(out, "Rooms", "Room", names, summaries, stats, func(s *testerStats) string {
+		return s.room
+	})
+}
+
+// printGroupResults prints a summary of each group of testers, when there is more than one
+func printGroupResults(out io.Writer, title, column string, names []string, summaries map[string]*summary, stats map[string]*testerStats, group func(*testerStats) string) {
+	byGroup := make(map[string]map[string]*summary)
+	for _, name := range names {
+		g := group(stats[name])
+		if byGroup[g] == nil {
+			byGroup[g] = make(map[string]*summary)
+		}
+		byGroup[g][name] = summaries[name]
+	}
+	if len(byGroup) < 2 {
+		return
+	}
+
+	groups := make([]string, 0, len(byGroup))
+	for g := range byGroup {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+
+	w := tabwriter.NewWriter(out, 1, 1, 1, ' ', 0)
+	_, _ = fmt.Fprintf(w, "\n%s\t| %s\t| Subscribers\t| Tracks\t| Bitrate\t| Total Dropped\t| Latency (p50/p95/p99)\t| Errors\n", title, column)
+	for _, g := range groups {
+		s := getTestSummary(byGroup[g])
+		_, _ = fmt.Fprintf(w, "\t| %s\t| %d\t| %d/%d\t| %s\t| %s\t| %s\t| %d\n",
+			g, len(byGroup[g]), s.tracks, s.expected, formatBitrate(s.bytes, s.elapsed),
+			formatStrings(s.packets, s.dropped), formatLatency(&s.latency), s.errCount)
+	}
+	_ = w.Flush()
+}
@@ -0,0 +1,70 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoomCounts(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	require.Equal(t, []int{3, 3, 2, 2}, roomCounts(10, 4, RoomDistributionEven, rnd))
+
+	skewed := roomCounts(100, 5, RoomDistributionSkewed, rnd)
+	require.Equal(t, 100, sum(skewed))
+	for i := 1; i < len(skewed); i++ {
+		require.GreaterOrEqual(t, skewed[i-1], skewed[i])
+	}
+
+	require.Equal(t, 100, sum(roomCounts(100, 7, RoomDistributionRandom, rnd)))
+}
+
+func TestRoomPlan(t *testing.T) {
+	params := &Params{
+		VideoPublishers: 4,
+		AudioPublishers: 2,
+		Subscribers:     6,
+		Rooms:           2,
+		Layouts:         []Layout{LayoutSpeaker, LayoutGrid3x3},
+		TesterParams: TesterParams{
+			Room:           "room",
+			IdentityPrefix: "abc",
+		},
+	}
+	plan := newRoomPlan(params)
+	require.Equal(t, []string{"room_0", "room_1"}, plan.names)
+	require.Equal(t, []int{0, 0, 1, 1, 0, 0, 0, 1, 1, 1}, plan.rooms)
+	// publishers 0 and 1 publish both video and audio
+	require.Equal(t, []int{4, 2}, plan.tracks)
+	require.Equal(t, LayoutGrid3x3, plan.layout(params, 1))
+
+	// agents of a distributed test come up with the same plan
+	params.RoomDistribution = RoomDistributionRandom
+	require.Equal(t, newRoomPlan(params).rooms, newRoomPlan(params).rooms)
+
+	params.Rooms = 0
+	require.Equal(t, []string{"room"}, newRoomPlan(params).names)
+}
+
+func sum(counts []int) int {
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	return total
+}
@@ -0,0 +1,351 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario describes a load test as a sequence of phases. Testers are kept connected between phases,
+// each phase starts or stops testers to reach its target counts, then holds until its duration has elapsed.
+type Scenario struct {
+	Room   string           `yaml:"room"`
+	Phases []*ScenarioPhase `yaml:"phases"`
+}
+
+// ScenarioPhase sets target counts and publishing options. Unset values are carried over from the previous phase,
+// publishing options only apply to testers started during the phase.
+type ScenarioPhase struct {
+	Name            string        `yaml:"name"`
+	Duration        time.Duration `yaml:"duration"`
+	VideoPublishers *int          `yaml:"video_publishers"`
+	AudioPublishers *int          `yaml:"audio_publishers"`
+	Subscribers     *int          `yaml:"subscribers"`
+	VideoResolution string        `yaml:"video_resolution"`
+	VideoCodec      string        `yaml:"video_codec"`
+	Layout          string        `yaml:"layout"`
+	Simulcast       *bool         `yaml:"simulcast"`
+	NumPerSecond    float64       `yaml:"num_per_second"`
+}
+
+func LoadScenario(path string) (*Scenario, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseScenario(b)
+}
+
+func ParseScenario(b []byte) (*Scenario, error) {
+	s := &Scenario{}
+	if err := yaml.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	if len(s.Phases) == 0 {
+		return nil, errors.New("scenario has no phases")
+	}
+	for i, phase := range s.Phases {
+		if phase.Name == "" {
+			phase.Name = fmt.Sprintf("phase %d", i+1)
+		}
+		if phase.Duration <= 0 {
+			return nil, fmt.Errorf("%s: duration is required", phase.Name)
+		}
+		for _, n := range []*int{phase.VideoPublishers, phase.AudioPublishers, phase.Subscribers} {
+			if n != nil && *n < 0 {
+				return nil, fmt.Errorf("%s: participant counts cannot be negative", phase.Name)
+			}
+		}
+	}
+	return s, nil
+}
+
+// apply returns the parameters of a phase, inheriting unset values from prev
+func (p *ScenarioPhase) apply(prev Params) Params {
+	params := prev
+	params.Duration = p.Duration
+	if p.VideoPublishers != nil {
+		params.VideoPublishers = *p.VideoPublishers
+	}
+	if p.AudioPublishers != nil {
+		params.AudioPublishers = *p.AudioPublishers
+	}
+	if p.Subscribers != nil {
+		params.Subscribers = *p.Subscribers
+	}
+	if p.VideoResolution != "" {
+		params.VideoResolution = p.VideoResolution
+	}
+	if p.VideoCodec != "" {
+		params.VideoCodec = p.VideoCodec
+	}
+	if p.Layout != "" {
+		params.Layout = LayoutFromString(p.Layout)
+	}
+	if p.Simulcast != nil {
+		params.Simulcast = *p.Simulcast
+	}
+	if p.NumPerSecond > 0 {
+		params.NumPerSecond = p.NumPerSecond
+	}
+	return params
+}
+
+type phaseResult struct {
+	name            string
+	duration        time.Duration
+	videoPublishers int
+	audioPublishers int
+	subscribers     int
+	connected       int
+	errors          int
+}
+
+// scenarioRunner keeps track of testers across phases
+type scenarioRunner struct {
+	test     *LoadTest
+	sequence int
+	errs     sync.Map
+	stats    map[string]*testerStats
+
+	videoPublishers []*LoadTester
+	audioPublishers []*LoadTester
+	subscribers     []*LoadTester
+}
+
+func (t *LoadTest) RunScenario(ctx context.Context, scenario *Scenario) error {
+	// counts come from phases only, not the defaults used by Run
+	base := t.Params
+	base.VideoPublishers = 0
+	base.AudioPublishers = 0
+	base.Subscribers = 0
+
+	params := base
+	for _, phase := range scenario.Phases {
+		params = phase.apply(params)
+		if err := checkAcceptableUse(&params); err != nil {
+			return err
+		}
+	}
+
+	if t.Params.PrometheusPort != 0 {
+		stop, err := t.startMetricsServer()
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+
+	params = base
+	params.Room = scenario.Room
+	if params.Room == "" {
+		params.Room = fmt.Sprintf("testroom%d", rand.Int31n(1000))
+	}
+	if params.IdentityPrefix == "" {
+		params.IdentityPrefix = randStringRunes(5)
+	}
+	fmt.Fprintf(t.Params.output(), "Starting scenario with %d phases, room: %s\n", len(scenario.Phases), params.Room)
+
+	r := &scenarioRunner{
+		test:  t,
+		stats: make(map[string]*testerStats),
+	}
+	t.lock.Lock()
+	t.ramp = newRampTracker()
+	t.lock.Unlock()
+	startedAt := time.Now()
+	var results []*phaseResult
+	peak := params
+	for _, phase := range scenario.Phases {
+		params = phase.apply(params)
+		peak.VideoPublishers = max(peak.VideoPublishers, params.VideoPublishers)
+		peak.AudioPublishers = max(peak.AudioPublishers, params.AudioPublishers)
+		peak.Subscribers = max(peak.Subscribers, params.Subscribers)
+		result, err := r.runPhase(ctx, phase.Name, &params)
+		if result != nil {
+			results = append(results, result)
+		}
+		if err != nil {
+			break
+		}
+	}
+	r.stopAll()
+
+	if t.Params.writesReport() {
+		// report the peak number of testers over the whole scenario
+		peak.Duration = time.Since(startedAt)
+		report := t.newTestReport(peak, startedAt, time.Now(), r.stats)
+		for _, res := range results {
+			report.Phases = append(report.Phases, res.toReport())
+		}
+		if err := t.writeReport(&Report{Tests: []*TestReport{report}}); err != nil {
+			return err
+		}
+	}
+
+	t.printResults(r.stats)
+	t.printRunResults()
+	printPhaseResults(t.Params.output(), results)
+	return t.checkThresholds(r.stats)
+}
+
+func (r *scenarioRunner) runPhase(ctx context.Context, name string, params *Params) (*phaseResult, error) {
+	fmt.Fprintf(r.test.Params.output(), "Starting %s: %d video publishers, %d audio publishers, %d subscribers for %s\n",
+		name, params.VideoPublishers, params.AudioPublishers, params.Subscribers, params.Duration)
+	phaseEnd := time.Now().Add(params.Duration)
+	result := &phaseResult{
+		name:            name,
+		duration:        params.Duration,
+		videoPublishers: params.VideoPublishers,
+		audioPublishers: params.AudioPublishers,
+		subscribers:     params.Subscribers,
+	}
+
+	// ramp down before ramping up, so that testers do not linger past their phase
+	limiter := rate.NewLimiter(rate.Limit(params.NumPerSecond), 1)
+	var err error
+	r.subscribers, err = r.scaleDown(ctx, r.subscribers, params.Subscribers, limiter)
+	if err == nil {
+		r.videoPublishers, err = r.scaleDown(ctx, r.videoPublishers, params.VideoPublishers, limiter)
+	}
+	if err == nil {
+		r.audioPublishers, err = r.scaleDown(ctx, r.audioPublishers, params.AudioPublishers, limiter)
+	}
+
+	var wg sync.WaitGroup
+	for len(r.videoPublishers) < params.VideoPublishers && err == nil {
+		r.videoPublishers = append(r.videoPublishers, r.start(&wg, params, false, true))
+		err = limiter.Wait(ctx)
+	}
+	for len(r.audioPublishers) < params.AudioPublishers && err == nil {
+		r.audioPublishers = append(r.audioPublishers, r.start(&wg, params, true, false))
+		err = limiter.Wait(ctx)
+	}
+	for len(r.subscribers) < params.Subscribers && err == nil {
+		r.subscribers = append(r.subscribers, r.start(&wg, params, false, false))
+		err = limiter.Wait(ctx)
+	}
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Until(phaseEnd)):
+	}
+
+	for _, testers := range [][]*LoadTester{r.videoPublishers, r.audioPublishers, r.subscribers} {
+		for _, tester := range testers {
+			if tester.IsRunning() {
+				result.connected++
+			}
+			if e, _ := r.errs.Load(tester.params.name); e != nil {
+				result.errors++
+			}
+		}
+	}
+	return result, ctx.Err()
+}
+
+func (r *scenarioRunner) start(wg *sync.WaitGroup, params *Params, audio, video bool) *LoadTester {
+	testerParams := params.TesterParams
+	testerParams.Sequence = r.sequence
+	r.sequence++
+	if audio || video {
+		testerParams.IdentityPrefix += "_pub"
+		testerParams.name = fmt.Sprintf("Pub %d", testerParams.Sequence)
+	} else {
+		testerParams.Subscribe = true
+		testerParams.expectedTracks = params.VideoPublishers + params.AudioPublishers
+		testerParams.name = fmt.Sprintf("Sub %d", testerParams.Sequence)
+	}
+
+	tester := NewLoadTester(testerParams)
+	r.test.lock.Lock()
+	r.test.testers = append(r.test.testers, tester)
+	r.test.lock.Unlock()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := r.test.startTester(tester, params, audio, video); err != nil {
+			r.errs.Store(testerParams.name, err)
+		}
+	}()
+	return tester
+}
+
+// scaleDown stops the most recently started testers until target remain, paced by limiter if set
+func (r *scenarioRunner) scaleDown(ctx context.Context, testers []*LoadTester, target int, limiter *rate.Limiter) ([]*LoadTester, error) {
+	for len(testers) > target {
+		r.stop(testers[len(testers)-1])
+		testers = testers[:len(testers)-1]
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				return testers, err
+			}
+		}
+	}
+	return testers, nil
+}
+
+func (r *scenarioRunner) stop(tester *LoadTester) {
+	tester.Stop()
+	stats := tester.getStats()
+	if e, _ := r.errs.Load(tester.params.name); e != nil {
+		stats.err = e.(error)
+	}
+	r.stats[tester.params.name] = stats
+}
+
+func (r *scenarioRunner) stopAll() {
+	r.subscribers, _ = r.scaleDown(context.Background(), r.subscribers, 0, nil)
+	r.videoPublishers, _ = r.scaleDown(context.Background(), r.videoPublishers, 0, nil)
+	r.audioPublishers, _ = r.scaleDown(context.Background(), r.audioPublishers, 0, nil)
+}
+
+func (res *phaseResult) toReport() *PhaseReport {
+	return &PhaseReport{
+		Name:            res.name,
+		Duration:        res.duration.String(),
+		VideoPublishers: res.videoPublishers,
+		AudioPublishers: res.audioPublishers,
+		Subscribers:     res.subscribers,
+		Connected:       res.connected,
+		Errors:          res.errors,
+	}
+}
+
+func printPhaseResults(out io.Writer, results []*phaseResult) {
+	w := tabwriter.NewWriter(out, 1, 1, 1, ' ', 0)
+	_, _ = fmt.Fprint(w, "\nPhase\t| Duration\t| Video Pubs\t| Audio Pubs\t| Subs\t| Connected\t| Errors\n")
+	for _, res := range results {
+		_, _ = fmt.Fprintf(w, "%s\t| %s\t| %d\t| %d\t| %d\t| %d\t| %d\n",
+			res.name, res.duration, res.videoPublishers, res.audioPublishers, res.subscribers, res.connected, res.errors)
+	}
+	_ = w.Flush()
+}
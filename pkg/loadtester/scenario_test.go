@@ -0,0 +1,71 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScenario(t *testing.T) {
+	scenario, err := ParseScenario([]byte(`
+room: scenario-room
+phases:
+  - name: ramp-up
+    duration: 1m
+    video_publishers: 2
+    subscribers: 10
+    layout: 3x3
+    simulcast: false
+  - duration: 5m
+    audio_publishers: 1
+  - name: ramp-down
+    duration: 30s
+    video_publishers: 0
+    subscribers: 0
+`))
+	require.NoError(t, err)
+	require.Equal(t, "scenario-room", scenario.Room)
+	require.Len(t, scenario.Phases, 3)
+	require.Equal(t, "phase 2", scenario.Phases[1].Name)
+
+	params := Params{Simulcast: true, NumPerSecond: 5}
+	params = scenario.Phases[0].apply(params)
+	require.Equal(t, time.Minute, params.Duration)
+	require.Equal(t, 2, params.VideoPublishers)
+	require.Equal(t, 10, params.Subscribers)
+	require.Equal(t, LayoutGrid3x3, params.Layout)
+	require.False(t, params.Simulcast)
+
+	// unset values carry over
+	params = scenario.Phases[1].apply(params)
+	require.Equal(t, 2, params.VideoPublishers)
+	require.Equal(t, 1, params.AudioPublishers)
+	require.Equal(t, 10, params.Subscribers)
+	require.False(t, params.Simulcast)
+
+	params = scenario.Phases[2].apply(params)
+	require.Equal(t, 0, params.VideoPublishers)
+	require.Equal(t, 1, params.AudioPublishers)
+	require.Equal(t, 0, params.Subscribers)
+	require.Equal(t, 30*time.Second, params.Duration)
+
+	_, err = ParseScenario([]byte("phases:\n  - subscribers: 1\n"))
+	require.Error(t, err)
+	_, err = ParseScenario([]byte("room: empty\n"))
+	require.Error(t, err)
+}
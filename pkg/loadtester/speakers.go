@@ -0,0 +1,178 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	lksdk "github.com/livekit/server-sdk-go/v2"
+)
+
+// simulated speakers that a subscriber has not seen within this time are missed, same as the length of a simulated update
+const speakerUpdateTimeout = lksdk.SimulateSpeakerUpdateInterval * time.Second
+
+// speakerObserver checks the active speaker updates received by a subscriber against simulated speaker changes
+type speakerObserver struct {
+	lock sync.Mutex
+	// identity => time the speaker change was simulated, until it is observed
+	pending map[string]time.Time
+	// identities that were made to speak at some point
+	simulated map[string]bool
+	stats     speakerStats
+}
+
+// speakerStats counts active speaker updates received by a subscriber
+type speakerStats struct {
+	updates int64
+	// simulated speaker changes seen by the subscriber, and those it did not see in time
+	observed int64
+	missed   int64
+	// speakers in updates that were never simulated, counted once per update
+	unexpected int64
+	// time from simulating a speaker change until it was observed
+	latency latencyStats
+}
+
+func newSpeakerObserver() *speakerObserver {
+	return &speakerObserver{
+		pending:   make(map[string]time.Time),
+		simulated: make(map[string]bool),
+	}
+}
+
+// expect records that identity was made to speak at the given time
+func (o *speakerObserver) expect(identity string, at time.Time) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.expire(at)
+	if _, ok := o.pending[identity]; ok {
+		o.stats.missed++
+	}
+	o.pending[identity] = at
+	o.simulated[identity] = true
+}
+
+func (o *speakerObserver) onActiveSpeakersChanged(speakers []lksdk.Participant) {
+	identities := make([]string, 0, len(speakers))
+	for _, p := range speakers {
+		identities = append(identities, p.Identity())
+	}
+	o.observe(identities, time.Now())
+}
+
+// observe records an update of the active speakers
+func (o *speakerObserver) observe(identities []string, now time.Time) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.stats.updates++
+	o.expire(now)
+	for _, identity := range identities {
+		if !o.simulated[identity] {
+			o.stats.unexpected++
+			continue
+		}
+		if at, ok := o.pending[identity]; ok {
+			o.stats.observed++
+			o.stats.latency.add(now.Sub(at))
+			delete(o.pending, identity)
+		}
+	}
+}
+
+// expire counts speaker changes that were not observed in time as missed, o.lock must be held
+func (o *speakerObserver) expire(now time.Time) {
+	for identity, at := range o.pending {
+		if now.Sub(at) > speakerUpdateTimeout {
+			o.stats.missed++
+			delete(o.pending, identity)
+		}
+	}
+}
+
+func (o *speakerObserver) getStats() *speakerStats {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.expire(time.Now())
+	s := &speakerStats{
+		updates:    o.stats.updates,
+		observed:   o.stats.observed,
+		missed:     o.stats.missed,
+		unexpected: o.stats.unexpected,
+	}
+	s.latency.merge(&o.stats.latency)
+	return s
+}
+
+func (s *speakerStats) expected() int64 {
+	return s.observed + s.missed
+}
+
+func (s *speakerStats) merge(other *speakerStats) {
+	s.updates += other.updates
+	s.observed += other.observed
+	s.missed += other.missed
+	s.unexpected += other.unexpected
+	s.latency.merge(&other.latency)
+}
+
+// printSpeakerResults prints how subscribers observed simulated speaker changes, when speakers were simulated
+func printSpeakerResults(out io.Writer, names []string, stats map[string]*testerStats) {
+	total := &speakerStats{}
+	for _, name := range names {
+		if s := stats[name].speakers; s != nil {
+			total.merge(s)
+		}
+	}
+	if total.expected() == 0 {
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 1, 1, 1, ' ', 0)
+	_, _ = fmt.Fprint(w, "\nSpeakers\t| Tester\t| Updates\t| Observed\t| Missed\t| Unexpected\t| Latency (p50/p95/p99)\n")
+	for _, name := range names {
+		s := stats[name].speakers
+		if s == nil || s.expected() == 0 {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "\t| %s\t| %d\t| %d/%d\t| %d\t| %d\t| %s\n",
+			name, s.updates, s.observed, s.expected(), s.missed, s.unexpected, formatLatency(&s.latency))
+	}
+	_, _ = fmt.Fprintf(w, "\t| %s\t| %d\t| %d/%d\t| %d\t| %d\t| %s\n",
+		"Total", total.updates, total.observed, total.expected(), total.missed, total.unexpected, formatLatency(&total.latency))
+	_ = w.Flush()
+}
+
+func newSpeakerReport(s *speakerStats) *SpeakerReport {
+	if s == nil || s.expected() == 0 {
+		return nil
+	}
+	r := &SpeakerReport{
+		Updates:    s.updates,
+		Expected:   s.expected(),
+		Observed:   s.observed,
+		Missed:     s.missed,
+		Unexpected: s.unexpected,
+	}
+	if s.latency.samples() > 0 {
+		r.LatencyP50Ms = s.latency.percentile(50).Milliseconds()
+		r.LatencyP95Ms = s.latency.percentile(95).Milliseconds()
+		r.LatencyP99Ms = s.latency.percentile(99).Milliseconds()
+	}
+	return r
+}
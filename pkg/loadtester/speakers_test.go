@@ -0,0 +1,50 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpeakerObserver(t *testing.T) {
+	o := newSpeakerObserver()
+	start := time.Now()
+
+	o.expect("pub_0", start)
+	o.observe([]string{"pub_1"}, start.Add(100*time.Millisecond))
+	o.observe([]string{"pub_1", "pub_0"}, start.Add(300*time.Millisecond))
+	// already observed
+	o.observe([]string{"pub_0"}, start.Add(400*time.Millisecond))
+
+	// never observed, missed once the update times out
+	o.expect("pub_2", start.Add(time.Second))
+	o.observe(nil, start.Add(time.Second+speakerUpdateTimeout+time.Millisecond))
+
+	s := o.getStats()
+	require.Equal(t, int64(4), s.updates)
+	require.Equal(t, int64(1), s.observed)
+	require.Equal(t, int64(1), s.missed)
+	// pub_1 was never simulated
+	require.Equal(t, int64(2), s.unexpected)
+	require.Equal(t, 300*time.Millisecond, s.latency.percentile(50))
+
+	// a speaker simulated again before being observed is missed
+	o.expect("pub_3", time.Now())
+	o.expect("pub_3", time.Now())
+	require.Equal(t, int64(2), o.getStats().missed)
+}
@@ -25,6 +25,8 @@ import (
 
 type SpeakerSimulatorParams struct {
 	Testers []*LoadTester
+	// subscribers expected to see each simulated speaker become active
+	Subscribers []*LoadTester
 	// amount of time between each speaker
 	Pause uint64
 }
@@ -68,8 +70,26 @@ func (s *SpeakerSimulator) worker() {
 			return
 		case <-t.C:
 			speaker := s.params.Testers[rand.Intn(len(s.params.Testers))]
-			speaker.room.Simulate(lksdk.SimulateSpeakerUpdate)
+			if !speaker.IsRunning() {
+				continue
+			}
+			s.expect(speaker)
+			speaker.withRoom(func(room *lksdk.Room) {
+				room.Simulate(lksdk.SimulateSpeakerUpdate)
+			})
 			t.Reset(time.Duration(s.params.Pause+lksdk.SimulateSpeakerUpdateInterval) * time.Second)
 		}
 	}
 }
+
+// expect tells subscribers that the speaker is about to become active. Speakers that are already active can't be timed
+func (s *SpeakerSimulator) expect(speaker *LoadTester) {
+	identity := speaker.identity()
+	now := time.Now()
+	for _, sub := range s.params.Subscribers {
+		if !sub.IsRunning() || sub.isActiveSpeaker(identity) {
+			continue
+		}
+		sub.speakers.expect(identity, now)
+	}
+}
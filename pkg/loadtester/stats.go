@@ -15,6 +15,9 @@
 package loadtester
 
 import (
+	"maps"
+	"slices"
+	"sync"
 	"time"
 
 	"go.uber.org/atomic"
@@ -23,18 +26,76 @@ import (
 )
 
 type testerStats struct {
-	expectedTracks int
-	trackStats     map[string]*trackStats
-	err            error
+	room string
+	// name of the network impairment, empty when none
+	impairment           string
+	expectedTracks       int
+	trackStats           map[string]*trackStats
+	subscriptionFailures int64
+	reconnects           int64
+	// round trip time to the server, in ms
+	rtt   uint32
+	setup setupTiming
+	// mode => data messages
+	data map[DataMode]*dataStats
+	// active speaker updates seen by subscribers, nil for publishers
+	speakers *speakerStats
+	err      error
 }
 
 type trackStats struct {
+	// SID the stats are stored under, changed by renameTrack while the LoadTest lock is held
 	trackID   string
 	kind      lksdk.TrackKind
+	codec     string
 	startedAt atomic.Time
+	endedAt   atomic.Time
 	packets   atomic.Int64
 	bytes     atomic.Int64
 	dropped   atomic.Int64
+	latency   latencyStats
+
+	// latest interarrival jitter estimate
+	jitter         atomic.Duration
+	nacks          atomic.Int64
+	plis           atomic.Int64
+	freezes        atomic.Int64
+	freezeDuration atomic.Duration
+
+	// layers requested by layout switches, and how long the received layer took to match
+	layer         layerTracker
+	layerSwitches atomic.Int64
+	layerTimeouts atomic.Int64
+	layerReplaced atomic.Int64
+	layerLatency  latencyStats
+}
+
+// elapsed returns how long the track has been consumed for
+func (ts *trackStats) elapsed() time.Duration {
+	if endedAt := ts.endedAt.Load(); !endedAt.IsZero() {
+		return endedAt.Sub(ts.startedAt.Load())
+	}
+	return time.Since(ts.startedAt.Load())
+}
+
+// merge adds the counts of another track, which was replaced by this one
+func (ts *trackStats) merge(other *trackStats) {
+	if startedAt := other.startedAt.Load(); !startedAt.IsZero() &&
+		(ts.startedAt.Load().IsZero() || startedAt.Before(ts.startedAt.Load())) {
+		ts.startedAt.Store(startedAt)
+	}
+	ts.packets.Add(other.packets.Load())
+	ts.bytes.Add(other.bytes.Load())
+	ts.dropped.Add(other.dropped.Load())
+	ts.latency.merge(&other.latency)
+	ts.nacks.Add(other.nacks.Load())
+	ts.plis.Add(other.plis.Load())
+	ts.freezes.Add(other.freezes.Load())
+	ts.freezeDuration.Add(other.freezeDuration.Load())
+	ts.layerSwitches.Add(other.layerSwitches.Load())
+	ts.layerTimeouts.Add(other.layerTimeouts.Load())
+	ts.layerReplaced.Add(other.layerReplaced.Load())
+	ts.layerLatency.merge(&other.layerLatency)
 }
 
 type summary struct {
@@ -46,6 +107,132 @@ type summary struct {
 	elapsed   time.Duration
 	errString string
 	errCount  int64
+	latency   latencyStats
+
+	// sum of the jitter of all tracks, averaged over tracks when printed
+	jitterTotal    time.Duration
+	nacks          int64
+	plis           int64
+	freezes        int64
+	freezeDuration time.Duration
+	// sum of the round trip time of testers that measured it, averaged over rttCount
+	rttTotal time.Duration
+	rttCount int
+
+	subscriptionFailures int64
+	reconnects           int64
+}
+
+// latency is tracked with 1ms resolution, anything above maxLatency is counted in the last bucket
+const maxLatency = 5 * time.Second
+
+// latencyStats is a histogram of end-to-end latency measured from LoadTestProvider samples.
+// Buckets are kept in a map, as most tracks only ever see a small range of latencies
+type latencyStats struct {
+	lock sync.Mutex
+	// sample counts by latency in ms
+	buckets map[int64]int64
+	count   int64
+	// samples that arrived before they were sent, counted as 0. Only possible when clocks are out of sync
+	early int64
+}
+
+func (l *latencyStats) add(latency time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if latency < 0 {
+		latency = 0
+		l.early++
+	}
+	l.addBucket(int64(min(latency, maxLatency)/time.Millisecond), 1)
+	l.count++
+}
+
+// addBucket adds c samples to the bucket of ms, l.lock must be held
+func (l *latencyStats) addBucket(ms, c int64) {
+	if l.buckets == nil {
+		l.buckets = make(map[int64]int64)
+	}
+	l.buckets[min(max(ms, 0), int64(maxLatency/time.Millisecond))] += c
+}
+
+func (l *latencyStats) merge(other *latencyStats) {
+	other.lock.Lock()
+	defer other.lock.Unlock()
+	if other.count == 0 {
+		return
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for ms, c := range other.buckets {
+		l.addBucket(ms, c)
+	}
+	l.count += other.count
+	l.early += other.early
+}
+
+// histogram returns a copy of the buckets, nil if there are no samples
+func (l *latencyStats) histogram() map[int64]int64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.count == 0 {
+		return nil
+	}
+	return maps.Clone(l.buckets)
+}
+
+// load replaces the histogram with buckets of the same resolution
+func (l *latencyStats) load(buckets map[int64]int64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.buckets = nil
+	l.count = 0
+	l.early = 0
+	for ms, c := range buckets {
+		l.addBucket(ms, c)
+		l.count += c
+	}
+}
+
+func (l *latencyStats) earlySamples() int64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.early
+}
+
+func (l *latencyStats) samples() int64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.count
+}
+
+// percentile returns the latency below which p (0-100) percent of samples fall
+func (l *latencyStats) percentile(p float64) time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.count == 0 {
+		return 0
+	}
+
+	target := int64(float64(l.count) * p / 100)
+	if target < 1 {
+		target = 1
+	}
+	keys := make([]int64, 0, len(l.buckets))
+	for ms := range l.buckets {
+		keys = append(keys, ms)
+	}
+	slices.Sort(keys)
+	var seen int64
+	for _, ms := range keys {
+		seen += l.buckets[ms]
+		if seen >= target {
+			return time.Duration(ms) * time.Millisecond
+		}
+	}
+	return maxLatency
 }
 
 func getTestSummary(summaries map[string]*summary) *summary {
@@ -60,23 +247,32 @@ func getTestSummary(summaries map[string]*summary) *summary {
 			s.elapsed = testerSummary.elapsed
 		}
 		s.errCount += testerSummary.errCount
+		s.subscriptionFailures += testerSummary.subscriptionFailures
+		s.reconnects += testerSummary.reconnects
+		s.latency.merge(&testerSummary.latency)
+		s.jitterTotal += testerSummary.jitterTotal
+		s.nacks += testerSummary.nacks
+		s.plis += testerSummary.plis
+		s.freezes += testerSummary.freezes
+		s.freezeDuration += testerSummary.freezeDuration
+		s.rttTotal += testerSummary.rttTotal
+		s.rttCount += testerSummary.rttCount
 	}
 	return s
 }
 
 func getTesterSummary(testerStats *testerStats) *summary {
 	s := &summary{
-		expected: testerStats.expectedTracks,
+		expected:             testerStats.expectedTracks,
+		subscriptionFailures: testerStats.subscriptionFailures,
+		reconnects:           testerStats.reconnects,
+	}
+	if testerStats.rtt > 0 {
+		s.rttTotal = time.Duration(testerStats.rtt) * time.Millisecond
+		s.rttCount = 1
 	}
 	for _, trackStats := range testerStats.trackStats {
-		s.tracks++
-		s.packets += trackStats.packets.Load()
-		s.bytes += trackStats.bytes.Load()
-		s.dropped += trackStats.dropped.Load()
-		elapsed := time.Since(trackStats.startedAt.Load())
-		if elapsed > s.elapsed {
-			s.elapsed = elapsed
-		}
+		s.addTrack(trackStats)
 	}
 	if testerStats.err == nil {
 		s.errString = "-"
@@ -86,3 +282,106 @@ func getTesterSummary(testerStats *testerStats) *summary {
 	}
 	return s
 }
+
+// getCodecSummaries groups the tracks of all testers by codec
+func getCodecSummaries(stats []*testerStats) map[string]*summary {
+	summaries := make(map[string]*summary)
+	for _, testerStats := range stats {
+		for _, trackStats := range testerStats.trackStats {
+			s := summaries[trackStats.codec]
+			if s == nil {
+				s = &summary{}
+				summaries[trackStats.codec] = s
+			}
+			s.addTrack(trackStats)
+		}
+	}
+	return summaries
+}
+
+func (s *summary) addTrack(trackStats *trackStats) {
+	s.tracks++
+	s.packets += trackStats.packets.Load()
+	s.bytes += trackStats.bytes.Load()
+	s.dropped += trackStats.dropped.Load()
+	elapsed := trackStats.elapsed()
+	if elapsed > s.elapsed {
+		s.elapsed = elapsed
+	}
+	s.latency.merge(&trackStats.latency)
+	s.jitterTotal += trackStats.jitter.Load()
+	s.nacks += trackStats.nacks.Load()
+	s.plis += trackStats.plis.Load()
+	s.freezes += trackStats.freezes.Load()
+	s.freezeDuration += trackStats.freezeDuration.Load()
+}
+
+func (s *summary) jitter() time.Duration {
+	if s.tracks == 0 {
+		return 0
+	}
+	return s.jitterTotal / time.Duration(s.tracks)
+}
+
+func (s *summary) rtt() time.Duration {
+	if s.rttCount == 0 {
+		return 0
+	}
+	return s.rttTotal / time.Duration(s.rttCount)
+}
+
+// jitterCalculator estimates interarrival jitter, as described in RFC 3550 section 6.4.1
+type jitterCalculator struct {
+	clockRate   float64
+	lastArrival time.Time
+	lastTS      uint32
+	// in RTP timestamp units
+	jitter float64
+}
+
+func (j *jitterCalculator) add(timestamp uint32, arrival time.Time) time.Duration {
+	if !j.lastArrival.IsZero() && j.clockRate > 0 {
+		d := arrival.Sub(j.lastArrival).Seconds()*j.clockRate - float64(int32(timestamp-j.lastTS))
+		if d < 0 {
+			d = -d
+		}
+		j.jitter += (d - j.jitter) / 16
+	}
+	j.lastArrival = arrival
+	j.lastTS = timestamp
+	return time.Duration(j.jitter / j.clockRate * float64(time.Second))
+}
+
+// frames needed to estimate the frame rate before freezes are detected
+const freezeMinFrames = 10
+
+// freezeDetector follows the definition of a freeze in WebRTC stats: a gap between frames
+// longer than three times the average frame duration, or the average plus 150ms, whichever is longer
+type freezeDetector struct {
+	lastFrameAt time.Time
+	averageGap  time.Duration
+	frames      int
+}
+
+// addFrame returns the time since the previous frame, and whether the video was frozen in between
+func (f *freezeDetector) addFrame(at time.Time) (time.Duration, bool) {
+	defer func() {
+		f.lastFrameAt = at
+	}()
+	if f.lastFrameAt.IsZero() {
+		return 0, false
+	}
+
+	gap := at.Sub(f.lastFrameAt)
+	if f.frames >= freezeMinFrames && gap > max(3*f.averageGap, f.averageGap+150*time.Millisecond) {
+		// leave freezes out of the average
+		return gap, true
+	}
+	if f.frames == 0 {
+		f.averageGap = gap
+	} else {
+		f.averageGap += (gap - f.averageGap) / 8
+	}
+	f.frames++
+	return gap, false
+}
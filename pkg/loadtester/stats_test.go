@@ -0,0 +1,86 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJitterCalculator(t *testing.T) {
+	j := &jitterCalculator{clockRate: 90000}
+	start := time.Now()
+
+	// packets arriving exactly on time have no jitter
+	for i := 0; i < 10; i++ {
+		require.Zero(t, j.add(uint32(i*3000), start.Add(time.Duration(i)*time.Second/30)))
+	}
+
+	// alternating 10ms early and late converges to 20ms
+	var jitter time.Duration
+	for i := 10; i < 500; i++ {
+		offset := 10 * time.Millisecond
+		if i%2 == 0 {
+			offset = -offset
+		}
+		jitter = j.add(uint32(i*3000), start.Add(time.Duration(i)*time.Second/30+offset))
+	}
+	require.InDelta(t, 20, float64(jitter)/float64(time.Millisecond), 0.5)
+}
+
+func TestFreezeDetector(t *testing.T) {
+	f := &freezeDetector{}
+	at := time.Now()
+	for i := 0; i < 30; i++ {
+		at = at.Add(33 * time.Millisecond)
+		_, frozen := f.addFrame(at)
+		require.False(t, frozen)
+	}
+
+	at = at.Add(500 * time.Millisecond)
+	gap, frozen := f.addFrame(at)
+	require.True(t, frozen)
+	require.Equal(t, 500*time.Millisecond, gap)
+
+	// the average isn't skewed by the freeze
+	at = at.Add(40 * time.Millisecond)
+	_, frozen = f.addFrame(at)
+	require.False(t, frozen)
+}
+
+func TestLatencyStats(t *testing.T) {
+	l := &latencyStats{}
+	for i := 0; i < 100; i++ {
+		l.add(time.Duration(10+i%10) * time.Millisecond)
+	}
+	l.add(time.Minute)
+	// only the buckets that were seen are kept
+	require.Len(t, l.histogram(), 11)
+	require.Equal(t, 14*time.Millisecond, l.percentile(50))
+	require.Equal(t, maxLatency, l.percentile(100))
+
+	merged := &latencyStats{}
+	merged.merge(l)
+	merged.merge(l)
+	require.Equal(t, int64(202), merged.samples())
+	require.Equal(t, l.percentile(95), merged.percentile(95))
+
+	loaded := &latencyStats{}
+	loaded.load(l.histogram())
+	require.Equal(t, l.samples(), loaded.samples())
+	require.Equal(t, l.percentile(99), loaded.percentile(99))
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Thresholds are pass/fail criteria checked against the test summary. Zero values are not checked
+type Thresholds struct {
+	// max percentage of packets dropped, 0-100
+	MaxPacketLoss float64
+	// min ratio of subscribed tracks to expected tracks, 0-1
+	MinTrackRatio float64
+	// max number of testers that ended with an error, nil to allow any
+	MaxErrors *int64
+	// max p95 end-to-end latency, requires MeasureLatency
+	MaxLatency time.Duration
+}
+
+// ThresholdError is returned when a test completes but breaches one or more thresholds
+type ThresholdError struct {
+	Breaches []string
+}
+
+func (e *ThresholdError) Error() string {
+	return "load test failed: " + strings.Join(e.Breaches, ", ")
+}
+
+func (th *Thresholds) enabled() bool {
+	return th.MaxPacketLoss > 0 || th.MinTrackRatio > 0 || th.MaxErrors != nil || th.MaxLatency > 0
+}
+
+// evaluate returns a description of each threshold breached by s
+func (th *Thresholds) evaluate(s *summary) []string {
+	var breaches []string
+	if th.MaxPacketLoss > 0 && s.packets+s.dropped > 0 {
+		loss := float64(s.dropped) / float64(s.packets+s.dropped) * 100
+		if loss > th.MaxPacketLoss {
+			breaches = append(breaches, fmt.Sprintf("packet loss %.2f%% > %.2f%%", loss, th.MaxPacketLoss))
+		}
+	}
+	if th.MinTrackRatio > 0 && s.expected > 0 {
+		ratio := float64(s.tracks) / float64(s.expected)
+		if ratio < th.MinTrackRatio {
+			breaches = append(breaches, fmt.Sprintf("track ratio %.2f (%d/%d) < %.2f",
+				ratio, s.tracks, s.expected, th.MinTrackRatio))
+		}
+	}
+	if th.MaxErrors != nil && s.errCount > *th.MaxErrors {
+		breaches = append(breaches, fmt.Sprintf("errors %d > %d", s.errCount, *th.MaxErrors))
+	}
+	if th.MaxLatency > 0 {
+		if s.latency.samples() == 0 {
+			breaches = append(breaches, "no latency measured")
+		} else if p95 := s.latency.percentile(95); p95 > th.MaxLatency {
+			breaches = append(breaches, fmt.Sprintf("p95 latency %s > %s", p95, th.MaxLatency))
+		}
+	}
+	return breaches
+}
+
+// checkThresholds prints a verdict for all testers, returning a ThresholdError if any threshold was breached
+func (t *LoadTest) checkThresholds(stats map[string]*testerStats) error {
+	if !t.Params.Thresholds.enabled() {
+		return nil
+	}
+
+	summaries := make(map[string]*summary)
+	for name, testerStats := range stats {
+		summaries[name] = getTesterSummary(testerStats)
+	}
+	breaches := t.Params.Thresholds.evaluate(getTestSummary(summaries))
+	if len(breaches) == 0 {
+		fmt.Fprintln(t.Params.output(), "\nThresholds: PASSED")
+		return nil
+	}
+
+	fmt.Fprintln(t.Params.output(), "\nThresholds: FAILED")
+	for _, b := range breaches {
+		fmt.Fprintf(t.Params.output(), "  %s\n", b)
+	}
+	return &ThresholdError{Breaches: breaches}
+}
@@ -0,0 +1,51 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestThresholds(t *testing.T) {
+	s := &summary{
+		tracks:   3,
+		expected: 4,
+		packets:  90,
+		dropped:  10,
+		errCount: 1,
+	}
+	s.latency.add(50 * time.Millisecond)
+
+	maxErrors := int64(1)
+	th := &Thresholds{
+		MaxPacketLoss: 10,
+		MinTrackRatio: 0.75,
+		MaxErrors:     &maxErrors,
+		MaxLatency:    100 * time.Millisecond,
+	}
+	require.Empty(t, th.evaluate(s))
+
+	maxErrors = 0
+	th.MaxPacketLoss = 5
+	th.MinTrackRatio = 0.9
+	th.MaxLatency = 20 * time.Millisecond
+	require.Len(t, th.evaluate(s), 4)
+
+	require.False(t, (&Thresholds{}).enabled())
+	require.Equal(t, []string{"no latency measured"}, (&Thresholds{MaxLatency: time.Second}).evaluate(&summary{}))
+}
@@ -0,0 +1,238 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pion/interceptor"
+)
+
+// connectTimes records when a tester reached each step of its first join. Later joins are not recorded
+type connectTimes struct {
+	lock      sync.Mutex
+	startedAt time.Time
+	// start of the latest attempt
+	attemptAt time.Time
+	// join response received, when peer connections are created
+	signalAt time.Time
+	// the SDK considers the room joined once ICE of the primary peer connection connects
+	joinedAt      time.Time
+	firstTrackAt  time.Time
+	firstPacketAt time.Time
+	retries       int
+}
+
+// setupTiming is how long each phase of joining took. Phases that did not happen are 0
+type setupTiming struct {
+	// join request until the join response
+	signal time.Duration
+	// join response until ICE connected
+	ice time.Duration
+	// start until joined, including retries
+	join time.Duration
+	// joined until the first track was subscribed
+	firstTrack time.Duration
+	// first track subscribed until the first media packet, including DTLS
+	firstPacket time.Duration
+	retries     int
+}
+
+func (c *connectTimes) startAttempt(attempt int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.joinedAt.IsZero() {
+		return
+	}
+	now := time.Now()
+	if c.startedAt.IsZero() {
+		c.startedAt = now
+	}
+	c.attemptAt = now
+	c.signalAt = time.Time{}
+	c.retries = attempt
+}
+
+func (c *connectTimes) signaled() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.joinedAt.IsZero() && c.signalAt.IsZero() {
+		c.signalAt = time.Now()
+	}
+}
+
+func (c *connectTimes) joined() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.joinedAt.IsZero() {
+		c.joinedAt = time.Now()
+	}
+}
+
+func (c *connectTimes) trackSubscribed() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.firstTrackAt.IsZero() {
+		c.firstTrackAt = time.Now()
+	}
+}
+
+func (c *connectTimes) packetReceived() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.firstPacketAt.IsZero() {
+		c.firstPacketAt = time.Now()
+	}
+}
+
+func (c *connectTimes) timing() setupTiming {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	since := func(from, to time.Time) time.Duration {
+		if from.IsZero() || to.IsZero() || to.Before(from) {
+			return 0
+		}
+		return to.Sub(from)
+	}
+	return setupTiming{
+		signal:      since(c.attemptAt, c.signalAt),
+		ice:         since(c.signalAt, c.joinedAt),
+		join:        since(c.startedAt, c.joinedAt),
+		firstTrack:  since(c.joinedAt, c.firstTrackAt),
+		firstPacket: since(c.firstTrackAt, c.firstPacketAt),
+		retries:     c.retries,
+	}
+}
+
+// signalTimerFactory is notified when the SDK creates peer connections, right after the join response
+type signalTimerFactory struct {
+	onCreate func()
+}
+
+func (f *signalTimerFactory) NewInterceptor(_ string) (interceptor.Interceptor, error) {
+	f.onCreate()
+	return &interceptor.NoOp{}, nil
+}
+
+var setupPhases = []string{"Signal", "ICE", "Join", "First track", "First packet"}
+
+func (s setupTiming) phases() []time.Duration {
+	return []time.Duration{s.signal, s.ice, s.join, s.firstTrack, s.firstPacket}
+}
+
+// setupSummary holds the sorted durations of each phase across testers
+type setupSummary struct {
+	phases  [][]time.Duration
+	retries int
+	retried int
+}
+
+func getSetupSummary(stats map[string]*testerStats) *setupSummary {
+	s := &setupSummary{
+		phases: make([][]time.Duration, len(setupPhases)),
+	}
+	for _, testerStats := range stats {
+		for i, d := range testerStats.setup.phases() {
+			if d > 0 {
+				s.phases[i] = append(s.phases[i], d)
+			}
+		}
+		s.retries += testerStats.setup.retries
+		if testerStats.setup.retries > 0 {
+			s.retried++
+		}
+	}
+	for _, durations := range s.phases {
+		sort.Slice(durations, func(i, j int) bool {
+			return durations[i] < durations[j]
+		})
+	}
+	return s
+}
+
+// durationPercentile returns the duration below which p (0-100) percent of sorted durations fall
+func durationPercentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted))*p/100+0.5) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+func printSetupResults(out io.Writer, stats map[string]*testerStats) {
+	s := getSetupSummary(stats)
+	if len(s.phases[0]) == 0 {
+		return
+	}
+	w := tabwriter.NewWriter(out, 1, 1, 1, ' ', 0)
+	_, _ = fmt.Fprint(w, "\nSetup\t| Phase\t| Testers\t| p50\t| p95\t| p99\t| Max\n")
+	for i, phase := range setupPhases {
+		durations := s.phases[i]
+		if len(durations) == 0 {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "\t| %s\t| %d\t| %s\t| %s\t| %s\t| %s\n",
+			phase, len(durations), formatMs(durationPercentile(durations, 50)), formatMs(durationPercentile(durations, 95)),
+			formatMs(durationPercentile(durations, 99)), formatMs(durations[len(durations)-1]))
+	}
+	_ = w.Flush()
+	fmt.Fprintf(out, "Join retries: %d (%d testers)\n", s.retries, s.retried)
+}
+
+func (s *setupSummary) toReport() []*SetupPhaseReport {
+	var reports []*SetupPhaseReport
+	for i, phase := range setupPhases {
+		durations := s.phases[i]
+		if len(durations) == 0 {
+			continue
+		}
+		reports = append(reports, &SetupPhaseReport{
+			Phase:   phase,
+			Testers: len(durations),
+			P50Ms:   durationPercentile(durations, 50).Milliseconds(),
+			P95Ms:   durationPercentile(durations, 95).Milliseconds(),
+			P99Ms:   durationPercentile(durations, 99).Milliseconds(),
+			MaxMs:   durations[len(durations)-1].Milliseconds(),
+		})
+	}
+	return reports
+}
+
+func (s setupTiming) toReport() *SetupReport {
+	return &SetupReport{
+		SignalMs:      s.signal.Milliseconds(),
+		IceMs:         s.ice.Milliseconds(),
+		JoinMs:        s.join.Milliseconds(),
+		FirstTrackMs:  s.firstTrack.Milliseconds(),
+		FirstPacketMs: s.firstPacket.Milliseconds(),
+		Retries:       s.retries,
+	}
+}
+
+func (r *SetupReport) timing() setupTiming {
+	return setupTiming{
+		signal:      time.Duration(r.SignalMs) * time.Millisecond,
+		ice:         time.Duration(r.IceMs) * time.Millisecond,
+		join:        time.Duration(r.JoinMs) * time.Millisecond,
+		firstTrack:  time.Duration(r.FirstTrackMs) * time.Millisecond,
+		firstPacket: time.Duration(r.FirstPacketMs) * time.Millisecond,
+		retries:     r.Retries,
+	}
+}
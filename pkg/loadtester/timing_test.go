@@ -0,0 +1,68 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadtester
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectTimes(t *testing.T) {
+	c := &connectTimes{}
+	c.startAttempt(0)
+	c.signaled()
+	c.startAttempt(1)
+	c.signaled()
+	c.joined()
+	c.trackSubscribed()
+	c.packetReceived()
+
+	timing := c.timing()
+	require.Equal(t, 1, timing.retries)
+	require.GreaterOrEqual(t, timing.join, timing.signal+timing.ice)
+
+	// later joins are not recorded
+	joinedAt := c.joinedAt
+	c.startAttempt(0)
+	c.joined()
+	require.Equal(t, joinedAt, c.joinedAt)
+	require.Equal(t, 1, c.timing().retries)
+}
+
+func TestSetupSummary(t *testing.T) {
+	stats := make(map[string]*testerStats)
+	for i := 1; i <= 100; i++ {
+		stats[string(rune(i))] = &testerStats{setup: setupTiming{
+			signal: time.Duration(i) * time.Millisecond,
+			join:   time.Duration(i) * time.Second,
+		}}
+	}
+	stats["retried"] = &testerStats{setup: setupTiming{retries: 3}}
+
+	s := getSetupSummary(stats)
+	require.Equal(t, 3, s.retries)
+	require.Equal(t, 1, s.retried)
+	require.Len(t, s.phases[0], 100)
+	require.Empty(t, s.phases[1])
+	require.Equal(t, 50*time.Millisecond, durationPercentile(s.phases[0], 50))
+	require.Equal(t, 95*time.Second, durationPercentile(s.phases[2], 95))
+
+	reports := s.toReport()
+	require.Len(t, reports, 2)
+	require.Equal(t, "Join", reports[1].Phase)
+	require.Equal(t, int64(100_000), reports[1].MaxMs)
+}
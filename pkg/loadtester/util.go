@@ -16,6 +16,7 @@ package loadtester
 
 import (
 	"fmt"
+	"io"
 	"math/rand"
 	"strings"
 	"time"
@@ -56,3 +57,37 @@ func formatBitrate(bytes int64, elapsed time.Duration) string {
 		return fmt.Sprintf("%.1fmbps", bps/1000000)
 	}
 }
+
+func formatLatency(latency *latencyStats) string {
+	if latency.samples() == 0 {
+		return " - "
+	}
+	return fmt.Sprintf("%s / %s / %s",
+		latency.percentile(50).String(),
+		latency.percentile(95).String(),
+		latency.percentile(99).String(),
+	)
+}
+
+// printClockWarning points out samples that arrived before they were sent, as their latency can't be trusted
+func printClockWarning(out io.Writer, what string, latency *latencyStats) {
+	if early := latency.earlySamples(); early > 0 {
+		_, _ = fmt.Fprintf(out, "Warning: %d of %d %s samples arrived before they were sent, clocks of the hosts are out of sync\n",
+			early, latency.samples(), what)
+	}
+}
+
+func formatFreezes(freezes int64, duration time.Duration) string {
+	if freezes == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%d (%s)", freezes, duration.Round(time.Millisecond))
+}
+
+// formatMs formats a duration in milliseconds, or a dash when it wasn't measured
+func formatMs(d time.Duration) string {
+	if d == 0 {
+		return " - "
+	}
+	return fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond))
+}